@@ -4,8 +4,10 @@ import (
 	"container/heap"
 	"fmt"
 	"math/rand"
+	"os"
 	"runtime"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,6 +22,16 @@ const (
 	k          = 10
 )
 
+// skipUnlessReports skips the performance report tests, which measure
+// rather than check and take minutes (far longer under -race), unless
+// VEXOR_REPORTS is set.
+func skipUnlessReports(t *testing.T) {
+	t.Helper()
+	if testing.Short() || os.Getenv("VEXOR_REPORTS") == "" {
+		t.Skip("set VEXOR_REPORTS=1 to run the performance reports")
+	}
+}
+
 // generateRandomVector creates a random float32 vector of given dimension.
 func generateRandomVector(dim int, rng *rand.Rand) []float32 {
 	v := make([]float32, dim)
@@ -53,6 +65,106 @@ func BenchmarkSearch(b *testing.B) {
 	}
 }
 
+// BenchmarkSearchInto benchmarks k-NN search into a reused result slice.
+func BenchmarkSearchInto(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	s := store.NewVectorStore(dimension)
+
+	for i := 0; i < numVectors; i++ {
+		s.Insert(store.Vector{
+			ID:   fmt.Sprintf("vec-%d", i),
+			Data: generateRandomVector(dimension, rng),
+		})
+	}
+
+	queries := make([][]float32, numQueries)
+	for i := range queries {
+		queries[i] = generateRandomVector(dimension, rng)
+	}
+
+	dst := make([]store.SearchResult, 0, k)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query := queries[i%numQueries]
+		dst, _ = s.SearchInto(dst, query, k)
+	}
+}
+
+// BenchmarkSearchUint64 benchmarks k-NN search on the numeric-ID store.
+func BenchmarkSearchUint64(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	s := store.NewUint64Store(dimension)
+
+	for i := 0; i < numVectors; i++ {
+		s.Insert(store.Uint64Vector{
+			ID:   uint64(i),
+			Data: generateRandomVector(dimension, rng),
+		})
+	}
+
+	queries := make([][]float32, numQueries)
+	for i := range queries {
+		queries[i] = generateRandomVector(dimension, rng)
+	}
+
+	dst := make([]store.Uint64Result, 0, k)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query := queries[i%numQueries]
+		dst, _ = s.SearchInto(dst, query, k)
+	}
+}
+
+// BenchmarkMixedReadWrite measures search and upsert throughput when both
+// run concurrently. Each sub-benchmark sets the fraction of operations that
+// are writes; searches never wait on writers.
+func BenchmarkMixedReadWrite(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	s := store.NewVectorStore(dimension)
+
+	for i := 0; i < numVectors; i++ {
+		s.Insert(store.Vector{
+			ID:   fmt.Sprintf("vec-%d", i),
+			Data: generateRandomVector(dimension, rng),
+		})
+	}
+
+	queries := make([][]float32, numQueries)
+	for i := range queries {
+		queries[i] = generateRandomVector(dimension, rng)
+	}
+
+	for _, writePct := range []int{1, 10, 50} {
+		b.Run(fmt.Sprintf("writes=%d%%", writePct), func(b *testing.B) {
+			var reads, writes atomic.Int64
+			var seed atomic.Int64
+			start := time.Now()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				local := rand.New(rand.NewSource(seed.Add(1)))
+				dst := make([]store.SearchResult, 0, k)
+				for pb.Next() {
+					if local.Intn(100) < writePct {
+						s.Insert(store.Vector{
+							ID:   fmt.Sprintf("vec-%d", local.Intn(numVectors)),
+							Data: generateRandomVector(dimension, local),
+						})
+						writes.Add(1)
+					} else {
+						dst, _ = s.SearchInto(dst, queries[local.Intn(numQueries)], k)
+						reads.Add(1)
+					}
+				}
+			})
+			elapsed := time.Since(start).Seconds()
+			b.ReportMetric(float64(reads.Load())/elapsed, "reads/s")
+			b.ReportMetric(float64(writes.Load())/elapsed, "writes/s")
+		})
+	}
+}
+
 // BenchmarkSearchCosine benchmarks cosine similarity search.
 func BenchmarkSearchCosine(b *testing.B) {
 	rng := rand.New(rand.NewSource(42))
@@ -79,9 +191,7 @@ func BenchmarkSearchCosine(b *testing.B) {
 
 // TestQPSAndLatency measures QPS and latency metrics.
 func TestQPSAndLatency(t *testing.T) {
-	if testing.Short() {
-		t.Skip("skipping QPS test in short mode")
-	}
+	skipUnlessReports(t)
 
 	rng := rand.New(rand.NewSource(42))
 	s := store.NewVectorStore(dimension)
@@ -154,9 +264,7 @@ func BenchmarkInsert(b *testing.B) {
 
 // TestFullReport produces a comprehensive performance comparison across all optimization levels.
 func TestFullReport(t *testing.T) {
-	if testing.Short() {
-		t.Skip("skipping full report in short mode")
-	}
+	skipUnlessReports(t)
 
 	rng := rand.New(rand.NewSource(42))
 
@@ -325,9 +433,7 @@ func TestFullReport(t *testing.T) {
 
 // TestScalability measures search performance at different GOMAXPROCS values.
 func TestScalability(t *testing.T) {
-	if testing.Short() {
-		t.Skip("skipping scalability test in short mode")
-	}
+	skipUnlessReports(t)
 
 	rng := rand.New(rand.NewSource(42))
 	s := store.NewVectorStore(dimension)
@@ -456,9 +562,7 @@ func (h *aosMaxHeap) Pop() any {
 
 // TestAoSvsSoA compares search performance between AoS and SoA memory layouts.
 func TestAoSvsSoA(t *testing.T) {
-	if testing.Short() {
-		t.Skip("skipping AoS vs SoA test in short mode")
-	}
+	skipUnlessReports(t)
 
 	rng := rand.New(rand.NewSource(42))
 
@@ -0,0 +1,118 @@
+package bench
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"vexor/pkg/eval"
+	"vexor/pkg/store"
+	"vexor/pkg/vecio"
+)
+
+// TestDataset measures QPS, latency and recall on a real dataset in TEXMEX
+// format, such as SIFT1M from http://corpus-texmex.irisa.fr/. Point it at
+// local files with:
+//
+//	VEXOR_BASE=sift_base.fvecs       base vectors (.fvecs or .bvecs)
+//	VEXOR_QUERY=sift_query.fvecs     query vectors
+//	VEXOR_GT=sift_groundtruth.ivecs  optional ground truth neighbor lists
+//	VEXOR_BASE_LIMIT=100000          optional cap on base vectors loaded
+//	VEXOR_QUERY_LIMIT=1000           optional cap on queries run
+//
+// Ground truth positions refer to the full base file, so with
+// VEXOR_BASE_LIMIT or without VEXOR_GT it is recomputed by brute force.
+func TestDataset(t *testing.T) {
+	basePath, queryPath := os.Getenv("VEXOR_BASE"), os.Getenv("VEXOR_QUERY")
+	if basePath == "" || queryPath == "" {
+		t.Skip("set VEXOR_BASE and VEXOR_QUERY to run the dataset benchmark")
+	}
+	baseLimit := envInt(t, "VEXOR_BASE_LIMIT")
+	queryLimit := envInt(t, "VEXOR_QUERY_LIMIT")
+
+	loadStart := time.Now()
+	base, err := vecio.LoadVectors(basePath, baseLimit)
+	if err != nil {
+		t.Fatalf("loading base vectors: %v", err)
+	}
+	queries, err := vecio.LoadVectors(queryPath, queryLimit)
+	if err != nil {
+		t.Fatalf("loading queries: %v", err)
+	}
+	if len(base) == 0 || len(queries) == 0 {
+		t.Fatal("dataset is empty")
+	}
+	dim := len(base[0])
+	t.Logf("Loaded %d base vectors and %d queries (dim %d) in %v", len(base), len(queries), dim, time.Since(loadStart))
+
+	s := store.NewVectorStore(dim)
+	insertStart := time.Now()
+	for i, v := range base {
+		s.Insert(store.Vector{ID: strconv.Itoa(i), Data: v})
+	}
+	t.Logf("Insert completed in %v", time.Since(insertStart))
+
+	var truth [][]store.SearchResult
+	if gtPath := os.Getenv("VEXOR_GT"); gtPath != "" && baseLimit <= 0 {
+		gt, err := vecio.LoadGroundTruth(gtPath, len(queries))
+		if err != nil {
+			t.Fatalf("loading ground truth: %v", err)
+		}
+		if len(gt) != len(queries) {
+			t.Fatalf("ground truth has %d lists for %d queries", len(gt), len(queries))
+		}
+		truth = make([][]store.SearchResult, len(gt))
+		for i, ids := range gt {
+			for _, id := range ids[:min(k, len(ids))] {
+				truth[i] = append(truth[i], store.SearchResult{ID: strconv.Itoa(int(id))})
+			}
+		}
+	} else {
+		if truth, err = eval.GroundTruth(s, queries, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latencies := make([]time.Duration, len(queries))
+	var recall float64
+	queryStart := time.Now()
+	for i, q := range queries {
+		start := time.Now()
+		res, err := s.Search(q, k)
+		latencies[i] = time.Since(start)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		recall += eval.Recall(res, truth[i], k)
+	}
+	qps := float64(len(queries)) / time.Since(queryStart).Seconds()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p50, p99, p999 := percentiles(latencies)
+
+	t.Logf("\n=== Dataset Report ===")
+	t.Logf("Base: %s (%d vectors, dim %d)", basePath, len(base), dim)
+	t.Logf("Queries: %d, k: %d, GOMAXPROCS: %d", len(queries), k, runtime.GOMAXPROCS(0))
+	t.Logf("-----------------------------------")
+	t.Logf("QPS:           %.2f", qps)
+	t.Logf("Recall@%d:     %.4f", k, recall/float64(len(queries)))
+	t.Logf("P50 Latency:   %v", p50)
+	t.Logf("P99 Latency:   %v", p99)
+	t.Logf("P99.9 Latency: %v", p999)
+	t.Logf("===================================")
+}
+
+func envInt(t *testing.T, name string) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		t.Fatalf("%s=%q is not an integer", name, v)
+	}
+	return n
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"vexor/pkg/distance"
+	"vexor/pkg/eval"
+	"vexor/pkg/store"
+	"vexor/pkg/vecio"
+)
+
+// evalIndex builds a searchable index over a loaded store. params lists
+// the sweep parameters the index reads; the rest are ignored.
+type evalIndex struct {
+	build  func(s *store.VectorStore, p eval.Params) (eval.Searcher, error)
+	params []string
+}
+
+// evalIndexes are the indexes `vexor eval` can sweep. New approximate
+// indexes register here.
+var evalIndexes = map[string]evalIndex{
+	"flat": {
+		build: func(s *store.VectorStore, _ eval.Params) (eval.Searcher, error) {
+			return s, nil
+		},
+	},
+}
+
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	var (
+		n       = fs.Int("n", 10_000, "number of random vectors to index")
+		dim     = fs.Int("dim", 128, "vector dimension")
+		queries = fs.Int("queries", 100, "number of random queries")
+		k       = fs.Int("k", 10, "neighbors per query")
+		seed    = fs.Int64("seed", 1, "random seed")
+		index   = fs.String("index", "flat", "index to evaluate: "+strings.Join(indexNames(), ", "))
+		ef      = fs.String("ef", "", "comma-separated ef values to sweep")
+		nprobe  = fs.String("nprobe", "", "comma-separated nprobe values to sweep")
+		rerank  = fs.String("rerank", "", "comma-separated rerank depths to sweep")
+		base    = fs.String("base", "", "load base vectors from a .fvecs or .bvecs file (-n caps how many)")
+		query   = fs.String("query", "", "load queries from a .fvecs or .bvecs file (-queries caps how many)")
+		gt      = fs.String("gt", "", "load ground truth for -query from an .ivecs file instead of brute-forcing it")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	idx, ok := evalIndexes[*index]
+	if !ok {
+		return fmt.Errorf("unknown index %q", *index)
+	}
+	grid, err := parseGrid(*ef, *nprobe, *rerank)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct{ name, value string }{{"ef", *ef}, {"nprobe", *nprobe}, {"rerank", *rerank}} {
+		if f.value != "" && !slices.Contains(idx.params, f.name) {
+			fmt.Fprintf(os.Stderr, "note: index %q ignores -%s\n", *index, f.name)
+		}
+	}
+
+	if (*base == "") != (*query == "") {
+		return fmt.Errorf("-base and -query must be given together")
+	}
+	if *gt != "" && *base == "" {
+		return fmt.Errorf("-gt needs -base and -query")
+	}
+	var vecs, qs [][]float32
+	if *base != "" {
+		if vecs, err = vecio.LoadVectors(*base, *n); err != nil {
+			return err
+		}
+		if qs, err = vecio.LoadVectors(*query, *queries); err != nil {
+			return err
+		}
+		if len(vecs) == 0 || len(qs) == 0 {
+			return fmt.Errorf("empty dataset")
+		}
+		*n, *queries, *dim = len(vecs), len(qs), len(vecs[0])
+	} else {
+		rng := rand.New(rand.NewSource(*seed))
+		vecs = make([][]float32, *n)
+		for i := range vecs {
+			vecs[i] = randomVector(rng, *dim)
+		}
+		qs = make([][]float32, *queries)
+		for i := range qs {
+			qs[i] = randomVector(rng, *dim)
+		}
+	}
+
+	s := store.NewVectorStore(*dim)
+	for i, v := range vecs {
+		if err := s.Insert(store.Vector{ID: fmt.Sprintf("vec-%d", i), Data: v}); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	var truth [][]store.SearchResult
+	if *gt != "" {
+		truth, err = loadTruth(*gt, vecs, qs, *k)
+	} else {
+		truth, err = eval.GroundTruth(s, qs, *k)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ground truth: %d queries over %d vectors (dim %d) in %v\n\n", *queries, *n, *dim, time.Since(start))
+
+	reports, err := eval.Sweep(func(p eval.Params) (eval.Searcher, error) {
+		return idx.build(s, p)
+	}, grid, qs, truth, *k)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "params\trecall@%d\tMRR\tdist ratio\tQPS\t\n", *k)
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%.4f\t%.4f\t%.4f\t%.0f\t\n", r.Params, r.Recall, r.MRR, r.DistanceRatio, r.QPS)
+	}
+	return w.Flush()
+}
+
+// loadTruth reads the first k neighbors of each query from an .ivecs
+// ground truth file, such as a TEXMEX *_groundtruth.ivecs, whose entries
+// are positions in base. Distances are recomputed so the distance ratio
+// can be reported.
+func loadTruth(path string, base, queries [][]float32, k int) ([][]store.SearchResult, error) {
+	gt, err := vecio.LoadGroundTruth(path, len(queries))
+	if err != nil {
+		return nil, err
+	}
+	if len(gt) != len(queries) {
+		return nil, fmt.Errorf("ground truth has %d lists for %d queries", len(gt), len(queries))
+	}
+	truth := make([][]store.SearchResult, len(gt))
+	for i, ids := range gt {
+		for _, id := range ids[:min(k, len(ids))] {
+			if id < 0 || int(id) >= len(base) {
+				return nil, fmt.Errorf("ground truth refers to base vector %d but %d are loaded; raise -n", id, len(base))
+			}
+			truth[i] = append(truth[i], store.SearchResult{
+				ID:       fmt.Sprintf("vec-%d", id),
+				Distance: distance.EuclideanDistance(queries[i], base[id]),
+			})
+		}
+	}
+	return truth, nil
+}
+
+// parseGrid turns the sweep flags into the parameter grid.
+func parseGrid(ef, nprobe, rerank string) ([]eval.Params, error) {
+	var lists [3][]int
+	for i, s := range []string{ef, nprobe, rerank} {
+		if s == "" {
+			continue
+		}
+		for _, f := range strings.Split(s, ",") {
+			v, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				return nil, fmt.Errorf("bad sweep value %q: %w", f, err)
+			}
+			lists[i] = append(lists[i], v)
+		}
+	}
+	return eval.Grid(lists[0], lists[1], lists[2]), nil
+}
+
+func indexNames() []string {
+	names := make([]string, 0, len(evalIndexes))
+	for name := range evalIndexes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func randomVector(rng *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = rng.Float32()*2 - 1
+	}
+	return v
+}
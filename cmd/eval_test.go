@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"vexor/pkg/eval"
+	"vexor/pkg/store"
+	"vexor/pkg/vecio"
+)
+
+func TestLoadTruth(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([][]float32, 200)
+	s := store.NewVectorStore(4)
+	for i := range base {
+		base[i] = randomVector(rng, 4)
+		s.Insert(store.Vector{ID: fmt.Sprintf("vec-%d", i), Data: base[i]})
+	}
+	queries := [][]float32{randomVector(rng, 4), randomVector(rng, 4)}
+	want, err := eval.GroundTruth(s, queries, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the neighbor positions out as TEXMEX ground truth, deeper
+	// than k as the published sets are.
+	deep, _ := eval.GroundTruth(s, queries, 8)
+	gt := make([][]int32, len(deep))
+	for i, res := range deep {
+		for _, r := range res {
+			id, _ := strconv.Atoi(strings.TrimPrefix(r.ID, "vec-"))
+			gt[i] = append(gt[i], int32(id))
+		}
+	}
+	path := filepath.Join(t.TempDir(), "gt.ivecs")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vecio.WriteIvecs(f, gt); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, err := loadTruth(path, base, queries, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Fatalf("query %d: %d neighbors, want %d", i, len(got[i]), len(want[i]))
+		}
+		for j, w := range want[i] {
+			if g := got[i][j]; g.ID != w.ID || math.Abs(float64(g.Distance-w.Distance)) > 1e-5 {
+				t.Errorf("query %d rank %d: got %+v, want %+v", i, j, g, w)
+			}
+		}
+	}
+
+	if _, err := loadTruth(path, base[:10], queries, 5); err == nil {
+		t.Error("expected an error for neighbors past the loaded base")
+	}
+	if _, err := loadTruth(path, base, append(queries, queries[0]), 5); err == nil {
+		t.Error("expected an error for more queries than ground truth lists")
+	}
+}
@@ -3,12 +3,49 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"os"
 	"time"
 
 	"vexor/pkg/store"
 )
 
+const usage = `usage: vexor [command] [flags]
+
+With no command, vexor runs a small insert-and-search demo.
+
+Commands:
+  eval    measure recall and QPS of an index against brute-force search
+  import  load JSON Lines or CSV rows into a snapshot file or a cluster
+  export  write a snapshot file out as JSON Lines or CSV
+`
+
 func main() {
+	if len(os.Args) < 2 {
+		runDemo()
+		return
+	}
+	var err error
+	switch os.Args[1] {
+	case "eval":
+		err = runEval(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "vexor: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vexor %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func runDemo() {
 	const (
 		numVectors = 10_000
 		dimension  = 128
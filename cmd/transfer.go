@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"vexor/pkg/cluster"
+	"vexor/pkg/store"
+	"vexor/pkg/vecio"
+)
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	var (
+		in         = fs.String("in", "-", "JSON Lines, CSV or Arrow IPC stream file to read, - for stdin")
+		format     = fs.String("format", "", "input format, jsonl, csv or arrow (default: from the -in extension)")
+		out        = fs.String("out", "", "snapshot file to write")
+		base       = fs.String("base", "", "snapshot to start from; imported rows are added to it")
+		nodes      = fs.String("nodes", "", "comma-separated cluster node addresses to insert into instead of -out")
+		dim        = fs.Int("dim", 0, "vector dimension (default: from -base, else the first row)")
+		workers    = fs.Int("workers", 0, "parallel parsers (default: GOMAXPROCS)")
+		quarantine = fs.String("quarantine", "", "write rejected rows here as JSON Lines instead of stopping")
+		quiet      = fs.Bool("quiet", false, "do not report progress")
+		idCol      = fs.String("id-column", "", "arrow: utf8 ID column (default: the first one)")
+		vecCol     = fs.String("vector-column", "", "arrow: fixed-size list vector column (default: the first one)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*out == "") == (*nodes == "") {
+		return errors.New("exactly one of -out and -nodes is required")
+	}
+	if *base != "" && *nodes != "" {
+		return errors.New("-base only applies with -out")
+	}
+	arrow := *format == "arrow" || (*format == "" && slices.Contains([]string{".arrow", ".arrows"}, filepath.Ext(*in)))
+	var (
+		f   vecio.Format
+		err error
+	)
+	if !arrow {
+		if f, err = recordFormat(*format, *in); err != nil {
+			return err
+		}
+	}
+	r, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var s *store.VectorStore
+	if *base != "" {
+		if s, err = vecio.LoadSnapshot(*base); err != nil {
+			return err
+		}
+		if *dim != 0 && *dim != s.Dimension() {
+			return fmt.Errorf("-dim %d does not match base snapshot dimension %d", *dim, s.Dimension())
+		}
+		*dim = s.Dimension()
+	}
+
+	// emit sends each batch to the cluster or, creating it on first use
+	// once the dimension is known, to the local store.
+	var emit func([]store.Vector) error
+	if *nodes != "" {
+		c := cluster.NewCoordinator(strings.Split(*nodes, ","))
+		defer c.Close()
+		emit = func(batch []store.Vector) error {
+			for _, v := range batch {
+				if err := c.Insert(v); err != nil {
+					return fmt.Errorf("inserting %q: %w", v.ID, err)
+				}
+			}
+			return nil
+		}
+	} else {
+		emit = func(batch []store.Vector) error {
+			if s == nil {
+				// Rows that reach emit all have the dimension ReadRecords
+				// settled on, so any of them gives it.
+				d := *dim
+				if d == 0 {
+					d = vecio.VectorDimension(batch[0])
+				}
+				s = store.NewVectorStore(d)
+			}
+			return s.InsertBatch(batch)
+		}
+	}
+
+	opts := vecio.ReadOptions{Format: f, Dimension: *dim, Workers: *workers}
+	if *quarantine != "" {
+		qf, err := os.Create(*quarantine)
+		if err != nil {
+			return err
+		}
+		defer qf.Close()
+		enc := json.NewEncoder(qf)
+		opts.Reject = func(e *vecio.RowError) error {
+			return enc.Encode(struct {
+				Line  int    `json:"line"`
+				Error string `json:"error"`
+				Row   string `json:"row"`
+			}{e.Line, e.Err.Error(), e.Raw})
+		}
+	}
+	start := time.Now()
+	if !*quiet {
+		last := start
+		opts.Progress = func(st vecio.ReadStats) {
+			if now := time.Now(); now.Sub(last) >= time.Second {
+				last = now
+				fmt.Fprintf(os.Stderr, "%d rows, %d imported, %d rejected (%.0f rows/s)\n",
+					st.Rows, st.Accepted, st.Rejected, float64(st.Rows)/now.Sub(start).Seconds())
+			}
+		}
+	}
+
+	var stats vecio.ReadStats
+	if arrow {
+		stats, err = readArrow(r, *idCol, *vecCol, *dim, emit, opts.Progress)
+	} else {
+		stats, err = vecio.ReadRecords(r, opts, emit)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		if s == nil {
+			return errors.New("no rows imported; snapshot not written")
+		}
+		if _, err := vecio.SaveSnapshot(*out, s); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "imported %d of %d rows in %v", stats.Accepted, stats.Rows, time.Since(start).Round(time.Millisecond))
+	switch {
+	case stats.Rejected > 0 && arrow:
+		fmt.Fprintf(os.Stderr, "; %d rows with nulls skipped", stats.Rejected)
+	case stats.Rejected > 0:
+		fmt.Fprintf(os.Stderr, "; %d rejected, see %s", stats.Rejected, *quarantine)
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+// readArrow feeds the batches of an Arrow IPC stream to emit. Rows with
+// nulls count as rejected.
+func readArrow(r io.Reader, idCol, vecCol string, dim int, emit func([]store.Vector) error, progress func(vecio.ReadStats)) (vecio.ReadStats, error) {
+	var stats vecio.ReadStats
+	ar, err := vecio.NewArrowReader(r, idCol, vecCol)
+	if err != nil {
+		return stats, err
+	}
+	if dim != 0 && ar.Dimension() != dim {
+		return stats, fmt.Errorf("arrow vectors have dimension %d, want %d: %w", ar.Dimension(), dim, store.ErrDimensionMismatch)
+	}
+	for {
+		vecs, err := ar.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Accepted += len(vecs)
+		stats.Rejected = ar.Skipped()
+		stats.Rows = stats.Accepted + stats.Rejected
+		if len(vecs) > 0 {
+			if err := emit(vecs); err != nil {
+				return stats, err
+			}
+		}
+		if progress != nil {
+			progress(stats)
+		}
+	}
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	var (
+		in     = fs.String("in", "", "snapshot file to read")
+		out    = fs.String("out", "-", "JSON Lines or CSV file to write, - for stdout")
+		format = fs.String("format", "", "output format, jsonl or csv (default: from the -out extension)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	f, err := recordFormat(*format, *out)
+	if err != nil {
+		return err
+	}
+	s, err := vecio.LoadSnapshot(*in)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	rw := vecio.NewRecordWriter(w, f)
+	n := 0
+	for v := range s.Scan(store.ScanOptions{Payloads: true}) {
+		if err := rw.Write(v); err != nil {
+			return err
+		}
+		n++
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	if w != os.Stdout {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d vectors\n", n)
+	return nil
+}
+
+// recordFormat resolves the -format flag, falling back to path's extension
+// and then to JSON Lines for stdin and stdout.
+func recordFormat(name, path string) (vecio.Format, error) {
+	if name != "" {
+		return vecio.ParseFormat(name)
+	}
+	if path == "-" {
+		return vecio.FormatJSONL, nil
+	}
+	return vecio.FormatOf(path)
+}
+
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
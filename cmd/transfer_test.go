@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vexor/pkg/vecio"
+)
+
+func TestImportTokenOnly(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "rows.jsonl")
+	rows := `{"id":"a","tokens":[[1,2],[3,4]]}
+{"id":"b","vector":[1,2]}
+`
+	if err := os.WriteFile(in, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.snap")
+	if err := runImport([]string{"-in", in, "-out", out, "-quiet"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := vecio.LoadSnapshot(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Dimension() != 2 || s.Count() != 2 {
+		t.Fatalf("snapshot has %d vectors of dimension %d", s.Count(), s.Dimension())
+	}
+	if v, err := s.Get("a"); err != nil || len(v.Tokens) != 2 {
+		t.Errorf("token-only row: %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,222 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+const dim = 8
+
+// startNodes starts n loopback nodes and returns them with their addresses.
+func startNodes(t *testing.T, n int) ([]*Node, []*store.VectorStore, []string) {
+	t.Helper()
+	var (
+		nodes  []*Node
+		stores []*store.VectorStore
+		addrs  []string
+	)
+	for range n {
+		s := store.NewVectorStore(dim)
+		node := NewNode(s)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go node.Serve(ln)
+		t.Cleanup(func() { node.Close() })
+		nodes = append(nodes, node)
+		stores = append(stores, s)
+		addrs = append(addrs, ln.Addr().String())
+	}
+	return nodes, stores, addrs
+}
+
+func randomVector(rng *rand.Rand) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = rng.Float32()*2 - 1
+	}
+	return v
+}
+
+func TestScatterGatherMatchesLocal(t *testing.T) {
+	_, stores, addrs := startNodes(t, 3)
+	c := NewCoordinator(addrs)
+	defer c.Close()
+
+	rng := rand.New(rand.NewSource(1))
+	local := store.NewVectorStore(dim)
+	for i := range 1000 {
+		v := store.Vector{ID: fmt.Sprintf("vec-%d", i), Data: randomVector(rng)}
+		if err := c.Insert(v); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		local.Insert(v)
+	}
+	for _, s := range stores {
+		if s.Count() == 0 {
+			t.Fatal("a node received no vectors")
+		}
+	}
+	if n, err := c.Count(); err != nil || n != 1000 {
+		t.Fatalf("Count = %d, %v", n, err)
+	}
+
+	for range 20 {
+		q := randomVector(rng)
+		for _, cosine := range []bool{false, true} {
+			search, localSearch := c.Search, local.Search
+			if cosine {
+				search, localSearch = c.SearchCosine, local.SearchCosine
+			}
+			got, err := search(q, 10)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			want, _ := localSearch(q, 10)
+			if len(got) != len(want) {
+				t.Fatalf("got %d results, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].ID != want[i].ID {
+					t.Fatalf("result %d: got %s, want %s", i, got[i].ID, want[i].ID)
+				}
+			}
+		}
+	}
+
+	if err := c.Delete("vec-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := c.Get("vec-1"); err != store.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if v, err := c.Get("vec-2"); err != nil || v.ID != "vec-2" {
+		t.Fatalf("Get = %+v, %v", v, err)
+	}
+	if _, err := c.Search([]float32{1}, 1); !errors.Is(err, store.ErrDimensionMismatch) {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestNodeDistribution(t *testing.T) {
+	// With as many nodes as shards, routing must not correlate with the
+	// store's shard routing.
+	const nodes = store.DefaultNumShards
+	shardsUsed := make(map[int]map[int]bool)
+	counts := make([]int, nodes)
+	for i := range 20000 {
+		id := fmt.Sprintf("vec-%d", i)
+		n := nodeIndex(id, nodes)
+		counts[n]++
+		if shardsUsed[n] == nil {
+			shardsUsed[n] = make(map[int]bool)
+		}
+		shardsUsed[n][int(fnv32(id)%nodes)] = true
+	}
+	for n, c := range counts {
+		if c < 20000/nodes/2 {
+			t.Errorf("node %d got only %d IDs", n, c)
+		}
+		if len(shardsUsed[n]) < nodes/2 {
+			t.Errorf("node %d's IDs land in only %d shards", n, len(shardsUsed[n]))
+		}
+	}
+}
+
+func fnv32(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
+
+func TestPartialFailure(t *testing.T) {
+	nodes, _, addrs := startNodes(t, 3)
+	rng := rand.New(rand.NewSource(2))
+
+	loader := NewCoordinator(addrs)
+	defer loader.Close()
+	for i := range 300 {
+		loader.Insert(store.Vector{ID: fmt.Sprintf("vec-%d", i), Data: randomVector(rng)})
+	}
+	nodes[1].Close()
+
+	strict := NewCoordinator(addrs, WithTimeout(time.Second))
+	defer strict.Close()
+	if _, err := strict.Search(randomVector(rng), 5); err == nil {
+		t.Fatal("expected FailOnError search to fail with a node down")
+	}
+
+	lenient := NewCoordinator(addrs, WithFailurePolicy(BestEffort), WithTimeout(time.Second))
+	defer lenient.Close()
+	results, err := lenient.Search(randomVector(rng), 5)
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected *PartialError, got %v", err)
+	}
+	if _, ok := partial.Failed[1]; !ok || len(partial.Failed) != 1 {
+		t.Fatalf("unexpected failed nodes: %v", partial.Failed)
+	}
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results from surviving nodes, got %d", len(results))
+	}
+	for _, r := range results {
+		if nodeIndex(r.ID, 3) == 1 {
+			t.Fatalf("result %s came from the failed node", r.ID)
+		}
+	}
+
+	nodes[0].Close()
+	nodes[2].Close()
+	if _, err := lenient.Search(randomVector(rng), 5); err == nil || errors.As(err, &partial) {
+		t.Fatalf("expected failure with every node down, got %v", err)
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	_, _, addrs := startNodes(t, 2)
+	// A node that accepts calls and never answers them.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	addrs = append(addrs, ln.Addr().String())
+
+	c := NewCoordinator(addrs, WithFailurePolicy(BestEffort), WithTimeout(50*time.Millisecond))
+	defer c.Close()
+	rng := rand.New(rand.NewSource(3))
+	for i := range 100 {
+		id := fmt.Sprintf("vec-%d", i)
+		if nodeIndex(id, 3) != 2 {
+			c.Insert(store.Vector{ID: id, Data: randomVector(rng)})
+		}
+	}
+
+	results, err := c.Search(randomVector(rng), 5)
+	var partial *PartialError
+	if !errors.As(err, &partial) || !errors.Is(partial.Failed[2], ErrTimeout) || len(partial.Failed) != 1 {
+		t.Fatalf("expected node 2 to time out, got %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results from the answering nodes, got %d", len(results))
+	}
+}
@@ -0,0 +1,321 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+// FailurePolicy decides what a search does when some nodes fail.
+type FailurePolicy int
+
+const (
+	// FailOnError fails the whole search if any node fails.
+	FailOnError FailurePolicy = iota
+	// BestEffort merges results from the nodes that answered and reports
+	// the rest in a *PartialError. It still fails if every node fails.
+	BestEffort
+)
+
+// DefaultTimeout bounds each call to a node.
+const DefaultTimeout = 5 * time.Second
+
+// ErrTimeout is returned for a node call that exceeds the timeout.
+var ErrTimeout = errors.New("cluster: node call timed out")
+
+// PartialError reports the nodes that failed during a BestEffort search.
+// The results returned with it are the merged top-k of the other nodes.
+type PartialError struct {
+	// Failed maps node index to the error that node returned.
+	Failed map[int]error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("cluster: %d node(s) failed; results are partial", len(e.Failed))
+}
+
+// CoordinatorOption configures a Coordinator.
+type CoordinatorOption func(*Coordinator)
+
+// WithFailurePolicy sets how searches handle failed nodes.
+func WithFailurePolicy(p FailurePolicy) CoordinatorOption {
+	return func(c *Coordinator) { c.policy = p }
+}
+
+// WithTimeout sets the per-call timeout. Values <= 0 are ignored.
+func WithTimeout(d time.Duration) CoordinatorOption {
+	return func(c *Coordinator) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// Coordinator partitions vectors across nodes by ID and runs searches on
+// all of them. The node list, in order, defines the partitioning, so every
+// coordinator of a cluster must be given the same addresses.
+type Coordinator struct {
+	nodes   []*nodeClient
+	policy  FailurePolicy
+	timeout time.Duration
+}
+
+// NewCoordinator returns a coordinator over the nodes at addrs. Connections
+// are made on first use and re-made after failures.
+func NewCoordinator(addrs []string, opts ...CoordinatorOption) *Coordinator {
+	c := &Coordinator{timeout: DefaultTimeout}
+	for _, addr := range addrs {
+		c.nodes = append(c.nodes, &nodeClient{addr: addr})
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NumNodes returns the number of partitions.
+func (c *Coordinator) NumNodes() int {
+	return len(c.nodes)
+}
+
+// Close closes all node connections.
+func (c *Coordinator) Close() error {
+	for _, n := range c.nodes {
+		n.reset(nil)
+	}
+	return nil
+}
+
+// nodeIndex routes id to one of n nodes. It runs FNV-1a like the store's
+// shardIndex but scrambles the result before reducing it: taking h % n at
+// both levels would send each node only the IDs whose shard index is
+// congruent to the node's, leaving most of every node's shards empty.
+func nodeIndex(id string, n int) int {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(id); i++ {
+		h ^= uint64(id[i])
+		h *= prime64
+	}
+	// splitmix64 finalizer, then a multiply-shift range reduction.
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	hi, _ := bits.Mul64(h, uint64(n))
+	return int(hi)
+}
+
+func (c *Coordinator) nodeFor(id string) *nodeClient {
+	return c.nodes[nodeIndex(id, len(c.nodes))]
+}
+
+// Insert stores v on the node that owns its ID.
+func (c *Coordinator) Insert(v store.Vector) error {
+	if v.ID == "" {
+		return store.ErrEmptyID
+	}
+	return c.nodeFor(v.ID).call(c.timeout, "Insert", InsertArgs{Vector: v}, &Empty{})
+}
+
+// Delete removes id from the node that owns it.
+func (c *Coordinator) Delete(id string) error {
+	return c.nodeFor(id).call(c.timeout, "Delete", IDArgs{ID: id}, &Empty{})
+}
+
+// Get fetches id from the node that owns it.
+func (c *Coordinator) Get(id string) (store.Vector, error) {
+	return callNode[store.Vector](c.nodeFor(id), c.timeout, "Get", IDArgs{ID: id})
+}
+
+// Count returns the total number of vectors across all nodes. It fails if
+// any node fails, whatever the failure policy.
+func (c *Coordinator) Count() (int, error) {
+	counts := make([]int, len(c.nodes))
+	errs := c.scatter(func(i int, n *nodeClient) error {
+		var err error
+		counts[i], err = callNode[int](n, c.timeout, "Count", Empty{})
+		return err
+	})
+	total := 0
+	for i, err := range errs {
+		if err != nil {
+			return 0, fmt.Errorf("cluster: node %d: %w", i, err)
+		}
+		total += counts[i]
+	}
+	return total, nil
+}
+
+// Search finds the k nearest neighbors by Euclidean distance across all
+// nodes.
+func (c *Coordinator) Search(query []float32, k int) ([]store.SearchResult, error) {
+	return c.search(query, k, store.Euclidean)
+}
+
+// SearchCosine finds the k nearest neighbors by cosine distance across all
+// nodes.
+func (c *Coordinator) SearchCosine(query []float32, k int) ([]store.SearchResult, error) {
+	return c.search(query, k, store.Cosine)
+}
+
+// search asks every node for its own top k and merges them. Each node
+// holds a disjoint partition, so the merged top k is exact.
+func (c *Coordinator) search(query []float32, k int, metric store.Metric) ([]store.SearchResult, error) {
+	args := SearchArgs{Query: query, K: k, Metric: metric}
+	lists := make([][]store.SearchResult, len(c.nodes))
+	errs := c.scatter(func(i int, n *nodeClient) error {
+		var err error
+		lists[i], err = callNode[[]store.SearchResult](n, c.timeout, "Search", args)
+		return err
+	})
+
+	var (
+		partial *PartialError
+		ok      [][]store.SearchResult
+	)
+	for i, err := range errs {
+		if err == nil {
+			ok = append(ok, lists[i])
+			continue
+		}
+		// A node rejecting the query itself, such as for a dimension
+		// mismatch, would be rejected by every node.
+		if c.policy == FailOnError || isStoreError(err) {
+			return nil, fmt.Errorf("cluster: node %d: %w", i, err)
+		}
+		if partial == nil {
+			partial = &PartialError{Failed: make(map[int]error)}
+		}
+		partial.Failed[i] = err
+	}
+	if partial != nil && len(partial.Failed) == len(c.nodes) {
+		return nil, fmt.Errorf("cluster: all nodes failed: %w", errs[0])
+	}
+
+	results := store.MergeResults(make([]store.SearchResult, 0, max(k, 0)), k, ok...)
+	if partial != nil {
+		return results, partial
+	}
+	return results, nil
+}
+
+// scatter runs fn against every node concurrently and returns the errors
+// by node index.
+func (c *Coordinator) scatter(fn func(i int, n *nodeClient) error) []error {
+	errs := make([]error, len(c.nodes))
+	var wg sync.WaitGroup
+	for i, n := range c.nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, n)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// storeErrors are the store errors a node can return. net/rpc flattens
+// errors to strings, so they are matched by message and restored.
+var storeErrors = []error{
+	store.ErrDimensionMismatch,
+	store.ErrEmptyID,
+	store.ErrNotFound,
+}
+
+func isStoreError(err error) bool {
+	for _, e := range storeErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeClient is a lazily dialed connection to one node.
+type nodeClient struct {
+	addr string
+
+	mu     sync.Mutex
+	client *rpc.Client
+}
+
+func (n *nodeClient) get() (*rpc.Client, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.client != nil {
+		return n.client, nil
+	}
+	client, err := rpc.Dial("tcp", n.addr)
+	if err != nil {
+		return nil, err
+	}
+	n.client = client
+	return client, nil
+}
+
+// reset closes client if it is still the current connection, so the next
+// call redials. A nil client closes whatever is current.
+func (n *nodeClient) reset(client *rpc.Client) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.client != nil && (client == nil || n.client == client) {
+		n.client.Close()
+		n.client = nil
+	}
+}
+
+// call runs method on the node, decoding its reply into reply. After a
+// timeout net/rpc may still be decoding into reply, so reply must not be
+// shared; callNode gives each call its own.
+func (n *nodeClient) call(timeout time.Duration, method string, args, reply any) error {
+	client, err := n.get()
+	if err != nil {
+		return err
+	}
+	call := client.Go(serviceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-call.Done:
+	case <-timer.C:
+		// The connection may be wedged; drop it so the next call redials.
+		n.reset(client)
+		return ErrTimeout
+	}
+
+	var serverErr rpc.ServerError
+	if errors.As(call.Error, &serverErr) {
+		for _, e := range storeErrors {
+			if string(serverErr) == e.Error() {
+				return e
+			}
+		}
+		return serverErr
+	}
+	if call.Error != nil {
+		n.reset(client)
+	}
+	return call.Error
+}
+
+// callNode runs method on n and returns its reply, decoded into a value of
+// the call's own and handed back only if the call succeeded.
+func callNode[R any](n *nodeClient, timeout time.Duration, method string, args any) (R, error) {
+	reply := new(R)
+	if err := n.call(timeout, method, args, reply); err != nil {
+		var zero R
+		return zero, err
+	}
+	return *reply, nil
+}
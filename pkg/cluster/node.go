@@ -0,0 +1,139 @@
+// Package cluster partitions vectors across several vexor nodes and answers
+// searches by scatter-gather.
+//
+// Each node serves one store over net/rpc. A Coordinator routes every ID to
+// a node by hash, fans searches out to all nodes and merges their top-k
+// lists into the global top-k.
+package cluster
+
+import (
+	"net"
+	"net/rpc"
+	"sync"
+
+	"vexor/pkg/store"
+)
+
+// serviceName is the net/rpc service a Node registers.
+const serviceName = "Vexor"
+
+// InsertArgs, SearchArgs and friends are the net/rpc request types. They
+// are exported only because net/rpc requires it.
+type (
+	InsertArgs struct{ Vector store.Vector }
+	IDArgs     struct{ ID string }
+	SearchArgs struct {
+		Query  []float32
+		K      int
+		Metric store.Metric
+	}
+	Empty struct{}
+)
+
+// Node serves a local store to coordinators.
+type Node struct {
+	srv *rpc.Server
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewNode returns a Node serving s.
+func NewNode(s *store.VectorStore) *Node {
+	srv := rpc.NewServer()
+	// RegisterName only fails for invalid receivers, which nodeService is not.
+	if err := srv.RegisterName(serviceName, &nodeService{s: s}); err != nil {
+		panic(err)
+	}
+	return &Node{srv: srv, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve accepts coordinator connections on ln until Close is called.
+func (n *Node) Serve(ln net.Listener) error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	n.ln = ln
+	n.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			n.mu.Lock()
+			closed := n.closed
+			n.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		n.mu.Lock()
+		if n.closed {
+			n.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		n.conns[conn] = struct{}{}
+		n.mu.Unlock()
+		go func() {
+			n.srv.ServeConn(conn)
+			n.mu.Lock()
+			delete(n.conns, conn)
+			n.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops the node and drops its connections.
+func (n *Node) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	var err error
+	if n.ln != nil {
+		err = n.ln.Close()
+	}
+	for conn := range n.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// nodeService exposes a store's operations over net/rpc.
+type nodeService struct {
+	s *store.VectorStore
+}
+
+func (ns *nodeService) Insert(args InsertArgs, _ *Empty) error {
+	return ns.s.Insert(args.Vector)
+}
+
+func (ns *nodeService) Delete(args IDArgs, _ *Empty) error {
+	return ns.s.Delete(args.ID)
+}
+
+func (ns *nodeService) Get(args IDArgs, reply *store.Vector) error {
+	v, err := ns.s.Get(args.ID)
+	*reply = v
+	return err
+}
+
+func (ns *nodeService) Count(_ Empty, reply *int) error {
+	*reply = ns.s.Count()
+	return nil
+}
+
+func (ns *nodeService) Search(args SearchArgs, reply *[]store.SearchResult) error {
+	var err error
+	if args.Metric == store.Cosine {
+		*reply, err = ns.s.SearchCosine(args.Query, args.K)
+	} else {
+		*reply, err = ns.s.Search(args.Query, args.K)
+	}
+	return err
+}
@@ -61,3 +61,24 @@ func CosineSimilarity(a, b []float32) float32 {
 func CosineDistance(a, b []float32) float32 {
 	return 1 - CosineSimilarity(a, b)
 }
+
+// SparseDotProduct computes the dot product of two sparse vectors given as
+// index/value pairs. Indices must be strictly increasing; dimensions present
+// in only one vector contribute nothing.
+func SparseDotProduct(aIdx []uint32, aVal []float32, bIdx []uint32, bVal []float32) float32 {
+	var sum float32
+	i, j := 0, 0
+	for i < len(aIdx) && j < len(bIdx) {
+		switch {
+		case aIdx[i] < bIdx[j]:
+			i++
+		case aIdx[i] > bIdx[j]:
+			j++
+		default:
+			sum += aVal[i] * bVal[j]
+			i++
+			j++
+		}
+	}
+	return sum
+}
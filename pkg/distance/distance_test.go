@@ -136,6 +136,17 @@ func TestZeroVectors(t *testing.T) {
 	}
 }
 
+func TestSparseDotProduct(t *testing.T) {
+	aIdx, aVal := []uint32{1, 4, 7, 100}, []float32{1, 2, 3, 4}
+	bIdx, bVal := []uint32{0, 4, 100, 200}, []float32{9, 5, 6, 9}
+	if got, want := SparseDotProduct(aIdx, aVal, bIdx, bVal), float32(2*5+4*6); got != want {
+		t.Errorf("SparseDotProduct=%v, want %v", got, want)
+	}
+	if got := SparseDotProduct(aIdx, aVal, nil, nil); got != 0 {
+		t.Errorf("SparseDotProduct with empty vector=%v, want 0", got)
+	}
+}
+
 func relError(got, want float32) float64 {
 	if want == 0 {
 		return float64(math.Abs(float64(got)))
@@ -0,0 +1,197 @@
+// Package eval measures the quality of approximate search against exact
+// brute-force ground truth.
+//
+// Ground truth comes from VectorStore.Search, which scans every vector. An
+// index under test is anything with the same Search signature; Evaluate
+// scores its answers with recall@k, MRR and distance ratio and times it,
+// and Sweep repeats that across a grid of index parameters to trace a
+// recall-vs-QPS curve.
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+// ErrQueryCount is returned when the number of ground truth lists does not
+// match the number of queries.
+var ErrQueryCount = errors.New("eval: ground truth and queries differ in length")
+
+// Searcher is an index under evaluation.
+type Searcher interface {
+	Search(query []float32, k int) ([]store.SearchResult, error)
+}
+
+// GroundTruth returns the exact k nearest neighbors of each query.
+func GroundTruth(s *store.VectorStore, queries [][]float32, k int) ([][]store.SearchResult, error) {
+	truth := make([][]store.SearchResult, len(queries))
+	for i, q := range queries {
+		res, err := s.Search(q, k)
+		if err != nil {
+			return nil, fmt.Errorf("eval: query %d: %w", i, err)
+		}
+		truth[i] = res
+	}
+	return truth, nil
+}
+
+// Recall returns the fraction of the true top k that appears in the
+// first k results.
+func Recall(results, truth []store.SearchResult, k int) float64 {
+	k = min(k, len(truth))
+	if k == 0 {
+		return 1
+	}
+	want := make(map[string]struct{}, k)
+	for _, r := range truth[:k] {
+		want[r.ID] = struct{}{}
+	}
+	hits := 0
+	for _, r := range results[:min(k, len(results))] {
+		if _, ok := want[r.ID]; ok {
+			hits++
+		}
+	}
+	return float64(hits) / float64(k)
+}
+
+// ReciprocalRank returns 1/rank of the true nearest neighbor among results,
+// or 0 if it is missing.
+func ReciprocalRank(results, truth []store.SearchResult) float64 {
+	if len(truth) == 0 {
+		return 1
+	}
+	for i, r := range results {
+		if r.ID == truth[0].ID {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// DistanceRatio returns the mean ratio of each result's distance to the
+// true distance at the same rank. It is 1 for exact results and grows as
+// the index returns farther neighbors. Ranks where the true distance is 0
+// count as 1 if the result's distance is 0 too and are skipped otherwise.
+func DistanceRatio(results, truth []store.SearchResult) float64 {
+	n := min(len(results), len(truth))
+	sum, count := 0.0, 0
+	for i := range n {
+		got, want := float64(results[i].Distance), float64(truth[i].Distance)
+		switch {
+		case want != 0:
+			sum += got / want
+		case got == 0:
+			sum++
+		default:
+			continue
+		}
+		count++
+	}
+	if count == 0 {
+		return 1
+	}
+	return sum / float64(count)
+}
+
+// Report summarizes one evaluation run.
+type Report struct {
+	Params Params
+	K      int
+
+	Recall        float64 // mean recall@k
+	MRR           float64 // mean reciprocal rank of the true nearest neighbor
+	DistanceRatio float64 // mean distance ratio
+
+	Queries int
+	Elapsed time.Duration
+	QPS     float64
+}
+
+// Evaluate runs every query against s, one at a time, and scores the
+// results against truth.
+func Evaluate(s Searcher, queries [][]float32, truth [][]store.SearchResult, k int) (Report, error) {
+	if len(truth) != len(queries) {
+		return Report{}, ErrQueryCount
+	}
+	results := make([][]store.SearchResult, len(queries))
+	start := time.Now()
+	for i, q := range queries {
+		res, err := s.Search(q, k)
+		if err != nil {
+			return Report{}, fmt.Errorf("eval: query %d: %w", i, err)
+		}
+		results[i] = res
+	}
+	elapsed := time.Since(start)
+
+	rep := Report{K: k, Queries: len(queries), Elapsed: elapsed}
+	for i := range queries {
+		rep.Recall += Recall(results[i], truth[i], k)
+		rep.MRR += ReciprocalRank(results[i], truth[i])
+		rep.DistanceRatio += DistanceRatio(results[i], truth[i])
+	}
+	if n := float64(len(queries)); n > 0 {
+		rep.Recall /= n
+		rep.MRR /= n
+		rep.DistanceRatio /= n
+		rep.QPS = n / elapsed.Seconds()
+	}
+	return rep, nil
+}
+
+// Params are the common approximate index knobs a sweep varies. An index
+// uses the ones that apply to it and ignores the rest.
+type Params struct {
+	Ef          int // graph search beam width
+	NProbe      int // inverted lists probed per query
+	RerankDepth int // candidates rescored exactly before returning k
+}
+
+func (p Params) String() string {
+	return fmt.Sprintf("ef=%d nprobe=%d rerank=%d", p.Ef, p.NProbe, p.RerankDepth)
+}
+
+// Grid returns every combination of the given values. An empty list
+// contributes a single zero value.
+func Grid(ef, nprobe, rerank []int) []Params {
+	orZero := func(v []int) []int {
+		if len(v) == 0 {
+			return []int{0}
+		}
+		return v
+	}
+	var grid []Params
+	for _, e := range orZero(ef) {
+		for _, n := range orZero(nprobe) {
+			for _, r := range orZero(rerank) {
+				grid = append(grid, Params{Ef: e, NProbe: n, RerankDepth: r})
+			}
+		}
+	}
+	return grid
+}
+
+// Builder returns the index to evaluate for one parameter setting.
+type Builder func(Params) (Searcher, error)
+
+// Sweep evaluates the index build returns for each setting in grid.
+func Sweep(build Builder, grid []Params, queries [][]float32, truth [][]store.SearchResult, k int) ([]Report, error) {
+	reports := make([]Report, 0, len(grid))
+	for _, p := range grid {
+		s, err := build(p)
+		if err != nil {
+			return nil, fmt.Errorf("eval: build %v: %w", p, err)
+		}
+		rep, err := Evaluate(s, queries, truth, k)
+		if err != nil {
+			return nil, err
+		}
+		rep.Params = p
+		reports = append(reports, rep)
+	}
+	return reports, nil
+}
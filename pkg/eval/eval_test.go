@@ -0,0 +1,103 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"vexor/pkg/store"
+)
+
+func results(ids ...string) []store.SearchResult {
+	out := make([]store.SearchResult, len(ids))
+	for i, id := range ids {
+		out[i] = store.SearchResult{ID: id, Distance: float32(i + 1)}
+	}
+	return out
+}
+
+func TestMetrics(t *testing.T) {
+	truth := results("a", "b", "c", "d")
+
+	if r := Recall(truth, truth, 4); r != 1 {
+		t.Errorf("exact recall = %v", r)
+	}
+	if r := Recall(results("a", "x", "c", "y"), truth, 4); r != 0.5 {
+		t.Errorf("recall = %v, want 0.5", r)
+	}
+	if r := Recall(results("b"), truth, 2); r != 0.5 {
+		t.Errorf("short result recall = %v, want 0.5", r)
+	}
+
+	if rr := ReciprocalRank(results("x", "y", "a"), truth); math.Abs(rr-1.0/3) > 1e-9 {
+		t.Errorf("reciprocal rank = %v, want 1/3", rr)
+	}
+	if rr := ReciprocalRank(results("x"), truth); rr != 0 {
+		t.Errorf("missing nearest neighbor rank = %v, want 0", rr)
+	}
+
+	far := []store.SearchResult{{ID: "b", Distance: 2}, {ID: "d", Distance: 4}}
+	if dr := DistanceRatio(far, truth); dr != 2 {
+		t.Errorf("distance ratio = %v, want 2", dr)
+	}
+}
+
+// skipFirst drops the nearest result, standing in for a lossy index.
+type skipFirst struct{ s *store.VectorStore }
+
+func (sf skipFirst) Search(q []float32, k int) ([]store.SearchResult, error) {
+	res, err := sf.s.Search(q, k+1)
+	if err != nil || len(res) == 0 {
+		return res, err
+	}
+	return res[1:], nil
+}
+
+func TestSweep(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := store.NewVectorStore(4)
+	for i := range 500 {
+		s.Insert(store.Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{rng.Float32(), rng.Float32(), rng.Float32(), rng.Float32()}})
+	}
+	queries := make([][]float32, 20)
+	for i := range queries {
+		queries[i] = []float32{rng.Float32(), rng.Float32(), rng.Float32(), rng.Float32()}
+	}
+	truth, err := GroundTruth(s, queries, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grid := Grid([]int{0, 1}, nil, nil)
+	if len(grid) != 2 {
+		t.Fatalf("expected 2 settings, got %d", len(grid))
+	}
+	reports, err := Sweep(func(p Params) (Searcher, error) {
+		if p.Ef == 0 {
+			return s, nil
+		}
+		return skipFirst{s}, nil
+	}, grid, queries, truth, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exact, lossy := reports[0], reports[1]
+	if exact.Recall != 1 || exact.MRR != 1 || exact.DistanceRatio != 1 {
+		t.Errorf("exact search scored %+v", exact)
+	}
+	if exact.QPS <= 0 || exact.Queries != 20 {
+		t.Errorf("exact search timing not recorded: %+v", exact)
+	}
+	if math.Abs(lossy.Recall-0.9) > 1e-9 || lossy.MRR != 0 || lossy.DistanceRatio <= 1 {
+		t.Errorf("lossy search scored %+v", lossy)
+	}
+	if lossy.Params.Ef != 1 {
+		t.Errorf("report lost its params: %+v", lossy.Params)
+	}
+
+	if _, err := Evaluate(s, queries, truth[:1], 10); err != ErrQueryCount {
+		t.Errorf("expected ErrQueryCount, got %v", err)
+	}
+}
@@ -0,0 +1,840 @@
+// Package raft replicates a VectorStore with the Raft consensus algorithm.
+//
+// Insert and Delete on a Node are appended to the replicated log and return
+// once a majority has stored the entry and the local store has applied it.
+// Every node applies committed entries in log order to its own store, and
+// the log is compacted into snapshots of the store's vectors.
+//
+// A node saves its term, its vote and its log to its Storage before it
+// answers the RPC or acknowledges the write that changed them, so a node
+// restarted on the same Storage keeps every vote it cast and every entry it
+// helped commit. A node started on empty storage joins as a new member and
+// catches up from the leader's snapshot; it must not take the ID of a
+// member whose storage was lost, or it could vote twice in one term.
+//
+// Searches and lookups read the local store without a round through the
+// log, so they may trail the latest committed writes, most of all on
+// followers.
+package raft
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+var (
+	ErrNotLeader = errors.New("raft: node is not the leader")
+	ErrStopped   = errors.New("raft: node stopped")
+	// ErrLeadershipLost is returned when the proposing node lost leadership
+	// before its entry was applied. The write may or may not have committed.
+	ErrLeadershipLost = errors.New("raft: leadership lost before entry was applied")
+)
+
+// Op is the operation a log entry applies to the store.
+type Op uint8
+
+const (
+	OpNoop Op = iota // appended by each new leader to commit earlier terms
+	OpInsert
+	OpDelete
+)
+
+// Entry is one replicated log entry.
+type Entry struct {
+	Index  uint64
+	Term   uint64
+	Op     Op
+	Vector store.Vector // for deletes only the ID is set
+}
+
+const (
+	DefaultHeartbeatInterval = 50 * time.Millisecond
+	DefaultElectionTimeout   = 300 * time.Millisecond
+	DefaultSnapshotThreshold = 10000
+
+	// maxAppendEntries caps the entries sent in one AppendEntries call.
+	maxAppendEntries = 512
+)
+
+// Config configures a Node.
+type Config struct {
+	// ID names this node; Peers lists every member, this node included.
+	ID    string
+	Peers []string
+
+	Dimension    int
+	StoreOptions []store.Option
+
+	Transport Transport
+	// Storage holds the node's term, vote, log and snapshot. A node built
+	// on the Storage of one that stopped resumes where it left off. If nil,
+	// the node uses a new MemoryStorage.
+	Storage Storage
+
+	// HeartbeatInterval is how often the leader contacts idle followers.
+	HeartbeatInterval time.Duration
+	// ElectionTimeout is the minimum time without a leader before a node
+	// starts an election; each wait is randomized up to twice this.
+	ElectionTimeout time.Duration
+	// SnapshotThreshold is the number of applied entries after which the
+	// log is compacted into a snapshot.
+	SnapshotThreshold int
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// waiter is a proposer waiting for its entry to be applied.
+type waiter struct {
+	term uint64
+	ch   chan error
+}
+
+// Node is one member of a Raft cluster replicating a VectorStore.
+type Node struct {
+	cfg Config
+
+	// sm is the state machine. It is replaced when a snapshot is installed.
+	sm atomic.Pointer[store.VectorStore]
+
+	mu        sync.Mutex
+	applyCond *sync.Cond
+	rng       *rand.Rand
+
+	role     role
+	term     uint64
+	votedFor string
+	leaderID string
+
+	// log[0] is a placeholder holding the index and term of the last entry
+	// in the snapshot, so log[i] has index log[0].Index+i.
+	log      []Entry
+	snapshot []store.Vector
+
+	commitIndex    uint64
+	lastApplied    uint64
+	installPending bool
+
+	nextIndex  map[string]uint64
+	matchIndex map[string]uint64
+	triggers   map[string]chan struct{}
+
+	electionDeadline time.Time
+	waiters          map[uint64]waiter
+
+	stopped bool
+	stop    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewNode returns a stopped node, restored from cfg.Storage. Call Start to
+// join the cluster.
+func NewNode(cfg Config) (*Node, error) {
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+	if cfg.ElectionTimeout <= 0 {
+		cfg.ElectionTimeout = DefaultElectionTimeout
+	}
+	if cfg.SnapshotThreshold <= 0 {
+		cfg.SnapshotThreshold = DefaultSnapshotThreshold
+	}
+	if !slices.Contains(cfg.Peers, cfg.ID) {
+		cfg.Peers = append(slices.Clone(cfg.Peers), cfg.ID)
+	}
+	if cfg.Storage == nil {
+		cfg.Storage = NewMemoryStorage()
+	}
+	state, snap, entries, err := cfg.Storage.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		cfg:      cfg,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		term:     state.Term,
+		votedFor: state.VotedFor,
+		log:      append([]Entry{{Index: snap.LastIndex, Term: snap.LastTerm}}, entries...),
+		snapshot: snap.Vectors,
+		// The snapshot holds only committed entries; the applier loads it
+		// into the store before anything else.
+		commitIndex: snap.LastIndex,
+		waiters:     make(map[uint64]waiter),
+		stop:        make(chan struct{}),
+	}
+	n.applyCond = sync.NewCond(&n.mu)
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	n.sm.Store(store.NewVectorStore(cfg.Dimension, cfg.StoreOptions...))
+	return n, nil
+}
+
+// ID returns the node's ID.
+func (n *Node) ID() string {
+	return n.cfg.ID
+}
+
+// Start begins election timing and applying committed entries.
+func (n *Node) Start() {
+	n.mu.Lock()
+	n.resetElectionTimer()
+	n.mu.Unlock()
+
+	n.wg.Add(2)
+	go n.runTimer()
+	go n.runApplier()
+}
+
+// Stop shuts the node down and waits for its goroutines to exit. Pending
+// proposals fail with ErrStopped.
+func (n *Node) Stop() {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		return
+	}
+	n.stopped = true
+	for idx, w := range n.waiters {
+		w.ch <- ErrStopped
+		delete(n.waiters, idx)
+	}
+	n.applyCond.Broadcast()
+	n.mu.Unlock()
+
+	close(n.stop)
+	n.cancel()
+	n.wg.Wait()
+}
+
+// State returns the current term and whether this node is the leader.
+func (n *Node) State() (term uint64, isLeader bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.term, n.role == leader
+}
+
+// Leader returns the ID of the leader this node last heard from, or "" if
+// it knows of none.
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leaderID
+}
+
+// Insert replicates an insert or upsert of v and waits until it is applied
+// locally. It fails with ErrNotLeader on followers.
+func (n *Node) Insert(ctx context.Context, v store.Vector) error {
+	sm := n.sm.Load()
+	if err := sm.Validate(v); err != nil {
+		return err
+	}
+	// The entry carries an absolute expiry so that every replica, and
+	// every snapshot, agrees on it whenever the entry is applied.
+	if ttl := sm.DefaultTTL(); ttl > 0 && v.ExpiresAt.IsZero() {
+		v.ExpiresAt = time.Now().Add(ttl)
+	}
+	return n.propose(ctx, OpInsert, v)
+}
+
+// Delete replicates a delete of id and waits until it is applied locally.
+// It returns store.ErrNotFound if id did not exist when the entry applied.
+func (n *Node) Delete(ctx context.Context, id string) error {
+	return n.propose(ctx, OpDelete, store.Vector{ID: id})
+}
+
+// Count returns the number of vectors in the local store.
+func (n *Node) Count() int {
+	return n.sm.Load().Count()
+}
+
+// Get returns a copy of the vector stored under id in the local store.
+func (n *Node) Get(id string) (store.Vector, error) {
+	return n.sm.Load().Get(id)
+}
+
+// Contains reports whether id is in the local store.
+func (n *Node) Contains(id string) bool {
+	return n.sm.Load().Contains(id)
+}
+
+// Search finds the k nearest neighbors in the local store by Euclidean
+// distance.
+func (n *Node) Search(query []float32, k int) ([]store.SearchResult, error) {
+	return n.sm.Load().Search(query, k)
+}
+
+// SearchCosine finds the k nearest neighbors in the local store by cosine
+// distance.
+func (n *Node) SearchCosine(query []float32, k int) ([]store.SearchResult, error) {
+	return n.sm.Load().SearchCosine(query, k)
+}
+
+func (n *Node) propose(ctx context.Context, op Op, v store.Vector) error {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		return ErrStopped
+	}
+	if n.role != leader {
+		n.mu.Unlock()
+		return ErrNotLeader
+	}
+	v = v.Clone()
+	idx, err := n.appendEntry(op, v)
+	if err != nil {
+		n.mu.Unlock()
+		return err
+	}
+	ch := make(chan error, 1)
+	n.waiters[idx] = waiter{term: n.term, ch: ch}
+	n.mu.Unlock()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		// The entry stays in the log and may still commit.
+		n.mu.Lock()
+		if w, ok := n.waiters[idx]; ok && w.ch == ch {
+			delete(n.waiters, idx)
+		}
+		n.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// appendEntry saves an entry to the leader's log and starts replicating
+// it. Caller holds mu.
+func (n *Node) appendEntry(op Op, v store.Vector) (uint64, error) {
+	idx := n.lastIndex() + 1
+	e := Entry{Index: idx, Term: n.term, Op: op, Vector: v}
+	if err := n.cfg.Storage.Append([]Entry{e}); err != nil {
+		return 0, err
+	}
+	n.log = append(n.log, e)
+	n.matchIndex[n.cfg.ID] = idx
+	for _, trigger := range n.triggers {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+	n.advanceCommit()
+	return idx, nil
+}
+
+func (n *Node) snapIndex() uint64 { return n.log[0].Index }
+func (n *Node) lastIndex() uint64 { return n.log[len(n.log)-1].Index }
+func (n *Node) lastTerm() uint64  { return n.log[len(n.log)-1].Term }
+
+// termAt returns the term of the entry at idx, which must lie between the
+// snapshot index and the last index.
+func (n *Node) termAt(idx uint64) uint64 {
+	return n.log[idx-n.snapIndex()].Term
+}
+
+func (n *Node) majority() int {
+	return len(n.cfg.Peers)/2 + 1
+}
+
+func (n *Node) resetElectionTimer() {
+	timeout := n.cfg.ElectionTimeout + time.Duration(n.rng.Int63n(int64(n.cfg.ElectionTimeout)))
+	n.electionDeadline = time.Now().Add(timeout)
+}
+
+// stepDown makes the node a follower of term, saving term first if it is
+// newer. If that fails the node stays in its own term, still as a
+// follower. Caller holds mu.
+func (n *Node) stepDown(term uint64) error {
+	if term > n.term {
+		if err := n.cfg.Storage.SetHardState(HardState{Term: term}); err != nil {
+			n.role = follower
+			n.triggers = nil
+			return err
+		}
+		n.term = term
+		n.votedFor = ""
+		n.leaderID = ""
+	}
+	n.role = follower
+	n.triggers = nil
+	return nil
+}
+
+func (n *Node) runTimer() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.cfg.ElectionTimeout / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			n.maybeStartElection()
+		}
+	}
+}
+
+func (n *Node) maybeStartElection() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopped || n.role == leader || time.Now().Before(n.electionDeadline) {
+		return
+	}
+
+	n.resetElectionTimer()
+	if err := n.cfg.Storage.SetHardState(HardState{Term: n.term + 1, VotedFor: n.cfg.ID}); err != nil {
+		return
+	}
+	n.role = candidate
+	n.term++
+	n.votedFor = n.cfg.ID
+	n.leaderID = ""
+
+	term := n.term
+	args := RequestVoteArgs{
+		Term:         term,
+		CandidateID:  n.cfg.ID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+	}
+	votes := 1
+	if votes >= n.majority() {
+		n.becomeLeader()
+		return
+	}
+	for _, peer := range n.cfg.Peers {
+		if peer == n.cfg.ID {
+			continue
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(n.ctx, n.cfg.ElectionTimeout)
+			defer cancel()
+			reply, err := n.cfg.Transport.RequestVote(ctx, peer, args)
+			if err != nil {
+				return
+			}
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if reply.Term > n.term {
+				n.stepDown(reply.Term)
+				return
+			}
+			if n.stopped || n.role != candidate || n.term != term || !reply.VoteGranted {
+				return
+			}
+			votes++
+			if votes >= n.majority() {
+				n.becomeLeader()
+			}
+		}()
+	}
+}
+
+// becomeLeader takes over the current term. Caller holds mu.
+func (n *Node) becomeLeader() {
+	n.role = leader
+	n.leaderID = n.cfg.ID
+	n.nextIndex = make(map[string]uint64)
+	n.matchIndex = make(map[string]uint64)
+	n.triggers = make(map[string]chan struct{})
+	for _, peer := range n.cfg.Peers {
+		n.nextIndex[peer] = n.lastIndex() + 1
+		n.matchIndex[peer] = 0
+		if peer != n.cfg.ID {
+			n.triggers[peer] = make(chan struct{}, 1)
+		}
+	}
+	for peer, trigger := range n.triggers {
+		n.wg.Add(1)
+		go n.replicate(peer, n.term, trigger)
+	}
+	// A leader may only count replicas for entries of its own term, so a
+	// no-op lets entries from earlier terms commit without waiting for a
+	// client write.
+	if _, err := n.appendEntry(OpNoop, store.Vector{}); err != nil {
+		n.stepDown(n.term)
+	}
+}
+
+// replicate keeps one follower up to date for as long as this node leads
+// term.
+func (n *Node) replicate(peer string, term uint64, trigger chan struct{}) {
+	defer n.wg.Done()
+	heartbeat := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		more, ok := n.sendTo(peer, term)
+		if !ok {
+			return
+		}
+		if more {
+			continue
+		}
+		select {
+		case <-n.stop:
+			return
+		case <-trigger:
+		case <-heartbeat.C:
+		}
+	}
+}
+
+// sendTo makes one AppendEntries or InstallSnapshot call to peer. It
+// reports whether the peer still lacks entries and whether this node still
+// leads term.
+func (n *Node) sendTo(peer string, term uint64) (more, ok bool) {
+	n.mu.Lock()
+	if n.stopped || n.role != leader || n.term != term {
+		n.mu.Unlock()
+		return false, false
+	}
+	next := n.nextIndex[peer]
+	if next <= n.snapIndex() {
+		args := InstallSnapshotArgs{
+			Term:      term,
+			LeaderID:  n.cfg.ID,
+			LastIndex: n.snapIndex(),
+			LastTerm:  n.log[0].Term,
+			Vectors:   n.snapshot,
+		}
+		n.mu.Unlock()
+		return n.sendSnapshot(peer, term, args)
+	}
+
+	prev := next - 1
+	end := min(n.lastIndex(), prev+maxAppendEntries)
+	args := AppendEntriesArgs{
+		Term:         term,
+		LeaderID:     n.cfg.ID,
+		PrevLogIndex: prev,
+		PrevLogTerm:  n.termAt(prev),
+		Entries:      slices.Clone(n.log[next-n.snapIndex() : end-n.snapIndex()+1]),
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.cfg.ElectionTimeout)
+	reply, err := n.cfg.Transport.AppendEntries(ctx, peer, args)
+	cancel()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		return false, true
+	}
+	if reply.Term > n.term {
+		n.stepDown(reply.Term)
+		return false, false
+	}
+	if n.role != leader || n.term != term {
+		return false, false
+	}
+	if reply.Success {
+		match := prev + uint64(len(args.Entries))
+		if match > n.matchIndex[peer] {
+			n.matchIndex[peer] = match
+			n.advanceCommit()
+		}
+		n.nextIndex[peer] = max(n.nextIndex[peer], match+1)
+	} else {
+		n.nextIndex[peer] = min(max(reply.ConflictIndex, 1), n.lastIndex()+1)
+	}
+	return n.nextIndex[peer] <= n.lastIndex(), true
+}
+
+func (n *Node) sendSnapshot(peer string, term uint64, args InstallSnapshotArgs) (more, ok bool) {
+	ctx, cancel := context.WithTimeout(n.ctx, n.cfg.ElectionTimeout)
+	reply, err := n.cfg.Transport.InstallSnapshot(ctx, peer, args)
+	cancel()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		return false, true
+	}
+	if reply.Term > n.term {
+		n.stepDown(reply.Term)
+		return false, false
+	}
+	if n.role != leader || n.term != term {
+		return false, false
+	}
+	n.matchIndex[peer] = max(n.matchIndex[peer], args.LastIndex)
+	n.nextIndex[peer] = max(n.nextIndex[peer], args.LastIndex+1)
+	n.advanceCommit()
+	return n.nextIndex[peer] <= n.lastIndex(), true
+}
+
+// advanceCommit commits the newest entry of the current term stored on a
+// majority. Caller holds mu.
+func (n *Node) advanceCommit() {
+	for idx := n.lastIndex(); idx > n.commitIndex && idx > n.snapIndex(); idx-- {
+		if n.termAt(idx) != n.term {
+			return
+		}
+		count := 0
+		for _, match := range n.matchIndex {
+			if match >= idx {
+				count++
+			}
+		}
+		if count >= n.majority() {
+			n.commitIndex = idx
+			n.applyCond.Broadcast()
+			return
+		}
+	}
+}
+
+// HandleRequestVote answers a candidate's vote request.
+func (n *Node) HandleRequestVote(args RequestVoteArgs) RequestVoteReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if args.Term > n.term {
+		if err := n.stepDown(args.Term); err != nil {
+			return RequestVoteReply{Term: n.term}
+		}
+	}
+	reply := RequestVoteReply{Term: n.term}
+	if args.Term < n.term {
+		return reply
+	}
+	upToDate := args.LastLogTerm > n.lastTerm() ||
+		args.LastLogTerm == n.lastTerm() && args.LastLogIndex >= n.lastIndex()
+	if (n.votedFor == "" || n.votedFor == args.CandidateID) && upToDate {
+		if n.votedFor == "" {
+			if err := n.cfg.Storage.SetHardState(HardState{Term: n.term, VotedFor: args.CandidateID}); err != nil {
+				return reply
+			}
+		}
+		n.votedFor = args.CandidateID
+		n.resetElectionTimer()
+		reply.VoteGranted = true
+	}
+	return reply
+}
+
+// HandleAppendEntries accepts entries or a heartbeat from the leader.
+func (n *Node) HandleAppendEntries(args AppendEntriesArgs) AppendEntriesReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if args.Term < n.term {
+		return AppendEntriesReply{Term: n.term}
+	}
+	if err := n.stepDown(args.Term); err != nil {
+		return AppendEntriesReply{Term: n.term, ConflictIndex: args.PrevLogIndex + 1}
+	}
+	n.leaderID = args.LeaderID
+	n.resetElectionTimer()
+	reply := AppendEntriesReply{Term: n.term}
+
+	prev, prevTerm, entries := args.PrevLogIndex, args.PrevLogTerm, args.Entries
+	if snap := n.snapIndex(); prev < snap {
+		// Everything up to the snapshot is committed and so already
+		// matches the leader.
+		skip := snap - prev
+		if skip >= uint64(len(entries)) {
+			reply.Success = true
+			return reply
+		}
+		entries = entries[skip:]
+		prev, prevTerm = snap, n.log[0].Term
+	}
+	if prev > n.lastIndex() {
+		reply.ConflictIndex = n.lastIndex() + 1
+		return reply
+	}
+	if t := n.termAt(prev); t != prevTerm {
+		// Skip back over the whole conflicting term in one round trip.
+		idx := prev
+		for idx > n.snapIndex()+1 && n.termAt(idx-1) == t {
+			idx--
+		}
+		reply.ConflictIndex = idx
+		return reply
+	}
+
+	for i, e := range entries {
+		if e.Index <= n.lastIndex() && n.termAt(e.Index) == e.Term {
+			continue
+		}
+		// Replace the log from the first new or conflicting entry on.
+		if err := n.cfg.Storage.Append(entries[i:]); err != nil {
+			reply.ConflictIndex = e.Index
+			return reply
+		}
+		n.log = append(n.log[:e.Index-n.snapIndex()], entries[i:]...)
+		break
+	}
+
+	// Only entries known to match the leader's log may be committed.
+	if commit := min(args.LeaderCommit, prev+uint64(len(entries))); commit > n.commitIndex {
+		n.commitIndex = commit
+		n.applyCond.Broadcast()
+	}
+	reply.Success = true
+	return reply
+}
+
+// HandleInstallSnapshot replaces this node's state with the leader's
+// snapshot when the follower is too far behind to catch up from the log.
+func (n *Node) HandleInstallSnapshot(args InstallSnapshotArgs) InstallSnapshotReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if args.Term < n.term {
+		return InstallSnapshotReply{Term: n.term}
+	}
+	if err := n.stepDown(args.Term); err != nil {
+		return InstallSnapshotReply{Term: n.term}
+	}
+	n.leaderID = args.LeaderID
+	n.resetElectionTimer()
+	if args.LastIndex <= n.commitIndex {
+		return InstallSnapshotReply{Term: n.term}
+	}
+	snap := Snapshot{LastIndex: args.LastIndex, LastTerm: args.LastTerm, Vectors: args.Vectors}
+	if err := n.cfg.Storage.SaveSnapshot(snap); err != nil {
+		// The leader resends the snapshot once appends show the gap.
+		return InstallSnapshotReply{Term: n.term}
+	}
+
+	head := Entry{Index: args.LastIndex, Term: args.LastTerm}
+	if args.LastIndex <= n.lastIndex() && n.termAt(args.LastIndex) == args.LastTerm {
+		n.log = append([]Entry{head}, n.log[args.LastIndex-n.snapIndex()+1:]...)
+	} else {
+		n.log = []Entry{head}
+	}
+	n.snapshot = args.Vectors
+	n.commitIndex = args.LastIndex
+	n.installPending = true
+	n.applyCond.Broadcast()
+	return InstallSnapshotReply{Term: n.term}
+}
+
+// runApplier applies committed entries and installed snapshots to the
+// store in log order, and compacts the log when it grows long.
+func (n *Node) runApplier() {
+	defer n.wg.Done()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for {
+		for !n.stopped && !n.installPending && n.lastApplied >= n.commitIndex {
+			n.applyCond.Wait()
+		}
+		if n.stopped {
+			return
+		}
+		if n.lastApplied < n.snapIndex() {
+			n.installPending = true
+		}
+		if n.installPending {
+			n.installSnapshot()
+			continue
+		}
+
+		first := n.lastApplied + 1 - n.snapIndex()
+		entries := slices.Clone(n.log[first : n.commitIndex-n.snapIndex()+1])
+		sm := n.sm.Load()
+		n.mu.Unlock()
+		errs := make([]error, len(entries))
+		for i, e := range entries {
+			switch e.Op {
+			case OpInsert:
+				errs[i] = sm.Insert(e.Vector)
+			case OpDelete:
+				errs[i] = sm.Delete(e.Vector.ID)
+			}
+		}
+		n.mu.Lock()
+
+		for i, e := range entries {
+			n.lastApplied = e.Index
+			n.notify(e.Index, e.Term, errs[i])
+		}
+		if n.lastApplied-n.snapIndex() >= uint64(n.cfg.SnapshotThreshold) {
+			n.takeSnapshot()
+		}
+	}
+}
+
+// notify resolves the proposer waiting on idx, if any. Caller holds mu.
+func (n *Node) notify(idx, term uint64, err error) {
+	w, ok := n.waiters[idx]
+	if !ok {
+		return
+	}
+	delete(n.waiters, idx)
+	if w.term != term {
+		// Another leader's entry replaced the proposal at this index.
+		err = ErrLeadershipLost
+	}
+	w.ch <- err
+}
+
+// installSnapshot loads the pending snapshot into a fresh store. Called
+// from the applier with mu held; mu is released while loading.
+func (n *Node) installSnapshot() {
+	n.installPending = false
+	idx, vectors := n.snapIndex(), n.snapshot
+	n.mu.Unlock()
+	sm := store.NewVectorStore(n.cfg.Dimension, n.cfg.StoreOptions...)
+	for _, v := range vectors {
+		sm.Insert(v)
+	}
+	n.mu.Lock()
+
+	if idx <= n.lastApplied {
+		return
+	}
+	n.sm.Store(sm)
+	n.lastApplied = idx
+	// Proposals covered by the snapshot never applied here individually,
+	// so their outcome is unknown to this node.
+	for i, w := range n.waiters {
+		if i <= idx {
+			delete(n.waiters, i)
+			w.ch <- ErrLeadershipLost
+		}
+	}
+}
+
+// takeSnapshot compacts the log up to lastApplied. Called from the applier
+// with mu held; mu is released while copying the store, which only the
+// applier writes.
+func (n *Node) takeSnapshot() {
+	idx := n.lastApplied
+	sm := n.sm.Load()
+	n.mu.Unlock()
+	var vectors []store.Vector
+	// Expired rows stay until a reap, which is a store-local delete, so
+	// the snapshot must hold them to match the state the log produced.
+	for v := range sm.Scan(store.ScanOptions{Payloads: true, Expired: true}) {
+		vectors = append(vectors, v)
+	}
+	n.mu.Lock()
+
+	if idx <= n.snapIndex() {
+		return
+	}
+	head := Entry{Index: idx, Term: n.termAt(idx)}
+	if err := n.cfg.Storage.SaveSnapshot(Snapshot{LastIndex: idx, LastTerm: head.Term, Vectors: vectors}); err != nil {
+		return // retried once more entries apply
+	}
+	n.log = append([]Entry{head}, n.log[idx-n.snapIndex()+1:]...)
+	n.snapshot = vectors
+}
@@ -0,0 +1,394 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+func startCluster(t *testing.T, size, snapshotThreshold int, opts ...store.Option) (*Network, []*Node) {
+	t.Helper()
+	nw := NewNetwork()
+	var ids []string
+	for i := range size {
+		ids = append(ids, fmt.Sprintf("n%d", i))
+	}
+	var nodes []*Node
+	for _, id := range ids {
+		n, err := NewNode(Config{
+			ID:                id,
+			Peers:             ids,
+			Dimension:         2,
+			StoreOptions:      opts,
+			Transport:         nw.Transport(id),
+			HeartbeatInterval: 10 * time.Millisecond,
+			ElectionTimeout:   50 * time.Millisecond,
+			SnapshotThreshold: snapshotThreshold,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nw.Add(n)
+		nodes = append(nodes, n)
+	}
+	for _, n := range nodes {
+		n.Start()
+		t.Cleanup(n.Stop)
+	}
+	return nw, nodes
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitLeader waits until exactly one of nodes leads the highest term among
+// them and returns it.
+func waitLeader(t *testing.T, nodes []*Node) *Node {
+	t.Helper()
+	var found *Node
+	waitFor(t, "a leader", func() bool {
+		found = nil
+		var top uint64
+		for _, n := range nodes {
+			if term, _ := n.State(); term > top {
+				top = term
+			}
+		}
+		for _, n := range nodes {
+			if term, isLeader := n.State(); isLeader && term == top {
+				found = n
+			}
+		}
+		return found != nil
+	})
+	return found
+}
+
+func insert(t *testing.T, n *Node, id string, x float32) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Insert(ctx, store.Vector{ID: id, Data: []float32{x, x}}); err != nil {
+		t.Fatalf("Insert %s on %s failed: %v", id, n.ID(), err)
+	}
+}
+
+// waitConverged waits until every node holds exactly the leader's vectors.
+func waitConverged(t *testing.T, leader *Node, nodes []*Node) {
+	t.Helper()
+	want := slices.Collect(leader.sm.Load().Scan(store.ScanOptions{}))
+	for _, n := range nodes {
+		waitFor(t, n.ID()+" to converge", func() bool {
+			if n.Count() != len(want) {
+				return false
+			}
+			for _, v := range want {
+				got, err := n.Get(v.ID)
+				if err != nil || !slices.Equal(got.Data, v.Data) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+func TestElectionAndReplication(t *testing.T) {
+	_, nodes := startCluster(t, 3, 0)
+	leader := waitLeader(t, nodes)
+
+	for i := range 50 {
+		insert(t, leader, fmt.Sprintf("v%d", i), float32(i))
+	}
+	ctx := context.Background()
+	if err := leader.Delete(ctx, "v0"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := leader.Delete(ctx, "v0"); err != store.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := leader.Insert(ctx, store.Vector{ID: "bad", Data: []float32{1}}); err != store.ErrDimensionMismatch {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if leader.Count() != 49 {
+		t.Fatalf("leader has %d vectors after its writes returned", leader.Count())
+	}
+	waitConverged(t, leader, nodes)
+
+	for _, n := range nodes {
+		if n == leader {
+			continue
+		}
+		if err := n.Insert(ctx, store.Vector{ID: "x", Data: []float32{1, 1}}); err != ErrNotLeader {
+			t.Fatalf("expected ErrNotLeader from follower, got %v", err)
+		}
+		if n.Leader() != leader.ID() {
+			t.Fatalf("follower %s thinks leader is %q", n.ID(), n.Leader())
+		}
+	}
+	res, err := nodes[0].Search([]float32{3, 3}, 1)
+	if err != nil || len(res) != 1 || res[0].ID != "v3" {
+		t.Fatalf("unexpected search result %v, %v", res, err)
+	}
+}
+
+func TestLeaderPartition(t *testing.T) {
+	nw, nodes := startCluster(t, 5, 0)
+	old := waitLeader(t, nodes)
+	insert(t, old, "before", 1)
+
+	var rest []string
+	var majority []*Node
+	for _, n := range nodes {
+		if n != old {
+			rest = append(rest, n.ID())
+			majority = append(majority, n)
+		}
+	}
+	nw.Partition([]string{old.ID()}, rest)
+
+	// The isolated leader cannot commit.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	err := old.Insert(ctx, store.Vector{ID: "lost", Data: []float32{2, 2}})
+	cancel()
+	if err == nil {
+		t.Fatal("isolated leader committed a write")
+	}
+
+	// The majority elects a new leader and keeps accepting writes.
+	leader := waitLeader(t, majority)
+	for i := range 20 {
+		insert(t, leader, fmt.Sprintf("after%d", i), float32(i))
+	}
+
+	nw.Heal()
+	waitFor(t, "old leader to step down", func() bool {
+		_, isLeader := old.State()
+		return !isLeader
+	})
+	waitConverged(t, leader, nodes)
+	if old.Contains("lost") {
+		t.Fatal("uncommitted write from the isolated leader survived")
+	}
+}
+
+func TestSnapshotCatchUp(t *testing.T) {
+	nw, nodes := startCluster(t, 3, 20)
+	leader := waitLeader(t, nodes)
+
+	var lagging *Node
+	var others []string
+	for _, n := range nodes {
+		if n != leader && lagging == nil {
+			lagging = n
+		} else {
+			others = append(others, n.ID())
+		}
+	}
+	nw.Partition([]string{lagging.ID()}, others)
+
+	for i := range 200 {
+		insert(t, leader, fmt.Sprintf("v%d", i%120), float32(i))
+	}
+	leader.mu.Lock()
+	compacted := leader.snapIndex() > 0
+	leader.mu.Unlock()
+	if !compacted {
+		t.Fatal("leader did not compact its log")
+	}
+
+	nw.Heal()
+	leader = waitLeader(t, nodes)
+	waitConverged(t, leader, nodes)
+	lagging.mu.Lock()
+	installed := lagging.snapIndex() > 0
+	lagging.mu.Unlock()
+	if !installed {
+		t.Fatal("lagging follower caught up without a snapshot")
+	}
+
+	// Replication continues from the log after the snapshot.
+	insert(t, leader, "later", 7)
+	waitConverged(t, leader, nodes)
+}
+
+func TestSingleNode(t *testing.T) {
+	_, nodes := startCluster(t, 1, 5)
+	leader := waitLeader(t, nodes)
+	for i := range 20 {
+		insert(t, leader, fmt.Sprintf("v%d", i), float32(i))
+	}
+	if leader.Count() != 20 {
+		t.Fatalf("expected 20 vectors, got %d", leader.Count())
+	}
+
+	leader.Stop()
+	if err := leader.Insert(context.Background(), store.Vector{ID: "x", Data: []float32{1, 1}}); !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected ErrStopped, got %v", err)
+	}
+}
+
+// restart stops n and starts a new node on its storage in its place.
+func restart(t *testing.T, nw *Network, n *Node) *Node {
+	t.Helper()
+	n.Stop()
+	restarted, err := NewNode(n.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nw.Add(restarted)
+	restarted.Start()
+	t.Cleanup(restarted.Stop)
+	return restarted
+}
+
+func TestRestartKeepsVote(t *testing.T) {
+	cfg := Config{ID: "n0", Peers: []string{"n0", "a", "b"}, Dimension: 2, Storage: NewMemoryStorage()}
+	n, err := NewNode(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply := n.HandleRequestVote(RequestVoteArgs{Term: 5, CandidateID: "a"}); !reply.VoteGranted {
+		t.Fatalf("vote for a not granted: %+v", reply)
+	}
+
+	n, err = NewNode(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if term, _ := n.State(); term != 5 {
+		t.Fatalf("restarted in term %d, want 5", term)
+	}
+	if reply := n.HandleRequestVote(RequestVoteArgs{Term: 5, CandidateID: "b"}); reply.VoteGranted {
+		t.Fatal("restarted node voted twice in term 5")
+	}
+	if reply := n.HandleRequestVote(RequestVoteArgs{Term: 5, CandidateID: "a"}); !reply.VoteGranted {
+		t.Fatal("restarted node refused the candidate it voted for")
+	}
+}
+
+func TestRestartKeepsLog(t *testing.T) {
+	nw, nodes := startCluster(t, 3, 20)
+	leader := waitLeader(t, nodes)
+	for i := range 50 {
+		insert(t, leader, fmt.Sprintf("v%d", i), float32(i))
+	}
+	waitConverged(t, leader, nodes)
+
+	// Restart every follower at once; they hold a majority of the log
+	// between them, so nothing committed may be lost.
+	for i, n := range nodes {
+		if n == leader {
+			continue
+		}
+		n.mu.Lock()
+		term, last := n.term, n.lastIndex()
+		n.mu.Unlock()
+		nodes[i] = restart(t, nw, n)
+		nodes[i].mu.Lock()
+		gotTerm, gotLast := nodes[i].term, nodes[i].lastIndex()
+		nodes[i].mu.Unlock()
+		if gotTerm < term || gotLast < last {
+			t.Fatalf("%s restarted at term %d, index %d; was at term %d, index %d", n.ID(), gotTerm, gotLast, term, last)
+		}
+	}
+
+	// With the old leader cut off, the restarted followers elect one of
+	// themselves, which must still hold every committed write.
+	var rest []string
+	var others []*Node
+	for _, n := range nodes {
+		if n != leader {
+			rest = append(rest, n.ID())
+			others = append(others, n)
+		}
+	}
+	nw.Partition([]string{leader.ID()}, rest)
+	next := waitLeader(t, others)
+	insert(t, next, "after", 1)
+	if next.Count() != 51 {
+		t.Fatalf("new leader has %d vectors, want 51", next.Count())
+	}
+	nw.Heal()
+	waitConverged(t, next, nodes)
+}
+
+func TestReplicateRichVectors(t *testing.T) {
+	nw, nodes := startCluster(t, 3, 5, store.WithDefaultTTL(time.Hour))
+	leader := waitLeader(t, nodes)
+	var lagging *Node
+	var others []string
+	for _, n := range nodes {
+		if n != leader && lagging == nil {
+			lagging = n
+		} else {
+			others = append(others, n.ID())
+		}
+	}
+	nw.Partition([]string{lagging.ID()}, others)
+
+	ctx := context.Background()
+	tok := [][]float32{{1, 0}, {0, 1}}
+	sparse := store.SparseVector{Indices: []uint32{4}, Values: []float32{2}}
+	if err := leader.Insert(ctx, store.Vector{ID: "tok", Tokens: tok}); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.Insert(ctx, store.Vector{ID: "sparse", Data: []float32{1, 1}, Sparse: sparse}); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.Insert(ctx, store.Vector{ID: "bad", Tokens: [][]float32{{1}}}); !errors.Is(err, store.ErrDimensionMismatch) {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+	// Reusing the buffers must not change what was proposed.
+	tok[0][0], sparse.Values[0] = 9, 9
+	expired := time.Now().Add(-time.Minute)
+	if err := leader.Insert(ctx, store.Vector{ID: "old", Data: []float32{3, 3}, ExpiresAt: expired}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		insert(t, leader, fmt.Sprintf("v%d", i), float32(i))
+	}
+
+	// The lagging node catches up from a snapshot.
+	nw.Heal()
+	leader = waitLeader(t, nodes)
+	waitConverged(t, leader, nodes)
+	want, _ := leader.Get("tok")
+	for _, n := range nodes {
+		got, err := n.Get("tok")
+		if err != nil || got.Tokens[0][0] != 1 || !got.ExpiresAt.Equal(want.ExpiresAt) || got.ExpiresAt.IsZero() {
+			t.Errorf("%s: tok = %+v, %v; want expiry %v", n.ID(), got, err, want.ExpiresAt)
+		}
+		if got, err := n.Get("sparse"); err != nil || got.Sparse.Values[0] != 2 {
+			t.Errorf("%s: sparse = %+v, %v", n.ID(), got, err)
+		}
+		var old []store.Vector
+		for v := range n.sm.Load().Scan(store.ScanOptions{Expired: true}) {
+			if v.ID == "old" {
+				old = append(old, v)
+			}
+		}
+		if len(old) != 1 || !old[0].ExpiresAt.Equal(expired) {
+			t.Errorf("%s: expired row %+v", n.ID(), old)
+		}
+	}
+	lagging.mu.Lock()
+	installed := lagging.snapIndex() > 0
+	lagging.mu.Unlock()
+	if !installed {
+		t.Fatal("lagging follower caught up without a snapshot")
+	}
+}
@@ -0,0 +1,109 @@
+package raft
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"vexor/pkg/store"
+)
+
+// ErrLogGap is returned by MemoryStorage when appended entries do not
+// follow on from the stored log.
+var ErrLogGap = errors.New("raft: appended entries leave a gap in the log")
+
+// HardState is the part of a node's state that must not be forgotten
+// across a restart: its term, and whom it voted for in that term.
+type HardState struct {
+	Term     uint64
+	VotedFor string
+}
+
+// Snapshot is a compacted prefix of the log: the store's vectors after
+// every entry up to and including LastIndex applied.
+type Snapshot struct {
+	LastIndex uint64
+	LastTerm  uint64
+	Vectors   []store.Vector
+}
+
+// Storage is a node's stable storage. Each method must have made its change
+// durable when it returns, since the node acts on the change right away:
+// it answers RPCs, grants votes and counts its own copy of an entry towards
+// a majority. A Storage belongs to one node at a time.
+type Storage interface {
+	// Load returns the saved state, snapshot and the log entries after the
+	// snapshot. A new Storage returns zero values and no entries.
+	Load() (HardState, Snapshot, []Entry, error)
+	// SetHardState replaces the saved term and vote.
+	SetHardState(HardState) error
+	// Append saves entries, which have consecutive indexes, replacing any
+	// stored entry at or after the index of the first one.
+	Append(entries []Entry) error
+	// SaveSnapshot replaces the saved snapshot with snap and drops the
+	// entries it covers. Entries after snap.LastIndex are kept only if the
+	// stored entry at snap.LastIndex has term snap.LastTerm; otherwise the
+	// whole log is dropped.
+	SaveSnapshot(snap Snapshot) error
+}
+
+// MemoryStorage is a Storage held in memory. It survives Stop and a new
+// Node built on it, which is enough for tests and for running a cluster in
+// one process, but not a restart of the process.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	state HardState
+	snap  Snapshot
+	log   []Entry // entries after snap.LastIndex
+}
+
+// NewMemoryStorage returns an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+
+func (m *MemoryStorage) Load() (HardState, Snapshot, []Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state, m.snap, slices.Clone(m.log), nil
+}
+
+func (m *MemoryStorage) SetHardState(st HardState) error {
+	m.mu.Lock()
+	m.state = st
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStorage) Append(entries []Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Entries the snapshot covers are committed and already stored.
+	for len(entries) > 0 && entries[0].Index <= m.snap.LastIndex {
+		entries = entries[1:]
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	keep := entries[0].Index - m.snap.LastIndex - 1
+	if keep > uint64(len(m.log)) {
+		return ErrLogGap
+	}
+	m.log = append(m.log[:keep:keep], entries...)
+	return nil
+}
+
+func (m *MemoryStorage) SaveSnapshot(snap Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if snap.LastIndex <= m.snap.LastIndex {
+		return nil
+	}
+	if i := snap.LastIndex - m.snap.LastIndex; i <= uint64(len(m.log)) && m.log[i-1].Term == snap.LastTerm {
+		m.log = slices.Clone(m.log[i:])
+	} else {
+		m.log = nil
+	}
+	m.snap = snap
+	return nil
+}
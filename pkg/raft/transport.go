@@ -0,0 +1,155 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"vexor/pkg/store"
+)
+
+// ErrUnreachable is returned by a transport when the target node cannot be
+// reached.
+var ErrUnreachable = errors.New("raft: node unreachable")
+
+// RequestVoteArgs is sent by candidates to gather votes.
+type RequestVoteArgs struct {
+	Term         uint64
+	CandidateID  string
+	LastLogIndex uint64
+	LastLogTerm  uint64
+}
+
+type RequestVoteReply struct {
+	Term        uint64
+	VoteGranted bool
+}
+
+// AppendEntriesArgs is sent by the leader to replicate entries; with no
+// entries it is a heartbeat.
+type AppendEntriesArgs struct {
+	Term         uint64
+	LeaderID     string
+	PrevLogIndex uint64
+	PrevLogTerm  uint64
+	Entries      []Entry
+	LeaderCommit uint64
+}
+
+type AppendEntriesReply struct {
+	Term    uint64
+	Success bool
+	// ConflictIndex is where the leader should resume when Success is false.
+	ConflictIndex uint64
+}
+
+// InstallSnapshotArgs carries the leader's snapshot to a follower whose
+// next entry the leader has already compacted away.
+type InstallSnapshotArgs struct {
+	Term      uint64
+	LeaderID  string
+	LastIndex uint64
+	LastTerm  uint64
+	Vectors   []store.Vector
+}
+
+type InstallSnapshotReply struct {
+	Term uint64
+}
+
+// Transport carries RPCs between nodes. Implementations deliver each call
+// to the target node's matching Handle method.
+type Transport interface {
+	RequestVote(ctx context.Context, target string, args RequestVoteArgs) (RequestVoteReply, error)
+	AppendEntries(ctx context.Context, target string, args AppendEntriesArgs) (AppendEntriesReply, error)
+	InstallSnapshot(ctx context.Context, target string, args InstallSnapshotArgs) (InstallSnapshotReply, error)
+}
+
+// Network is an in-memory Transport fabric for running a cluster in one
+// process. It can partition nodes to simulate network failures.
+type Network struct {
+	mu    sync.Mutex
+	nodes map[string]*Node
+	group map[string]int
+}
+
+// NewNetwork returns an empty, fully connected network.
+func NewNetwork() *Network {
+	return &Network{nodes: make(map[string]*Node), group: make(map[string]int)}
+}
+
+// Transport returns the transport node id uses to send.
+func (nw *Network) Transport(id string) Transport {
+	return memTransport{nw: nw, from: id}
+}
+
+// Add makes n reachable by its ID.
+func (nw *Network) Add(n *Node) {
+	nw.mu.Lock()
+	nw.nodes[n.ID()] = n
+	nw.mu.Unlock()
+}
+
+// Partition splits the network so that only nodes in the same group can
+// reach each other. Nodes not named in any group form one more group.
+func (nw *Network) Partition(groups ...[]string) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	clear(nw.group)
+	for i, g := range groups {
+		for _, id := range g {
+			nw.group[id] = i + 1
+		}
+	}
+}
+
+// Heal removes all partitions.
+func (nw *Network) Heal() {
+	nw.Partition()
+}
+
+// route returns the target node if from can reach it.
+func (nw *Network) route(from, to string) (*Node, error) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	n, ok := nw.nodes[to]
+	if !ok || nw.group[from] != nw.group[to] {
+		return nil, ErrUnreachable
+	}
+	return n, nil
+}
+
+type memTransport struct {
+	nw   *Network
+	from string
+}
+
+// call delivers one RPC, dropping the reply if the network was
+// partitioned while it was in flight.
+func call[A, R any](ctx context.Context, t memTransport, target string, args A, handle func(*Node, A) R) (R, error) {
+	var zero R
+	n, err := t.nw.route(t.from, target)
+	if err != nil {
+		return zero, err
+	}
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	reply := handle(n, args)
+	if _, err := t.nw.route(t.from, target); err != nil {
+		return zero, err
+	}
+	return reply, nil
+}
+
+func (t memTransport) RequestVote(ctx context.Context, target string, args RequestVoteArgs) (RequestVoteReply, error) {
+	return call(ctx, t, target, args, (*Node).HandleRequestVote)
+}
+
+func (t memTransport) AppendEntries(ctx context.Context, target string, args AppendEntriesArgs) (AppendEntriesReply, error) {
+	return call(ctx, t, target, args, (*Node).HandleAppendEntries)
+}
+
+func (t memTransport) InstallSnapshot(ctx context.Context, target string, args InstallSnapshotArgs) (InstallSnapshotReply, error) {
+	return call(ctx, t, target, args, (*Node).HandleInstallSnapshot)
+}
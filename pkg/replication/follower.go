@@ -0,0 +1,189 @@
+package replication
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+// DefaultRetryInterval is how long a follower waits before reconnecting.
+const DefaultRetryInterval = 500 * time.Millisecond
+
+// Follower keeps a local replica of a leader's store and serves read-only
+// searches from it.
+type Follower struct {
+	addr      string
+	dimension int
+	opts      []store.Option
+
+	// RetryInterval is the delay between reconnection attempts. Set it
+	// before calling Run.
+	RetryInterval time.Duration
+
+	// replica is replaced wholesale when a snapshot finishes loading, so
+	// readers never see a half-loaded snapshot.
+	replica atomic.Pointer[store.VectorStore]
+	// applied is the sequence number of the last change applied, or 0
+	// before the first snapshot.
+	applied atomic.Uint64
+	// log is the ID of the leader log applied counts in. Only the Run
+	// goroutine uses it.
+	log string
+}
+
+// NewFollower returns a follower of the leader at addr. opts configure the
+// local replica store.
+func NewFollower(addr string, dimension int, opts ...store.Option) *Follower {
+	f := &Follower{
+		addr:          addr,
+		dimension:     dimension,
+		opts:          opts,
+		RetryInterval: DefaultRetryInterval,
+	}
+	f.replica.Store(store.NewVectorStore(dimension, opts...))
+	return f
+}
+
+// Run replicates from the leader until ctx is done, reconnecting after
+// errors. It returns an error only if the leader refuses the follower.
+func (f *Follower) Run(ctx context.Context) error {
+	for {
+		err := f.replicate(ctx)
+		var refused refusedError
+		if errors.As(err, &refused) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(f.RetryInterval):
+		}
+	}
+}
+
+// Applied returns the sequence number of the last leader change reflected
+// in the replica.
+func (f *Follower) Applied() uint64 {
+	return f.applied.Load()
+}
+
+// Count returns the number of vectors in the replica.
+func (f *Follower) Count() int {
+	return f.replica.Load().Count()
+}
+
+// Get returns a copy of the vector stored under id in the replica.
+func (f *Follower) Get(id string) (store.Vector, error) {
+	return f.replica.Load().Get(id)
+}
+
+// Contains reports whether id is stored in the replica.
+func (f *Follower) Contains(id string) bool {
+	return f.replica.Load().Contains(id)
+}
+
+// Search finds the k nearest neighbors in the replica by Euclidean distance.
+func (f *Follower) Search(query []float32, k int) ([]store.SearchResult, error) {
+	return f.replica.Load().Search(query, k)
+}
+
+// SearchCosine finds the k nearest neighbors in the replica by cosine
+// distance.
+func (f *Follower) SearchCosine(query []float32, k int) ([]store.SearchResult, error) {
+	return f.replica.Load().SearchCosine(query, k)
+}
+
+// refusedError is returned when the leader rejects the follower outright.
+type refusedError string
+
+func (e refusedError) Error() string { return "replication: leader refused follower: " + string(e) }
+
+// replicate runs one connection to the leader until it fails.
+func (f *Follower) replicate(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", f.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var from uint64
+	if applied := f.applied.Load(); applied > 0 {
+		from = applied + 1
+	}
+	if err := gob.NewEncoder(conn).Encode(request{From: from, Log: f.log, Dimension: f.dimension}); err != nil {
+		return err
+	}
+
+	dec := gob.NewDecoder(conn)
+	var loading *store.VectorStore
+	for {
+		var fr frame
+		if err := dec.Decode(&fr); err != nil {
+			return err
+		}
+		switch fr.Kind {
+		case frameError:
+			return refusedError(fr.Err)
+		case frameSnapshot:
+			if loading == nil {
+				loading = store.NewVectorStore(f.dimension, f.opts...)
+			}
+			for _, v := range fr.Vectors {
+				if err := loading.Insert(v); err != nil {
+					return err
+				}
+			}
+		case frameSnapshotEnd:
+			if loading == nil {
+				loading = store.NewVectorStore(f.dimension, f.opts...)
+			}
+			f.replica.Store(loading)
+			f.applied.Store(fr.Next - 1)
+			f.log = fr.Log
+			loading = nil
+		case frameChanges:
+			if err := f.apply(fr.Changes); err != nil {
+				// The replica no longer matches the leader; start over
+				// from a snapshot.
+				f.applied.Store(0)
+				return err
+			}
+		}
+	}
+}
+
+// apply applies a batch of changes, committing each version's changes
+// together so readers never see part of a leader transaction.
+func (f *Follower) apply(changes []store.Change) error {
+	replica := f.replica.Load()
+	for len(changes) > 0 {
+		n := 1
+		for n < len(changes) && changes[n].Version == changes[0].Version {
+			n++
+		}
+		txn := replica.Begin()
+		for _, c := range changes[:n] {
+			if c.Kind == store.ChangeDelete {
+				// Remove, as the leader may have reaped a vector
+				// that has also expired here.
+				txn.Remove(c.Vector.ID)
+			} else {
+				txn.Insert(c.Vector)
+			}
+		}
+		if err := txn.Commit(); err != nil {
+			return err
+		}
+		f.applied.Store(changes[n-1].Seq)
+		changes = changes[n:]
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Package replication streams a VectorStore's change log from a leader to
+// read-only followers over TCP.
+//
+// A follower connects and asks for changes from the sequence number after
+// the last one it applied. If that position is no longer in the leader's
+// retained change log, or the follower has no state yet, the leader first
+// sends a checkpoint of the whole store. Sequence numbers only mean
+// something within one change log, which starts over when the leader
+// restarts, so the leader's snapshots come with the store's change log ID:
+// a follower resuming under another ID, or from past the end of the log, is
+// sent a snapshot too. Messages are gob-encoded, so payload
+// values must be gob-encodable (basic types work without registration).
+package replication
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"net"
+	"sync"
+
+	"vexor/pkg/store"
+)
+
+// snapshotChunk is the number of vectors per snapshot message.
+const snapshotChunk = 1024
+
+// request is the first and only message a follower sends on a connection.
+type request struct {
+	// From is the sequence number of the first change the follower needs,
+	// or 0 when it needs a snapshot.
+	From uint64
+	// Log is the ID of the leader log From counts in.
+	Log       string
+	Dimension int
+}
+
+type frameKind uint8
+
+const (
+	frameSnapshot    frameKind = iota + 1 // a chunk of snapshot vectors
+	frameSnapshotEnd                      // snapshot complete; Next is its position in Log
+	frameChanges                          // a batch of changes
+	frameError                            // the leader refused the request
+)
+
+// frame is a message from the leader.
+type frame struct {
+	Kind    frameKind
+	Vectors []store.Vector
+	Next    uint64
+	Log     string
+	Changes []store.Change
+	Err     string
+}
+
+// Leader serves a store's change log to followers.
+type Leader struct {
+	s   *store.VectorStore
+	log string // the store's change log ID, sent to followers
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewLeader returns a Leader for s. The store must have been created with
+// store.WithChangeLog; the retention decides how far behind a follower may
+// fall before it has to be resent a snapshot.
+func NewLeader(s *store.VectorStore) (*Leader, error) {
+	id, err := s.ChangeLogID()
+	if err != nil {
+		return nil, err
+	}
+	return &Leader{s: s, log: id, conns: make(map[net.Conn]struct{})}, nil
+}
+
+// Serve accepts follower connections on ln until Close is called.
+func (l *Leader) Serve(ln net.Listener) error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	l.ln = ln
+	l.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			l.mu.Lock()
+			closed := l.closed
+			l.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		if !l.track(conn) {
+			conn.Close()
+			return nil
+		}
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			defer l.untrack(conn)
+			l.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops accepting followers, drops connected ones and waits for
+// their handlers to return.
+func (l *Leader) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	var err error
+	if l.ln != nil {
+		err = l.ln.Close()
+	}
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+	return err
+}
+
+// dropFollowers closes every follower connection without stopping the
+// leader. Followers reconnect and resume.
+func (l *Leader) dropFollowers() {
+	l.mu.Lock()
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+}
+
+func (l *Leader) track(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.conns[conn] = struct{}{}
+	return true
+}
+
+func (l *Leader) untrack(conn net.Conn) {
+	l.mu.Lock()
+	delete(l.conns, conn)
+	l.mu.Unlock()
+	conn.Close()
+}
+
+func (l *Leader) serveConn(conn net.Conn) {
+	dec := gob.NewDecoder(conn)
+	enc := gob.NewEncoder(conn)
+
+	var req request
+	if err := dec.Decode(&req); err != nil {
+		return
+	}
+	if req.Dimension != l.s.Dimension() {
+		enc.Encode(frame{Kind: frameError, Err: store.ErrDimensionMismatch.Error()})
+		return
+	}
+
+	// The follower sends nothing after its request, so a read returning
+	// means the connection is gone; use it to stop waiting for changes.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		var b [1]byte
+		conn.Read(b[:])
+		cancel()
+	}()
+
+	// A position from another log, or past the end of this one, says
+	// nothing about the follower's state.
+	next := req.From
+	if first, end, _ := l.s.ChangeLogBounds(); next == 0 || req.Log != l.log || next < first || next > end {
+		var err error
+		if next, err = l.sendSnapshot(enc); err != nil {
+			return
+		}
+	}
+	for {
+		changes, err := l.s.WaitChanges(ctx, next)
+		if errors.Is(err, store.ErrChangesTruncated) {
+			// The follower fell behind the retained log while connected.
+			if next, err = l.sendSnapshot(enc); err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if err := enc.Encode(frame{Kind: frameChanges, Changes: changes}); err != nil {
+			return
+		}
+		next = changes[len(changes)-1].Seq + 1
+	}
+}
+
+// sendSnapshot streams a checkpoint of the store and returns the sequence
+// number to continue from.
+func (l *Leader) sendSnapshot(enc *gob.Encoder) (uint64, error) {
+	var (
+		vectors []store.Vector
+		next    uint64
+		err     error
+	)
+	for {
+		vectors, next, err = l.s.Checkpoint()
+		if !errors.Is(err, store.ErrChangesTruncated) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	for start := 0; start < len(vectors); start += snapshotChunk {
+		end := min(start+snapshotChunk, len(vectors))
+		if err := enc.Encode(frame{Kind: frameSnapshot, Vectors: vectors[start:end]}); err != nil {
+			return 0, err
+		}
+	}
+	return next, enc.Encode(frame{Kind: frameSnapshotEnd, Next: next, Log: l.log})
+}
@@ -0,0 +1,186 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+func startLeader(t *testing.T, s *store.VectorStore) (*Leader, string) {
+	t.Helper()
+	l, err := NewLeader(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.Serve(ln)
+	t.Cleanup(func() { l.Close() })
+	return l, ln.Addr().String()
+}
+
+func startFollower(t *testing.T, f *Follower) context.CancelFunc {
+	t.Helper()
+	f.RetryInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Run(ctx)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// waitConverged waits until f has applied everything s has logged and then
+// checks the replica holds exactly the leader's vectors.
+func waitConverged(t *testing.T, s *store.VectorStore, f *Follower) {
+	t.Helper()
+	_, next, _ := s.ChangeLogBounds()
+	deadline := time.Now().Add(10 * time.Second)
+	for f.Applied() < next-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("follower applied %d, leader at %d", f.Applied(), next-1)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if f.Count() != s.Count() {
+		t.Fatalf("follower has %d vectors, leader %d", f.Count(), s.Count())
+	}
+	for v := range s.Scan(store.ScanOptions{Payloads: true}) {
+		got, err := f.Get(v.ID)
+		if err != nil || !slices.Equal(got.Data, v.Data) || got.Payload["n"] != v.Payload["n"] {
+			t.Fatalf("follower has %+v for %q, leader %+v", got, v.ID, v)
+		}
+	}
+}
+
+func insertRange(s *store.VectorStore, from, to int) {
+	for i := from; i < to; i++ {
+		s.Insert(store.Vector{
+			ID:      fmt.Sprintf("v%d", i%300),
+			Data:    []float32{float32(i), float32(-i)},
+			Payload: store.Payload{"n": i},
+		})
+	}
+}
+
+func TestReplicationConverges(t *testing.T) {
+	s := store.NewVectorStore(2, store.WithChangeLog(10000))
+	insertRange(s, 0, 100)
+	leader, addr := startLeader(t, s)
+
+	followers := []*Follower{NewFollower(addr, 2), NewFollower(addr, 2, store.WithShards(4))}
+	for _, f := range followers {
+		startFollower(t, f)
+	}
+	insertRange(s, 100, 500)
+	s.Delete("v7")
+	s.Rename("v8", "renamed")
+	for _, f := range followers {
+		waitConverged(t, s, f)
+	}
+
+	// Followers reconnect after being dropped and resume where they were.
+	leader.dropFollowers()
+	insertRange(s, 500, 700)
+	txn := s.Begin()
+	txn.Delete("v9")
+	txn.Insert(store.Vector{ID: "txn", Data: []float32{1, 2}})
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range followers {
+		waitConverged(t, s, f)
+	}
+
+	res, err := followers[0].Search([]float32{1, 2}, 1)
+	if err != nil || len(res) != 1 || res[0].ID != "txn" {
+		t.Fatalf("unexpected follower search result %v, %v", res, err)
+	}
+}
+
+func TestReplicationSnapshotCatchUp(t *testing.T) {
+	s := store.NewVectorStore(2, store.WithChangeLog(50))
+	insertRange(s, 0, 20)
+	_, addr := startLeader(t, s)
+
+	f := NewFollower(addr, 2)
+	stop := startFollower(t, f)
+	waitConverged(t, s, f)
+	stop()
+
+	// Fall further behind than the leader retains.
+	insertRange(s, 20, 1000)
+	s.Delete("v3")
+	if first, _, _ := s.ChangeLogBounds(); f.Applied()+1 >= first {
+		t.Fatalf("follower at %d is not behind retained log starting at %d", f.Applied(), first)
+	}
+
+	startFollower(t, f)
+	waitConverged(t, s, f)
+	if f.Contains("v3") {
+		t.Error("deleted vector survived snapshot catch-up")
+	}
+}
+
+func TestReplicationLeaderRestart(t *testing.T) {
+	s := store.NewVectorStore(2, store.WithChangeLog(10000))
+	insertRange(s, 0, 100)
+	leader, addr := startLeader(t, s)
+	f := NewFollower(addr, 2)
+	startFollower(t, f)
+	waitConverged(t, s, f)
+
+	// A restarted leader's log starts over at 1 and soon reaches the
+	// follower's position again, with different changes behind it.
+	leader.Close()
+	restarted := store.NewVectorStore(2, store.WithChangeLog(10000))
+	for i := range 150 {
+		restarted.Insert(store.Vector{ID: fmt.Sprintf("new%d", i), Data: []float32{float32(i), 1}, Payload: store.Payload{"n": i}})
+	}
+	l, err := NewLeader(restarted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.Serve(ln)
+	t.Cleanup(func() { l.Close() })
+
+	waitConverged(t, restarted, f)
+	if f.Contains("v1") {
+		t.Error("follower kept state from the old leader")
+	}
+}
+
+func TestReplicationDimensionMismatch(t *testing.T) {
+	s := store.NewVectorStore(2, store.WithChangeLog(10))
+	_, addr := startLeader(t, s)
+
+	f := NewFollower(addr, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := f.Run(ctx); err == nil {
+		t.Fatal("expected follower with wrong dimension to be refused")
+	}
+}
+
+func TestNewLeaderRequiresChangeLog(t *testing.T) {
+	if _, err := NewLeader(store.NewVectorStore(2)); err != store.ErrChangeLogDisabled {
+		t.Fatalf("expected ErrChangeLogDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ChangeKind identifies the kind of mutation a Change records.
+type ChangeKind uint8
+
+const (
+	ChangeInsert ChangeKind = iota + 1 // a new ID was added
+	ChangeUpsert                       // an existing ID was replaced
+	ChangeDelete                       // an ID was removed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpsert:
+		return "upsert"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Change is one mutation from the store's change log.
+type Change struct {
+	// Seq is the change's position in the log. Sequence numbers start at 1
+	// and increase by one per change with no gaps.
+	Seq uint64
+	// Version is the commit version. Changes applied together by a Txn or
+	// Rename share a version and are adjacent in the log.
+	Version uint64
+	Kind    ChangeKind
+	// Vector holds the new data for inserts and upserts; for deletes only
+	// the ID is set.
+	Vector Vector
+}
+
+// changeLog is the ordered, bounded in-memory record of committed changes.
+// Changes are appended in version order as the visible version advances, so
+// a consumer never sees a change before everything older is visible.
+//
+// The log lives in memory only: a consumer that restarts can resume from
+// its last Seq for as long as the store process keeps that position
+// retained, but the log does not survive a restart of the store itself.
+// Each log gets a random ID so that a position saved before such a restart
+// is refused rather than read as a position in the new log.
+type changeLog struct {
+	id        string
+	mu        sync.Mutex
+	events    []Change
+	next      uint64 // Seq of the next appended change
+	retention int
+	notify    chan struct{} // closed and replaced on every append
+}
+
+func newChangeLog(retention int) *changeLog {
+	var id [16]byte
+	rand.Read(id[:])
+	return &changeLog{
+		id:        hex.EncodeToString(id[:]),
+		next:      1,
+		retention: retention,
+		notify:    make(chan struct{}),
+	}
+}
+
+// append assigns sequence numbers to changes and adds them to the log.
+func (l *changeLog) append(changes []Change) {
+	l.mu.Lock()
+	for _, c := range changes {
+		c.Seq = l.next
+		l.next++
+		l.events = append(l.events, c)
+	}
+	// Trim in batches so the copy is amortized over retention appends.
+	if len(l.events) >= 2*l.retention {
+		n := copy(l.events, l.events[len(l.events)-l.retention:])
+		clear(l.events[n:])
+		l.events = l.events[:n]
+	}
+	close(l.notify)
+	l.notify = make(chan struct{})
+	l.mu.Unlock()
+}
+
+// read returns up to limit retained changes starting at Seq from, and a
+// channel that is closed when more changes arrive.
+func (l *changeLog) read(from uint64, limit int) ([]Change, <-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	first := l.next - uint64(len(l.events))
+	if from < first {
+		return nil, nil, ErrChangesTruncated
+	}
+	if from >= l.next {
+		return nil, l.notify, nil
+	}
+	start := int(from - first)
+	end := len(l.events)
+	if limit > 0 && end-start > limit {
+		end = start + limit
+	}
+	out := make([]Change, end-start)
+	copy(out, l.events[start:end])
+	return out, l.notify, nil
+}
+
+// bounds returns the oldest retained Seq and the Seq the next change gets.
+func (l *changeLog) bounds() (first, next uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next - uint64(len(l.events)), l.next
+}
+
+// WithChangeLog enables the change log, retaining at least the most recent
+// retention changes for Changes and Subscribe. It is off by default, and
+// writes record nothing while it is off.
+func WithChangeLog(retention int) Option {
+	return func(c *config) {
+		if retention > 0 {
+			c.changeRetention = retention
+		}
+	}
+}
+
+// recordChange appends a change for the write at version to changes,
+// copying the vector so the log never aliases caller or row memory. It
+// returns changes unchanged when the change log is disabled.
+func (s *VectorStore) recordChange(changes []Change, kind ChangeKind, v Vector, version uint64) []Change {
+	if s.clock.log == nil {
+		return changes
+	}
+	if kind == ChangeDelete {
+		v = Vector{ID: v.ID}
+	} else {
+		v.Data = slices.Clone(v.Data)
+		v.Sparse = v.Sparse.clone()
+		v.Tokens = cloneTokens(v.Tokens)
+		v.Payload = maps.Clone(v.Payload)
+	}
+	return append(changes, Change{Version: version, Kind: kind, Vector: v})
+}
+
+// ChangeLogID returns the ID of the store's change log. Sequence numbers
+// only mean something within one log, and every store starts a new one, so
+// a consumer that saves its position saves the ID with it and passes both
+// back to Subscribe.
+func (s *VectorStore) ChangeLogID() (string, error) {
+	if s.clock.log == nil {
+		return "", ErrChangeLogDisabled
+	}
+	return s.clock.log.id, nil
+}
+
+// ChangeLogBounds returns the oldest retained sequence number and the
+// sequence number the next change will get. Resuming from any Seq in
+// [first, next] is possible.
+func (s *VectorStore) ChangeLogBounds() (first, next uint64, err error) {
+	if s.clock.log == nil {
+		return 0, 0, ErrChangeLogDisabled
+	}
+	first, next = s.clock.log.bounds()
+	return first, next, nil
+}
+
+// Changes returns up to limit committed changes starting at sequence number
+// from, oldest first. limit <= 0 means no limit. It returns
+// ErrChangesTruncated if from is older than the retained log.
+func (s *VectorStore) Changes(from uint64, limit int) ([]Change, error) {
+	if s.clock.log == nil {
+		return nil, ErrChangeLogDisabled
+	}
+	changes, _, err := s.clock.log.read(from, limit)
+	return changes, err
+}
+
+// WaitChanges is like Changes without a limit, but blocks until at least one
+// change at or after from has been committed or ctx is done. The changes of
+// one version are appended to the log together, so a returned batch never
+// ends partway through a Txn or Rename.
+func (s *VectorStore) WaitChanges(ctx context.Context, from uint64) ([]Change, error) {
+	log := s.clock.log
+	if log == nil {
+		return nil, ErrChangeLogDisabled
+	}
+	for {
+		changes, notify, err := log.read(from, 0)
+		if err != nil || len(changes) > 0 {
+			return changes, err
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Checkpoint copies every vector, payloads included, at one point in time
+// and returns the sequence number of the first change the copy does not
+// reflect. Loading the vectors and then applying changes from that sequence
+// number on reproduces the store. It returns ErrChangesTruncated if writes
+// outran the retained log during the copy; the caller may retry.
+func (s *VectorStore) Checkpoint() ([]Vector, uint64, error) {
+	log := s.clock.log
+	if log == nil {
+		return nil, 0, ErrChangeLogDisabled
+	}
+	// Everything logged before next was visible when it was logged, so its
+	// version is no newer than the epoch pinned below and the copy
+	// includes it.
+	_, next := log.bounds()
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	s.clock.awaitLog()
+
+	// Expired rows are copied too: their removal by the reaper is a logged
+	// delete, which a consumer of the copy must be able to apply.
+	var out []Vector
+	set := s.layout.Load()
+	for i := range set.shards {
+		out = append(out, set.shards[i].rows.Load().snapshot(s.dimension, pin.epoch, 0, true)...)
+	}
+
+	// Changes logged since next may also be covered by the pinned epoch,
+	// and awaitLog has put all of them in the log; they form a prefix
+	// because the log is in version order.
+	changes, _, err := log.read(next, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, c := range changes {
+		if c.Version > pin.epoch {
+			break
+		}
+		next = c.Seq + 1
+	}
+	return out, next, nil
+}
+
+// Subscription delivers changes from the log on a channel.
+type Subscription struct {
+	c    chan Change
+	done chan struct{}
+	once sync.Once
+	err  error
+}
+
+// C returns the channel changes are delivered on, in Seq order. It is
+// closed when the subscription ends; Err then reports why.
+func (sub *Subscription) C() <-chan Change {
+	return sub.c
+}
+
+// Err returns ErrChangesTruncated if the subscriber fell behind the
+// retained log, or nil if the subscription was closed normally.
+func (sub *Subscription) Err() error {
+	return sub.err
+}
+
+// Close stops the subscription. Pending changes are dropped.
+func (sub *Subscription) Close() {
+	sub.once.Do(func() { close(sub.done) })
+}
+
+// Subscribe streams changes starting at sequence number from of the change
+// log identified by logID. Pass the last processed Seq plus one to resume,
+// or the next value from ChangeLogBounds to receive only new changes. A
+// position saved under another logID, such as one from before the store
+// restarted, fails with ErrChangeLogReset; the consumer has to start over
+// from a Checkpoint. A slow subscriber only delays itself; writers never
+// wait on it.
+func (s *VectorStore) Subscribe(logID string, from uint64, buffer int) (*Subscription, error) {
+	log := s.clock.log
+	if log == nil {
+		return nil, ErrChangeLogDisabled
+	}
+	if logID != log.id {
+		return nil, ErrChangeLogReset
+	}
+	if first, _ := log.bounds(); from < first {
+		return nil, ErrChangesTruncated
+	}
+
+	sub := &Subscription{
+		c:    make(chan Change, buffer),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(sub.c)
+		next := from
+		for {
+			changes, notify, err := log.read(next, 0)
+			if err != nil {
+				sub.err = err
+				return
+			}
+			for _, c := range changes {
+				select {
+				case sub.c <- c:
+					next = c.Seq + 1
+				case <-sub.done:
+					return
+				}
+			}
+			if len(changes) > 0 {
+				continue
+			}
+			select {
+			case <-notify:
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
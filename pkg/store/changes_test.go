@@ -0,0 +1,292 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChangesKinds(t *testing.T) {
+	s := NewVectorStore(2, WithChangeLog(100))
+	s.Insert(Vector{ID: "a", Data: []float32{1, 1}, Payload: Payload{"k": "v"}})
+	s.Insert(Vector{ID: "a", Data: []float32{2, 2}})
+	s.Rename("a", "b")
+	s.Delete("b")
+
+	changes, err := s.Changes(1, 0)
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	want := []struct {
+		kind ChangeKind
+		id   string
+	}{
+		{ChangeInsert, "a"}, {ChangeUpsert, "a"}, {ChangeDelete, "a"}, {ChangeInsert, "b"}, {ChangeDelete, "b"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %d", len(want), len(changes))
+	}
+	for i, c := range changes {
+		if c.Seq != uint64(i+1) || c.Kind != want[i].kind || c.Vector.ID != want[i].id {
+			t.Errorf("change %d: got seq=%d %v %q", i, c.Seq, c.Kind, c.Vector.ID)
+		}
+	}
+	if changes[0].Vector.Payload["k"] != "v" || changes[3].Vector.Data[0] != 2 {
+		t.Errorf("change data not recorded: %+v %+v", changes[0].Vector, changes[3].Vector)
+	}
+	// Rename commits both halves under one version.
+	if changes[2].Version != changes[3].Version {
+		t.Errorf("rename changes have versions %d and %d", changes[2].Version, changes[3].Version)
+	}
+
+	if page, _ := s.Changes(4, 1); len(page) != 1 || page[0].Seq != 4 {
+		t.Errorf("unexpected page: %+v", page)
+	}
+	if rest, _ := s.Changes(6, 0); len(rest) != 0 {
+		t.Errorf("expected no changes past the end, got %d", len(rest))
+	}
+}
+
+func TestChangesTxn(t *testing.T) {
+	s := NewVectorStore(2, WithChangeLog(100))
+	s.Insert(Vector{ID: "x", Data: []float32{1, 1}})
+
+	txn := s.Begin()
+	txn.Delete("x")
+	txn.Insert(Vector{ID: "y", Data: []float32{2, 2}})
+	txn.Insert(Vector{ID: "y", Data: []float32{3, 3}})
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, _ := s.Changes(2, 0)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	kinds := []ChangeKind{ChangeDelete, ChangeInsert, ChangeUpsert}
+	for i, c := range changes {
+		if c.Kind != kinds[i] || c.Version != changes[0].Version {
+			t.Errorf("change %d: %v at version %d", i, c.Kind, c.Version)
+		}
+	}
+}
+
+func TestChangesDisabled(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "a", Data: []float32{1, 1}})
+	if _, err := s.Changes(1, 0); err != ErrChangeLogDisabled {
+		t.Errorf("expected ErrChangeLogDisabled, got %v", err)
+	}
+	if _, err := s.Subscribe("", 1, 0); err != ErrChangeLogDisabled {
+		t.Errorf("expected ErrChangeLogDisabled, got %v", err)
+	}
+}
+
+func TestChangesRetention(t *testing.T) {
+	s := NewVectorStore(2, WithChangeLog(10))
+	for i := range 50 {
+		s.Insert(Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{1, 1}})
+	}
+	first, next, err := s.ChangeLogBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 51 || next-first < 10 {
+		t.Fatalf("unexpected bounds [%d, %d)", first, next)
+	}
+	if _, err := s.Changes(1, 0); err != ErrChangesTruncated {
+		t.Errorf("expected ErrChangesTruncated, got %v", err)
+	}
+	if _, err := s.Subscribe(logID(s), 1, 0); err != ErrChangesTruncated {
+		t.Errorf("expected ErrChangesTruncated, got %v", err)
+	}
+	if changes, _ := s.Changes(41, 0); len(changes) != 10 || changes[0].Vector.ID != "v40" {
+		t.Errorf("unexpected retained changes: %d", len(changes))
+	}
+}
+
+func logID(s *VectorStore) string {
+	id, _ := s.ChangeLogID()
+	return id
+}
+
+func receive(t *testing.T, sub *Subscription) Change {
+	t.Helper()
+	select {
+	case c, ok := <-sub.C():
+		if !ok {
+			t.Fatalf("subscription ended: %v", sub.Err())
+		}
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+	return Change{}
+}
+
+func TestSubscribeResume(t *testing.T) {
+	s := NewVectorStore(2, WithChangeLog(100))
+	s.Insert(Vector{ID: "a", Data: []float32{1, 1}})
+
+	sub, err := s.Subscribe(logID(s), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := receive(t, sub); c.Seq != 1 || c.Vector.ID != "a" {
+		t.Fatalf("unexpected change %+v", c)
+	}
+	s.Insert(Vector{ID: "b", Data: []float32{2, 2}})
+	last := receive(t, sub)
+	if last.Seq != 2 || last.Vector.ID != "b" {
+		t.Fatalf("unexpected change %+v", last)
+	}
+	sub.Close()
+	for range sub.C() {
+	}
+	if sub.Err() != nil {
+		t.Errorf("expected nil Err after Close, got %v", sub.Err())
+	}
+
+	// Changes made while no one is subscribed are picked up on resume.
+	s.Delete("a")
+	sub, err = s.Subscribe(logID(s), last.Seq+1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if c := receive(t, sub); c.Seq != 3 || c.Kind != ChangeDelete || c.Vector.ID != "a" {
+		t.Fatalf("unexpected change %+v", c)
+	}
+
+	// A restarted store starts a new log, where the saved position would
+	// name different changes.
+	restarted := NewVectorStore(2, WithChangeLog(100))
+	for i := range 5 {
+		restarted.Insert(Vector{ID: fmt.Sprintf("n%d", i), Data: []float32{1, 1}})
+	}
+	if _, err := restarted.Subscribe(logID(s), last.Seq+1, 0); err != ErrChangeLogReset {
+		t.Errorf("expected ErrChangeLogReset, got %v", err)
+	}
+	if logID(restarted) == logID(s) {
+		t.Error("two stores share a change log ID")
+	}
+}
+
+func TestSubscribeConcurrentWriters(t *testing.T) {
+	s := NewVectorStore(2, WithChangeLog(100000))
+	sub, err := s.Subscribe(logID(s), 1, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	const writers, perWriter = 8, 500
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				s.Insert(Vector{ID: fmt.Sprintf("w%d-%d", w, i%50), Data: []float32{float32(i), 0}})
+			}
+		}()
+	}
+
+	// Sequence numbers have no gaps and versions never go backwards.
+	var lastVersion uint64
+	for i := 1; i <= writers*perWriter; i++ {
+		c := receive(t, sub)
+		if c.Seq != uint64(i) {
+			t.Fatalf("expected seq %d, got %d", i, c.Seq)
+		}
+		if c.Version < lastVersion {
+			t.Fatalf("version went backwards: %d after %d", c.Version, lastVersion)
+		}
+		lastVersion = c.Version
+	}
+	wg.Wait()
+}
+
+func TestCheckpoint(t *testing.T) {
+	s := NewVectorStore(2, WithChangeLog(1000))
+	for i := range 20 {
+		s.Insert(Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{float32(i), 0}})
+	}
+	s.Delete("v0")
+
+	vectors, next, err := s.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 19 || next != 22 {
+		t.Fatalf("got %d vectors resuming at %d", len(vectors), next)
+	}
+
+	s.Insert(Vector{ID: "later", Data: []float32{1, 1}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changes, err := s.WaitChanges(ctx, next)
+	if err != nil || len(changes) != 1 || changes[0].Vector.ID != "later" {
+		t.Fatalf("unexpected changes after checkpoint: %+v, %v", changes, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.WaitChanges(ctx, next+1); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestCheckpointConcurrentWriters(t *testing.T) {
+	s := NewVectorStore(2, WithShards(4), WithChangeLog(100000))
+	const writers, perWriter = 4, 500
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				s.Insert(Vector{ID: fmt.Sprintf("w%d-%d", w, i), Data: []float32{float32(i), 0}})
+			}
+		}()
+	}
+
+	type checkpoint struct {
+		vectors []Vector
+		next    uint64
+	}
+	var checkpoints []checkpoint
+	for range 20 {
+		vectors, next, err := s.Checkpoint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkpoints = append(checkpoints, checkpoint{vectors, next})
+	}
+	wg.Wait()
+
+	// Every insert is in exactly one of the copy and the changes after it.
+	for _, cp := range checkpoints {
+		seen := make(map[string]int)
+		for _, v := range cp.vectors {
+			seen[v.ID]++
+		}
+		changes, err := s.Changes(cp.next, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range changes {
+			seen[c.Vector.ID]++
+		}
+		if len(seen) != writers*perWriter {
+			t.Fatalf("checkpoint at %d lost changes: %d of %d IDs", cp.next, len(seen), writers*perWriter)
+		}
+		for id, n := range seen {
+			if n != 1 {
+				t.Fatalf("checkpoint at %d: %s seen %d times", cp.next, id, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+)
+
+// topK is a bounded max-heap of results keyed by distance. The root is
+// the worst result kept so far, so a scan only touches the heap when a
+// candidate beats it. Unlike container/heap it never boxes results into
+// interfaces, and its backing slice is reused across searches.
+type topK[ID comparable] struct {
+	items []Result[ID]
+	k     int
+}
+
+// reset empties the heap and sets its capacity bound, keeping the backing
+// slice.
+func (h *topK[ID]) reset(k int) {
+	h.items = h.items[:0]
+	h.k = k
+}
+
+func (h *topK[ID]) len() int { return len(h.items) }
+
+// offer adds r if the heap is not yet full or r beats the current worst.
+func (h *topK[ID]) offer(r Result[ID]) {
+	if len(h.items) < h.k {
+		h.items = append(h.items, r)
+		h.up(len(h.items) - 1)
+	} else if r.Distance < h.items[0].Distance {
+		h.items[0] = r
+		h.down(0)
+	}
+}
+
+// wants reports whether a candidate at distance d would be kept. Scans use
+// it to skip building a SearchResult for candidates that lose.
+func (h *topK[ID]) wants(d float32) bool {
+	return len(h.items) < h.k || d < h.items[0].Distance
+}
+
+func (h *topK[ID]) up(i int) {
+	items := h.items
+	for i > 0 {
+		parent := (i - 1) / 2
+		if items[parent].Distance >= items[i].Distance {
+			break
+		}
+		items[parent], items[i] = items[i], items[parent]
+		i = parent
+	}
+}
+
+func (h *topK[ID]) down(i int) {
+	items := h.items
+	n := len(items)
+	for {
+		largest := i
+		l, r := 2*i+1, 2*i+2
+		if l < n && items[l].Distance > items[largest].Distance {
+			largest = l
+		}
+		if r < n && items[r].Distance > items[largest].Distance {
+			largest = r
+		}
+		if largest == i {
+			return
+		}
+		items[i], items[largest] = items[largest], items[i]
+		i = largest
+	}
+}
+
+// appendSorted appends the heap contents to dst in ascending distance order
+// and empties the heap.
+func (h *topK[ID]) appendSorted(dst []Result[ID]) []Result[ID] {
+	start := len(dst)
+	dst = append(dst, h.items...)
+	slices.SortFunc(dst[start:], func(a, b Result[ID]) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	h.items = h.items[:0]
+	return dst
+}
+
+// MergeResults appends the k closest results across lists to dst in
+// ascending distance order. It merges through the same bounded heap a
+// search uses to combine per-worker results, so merging the top-k lists of
+// disjoint partitions gives the same answer as one search over all of them.
+func MergeResults[ID comparable](dst []Result[ID], k int, lists ...[]Result[ID]) []Result[ID] {
+	if k <= 0 {
+		return dst
+	}
+	var h topK[ID]
+	h.reset(k)
+	for _, list := range lists {
+		for _, r := range list {
+			h.offer(r)
+		}
+	}
+	return h.appendSorted(dst)
+}
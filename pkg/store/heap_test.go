@@ -0,0 +1,58 @@
+package store
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTopKMatchesSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	var h topK[string]
+	for _, k := range []int{1, 5, 10, 100} {
+		h.reset(k)
+		all := make([]float32, 500)
+		for i := range all {
+			all[i] = rng.Float32()
+			h.offer(SearchResult{Distance: all[i]})
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+		got := h.appendSorted(nil)
+		if len(got) != k {
+			t.Fatalf("k=%d: expected %d results, got %d", k, k, len(got))
+		}
+		for i := range got {
+			if got[i].Distance != all[i] {
+				t.Fatalf("k=%d: result %d = %v, want %v", k, i, got[i].Distance, all[i])
+			}
+		}
+		if h.len() != 0 {
+			t.Fatalf("k=%d: heap not emptied by appendSorted", k)
+		}
+	}
+}
+
+func TestTopKFewerThanK(t *testing.T) {
+	var h topK[string]
+	h.reset(10)
+	for _, d := range []float32{3, 1, 2} {
+		h.offer(SearchResult{Distance: d})
+	}
+	got := h.appendSorted([]SearchResult{{ID: "keep", Distance: 9}})
+	if len(got) != 4 || got[0].ID != "keep" || got[1].Distance != 1 || got[3].Distance != 3 {
+		t.Fatalf("unexpected results: %+v", got)
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	a := []SearchResult{{ID: "a1", Distance: 1}, {ID: "a4", Distance: 4}}
+	b := []SearchResult{{ID: "b2", Distance: 2}, {ID: "b3", Distance: 3}, {ID: "b5", Distance: 5}}
+	got := MergeResults(nil, 3, a, b, nil)
+	if len(got) != 3 || got[0].ID != "a1" || got[1].ID != "b2" || got[2].ID != "b3" {
+		t.Fatalf("unexpected merge: %+v", got)
+	}
+	if got := MergeResults(nil, 0, a); len(got) != 0 {
+		t.Fatalf("expected no results for k=0, got %+v", got)
+	}
+}
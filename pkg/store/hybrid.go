@@ -0,0 +1,161 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Fusion selects how SearchHybrid combines its rankings.
+type Fusion int
+
+const (
+	// FusionRRF is reciprocal rank fusion: each ranking contributes
+	// weight / (RRFK + rank), with ranks starting at 1.
+	FusionRRF Fusion = iota
+	// FusionWeighted min-max normalizes each ranking's scores to [0, 1],
+	// with the closest vector and the best text or sparse match scoring 1,
+	// and sums them by weight.
+	FusionWeighted
+)
+
+// HybridOptions configures SearchHybrid.
+type HybridOptions struct {
+	// Metric selects the vector distance. Defaults to Euclidean.
+	Metric Metric
+	// Fusion selects the fusion method. Defaults to FusionRRF.
+	Fusion Fusion
+	// Sparse, if non-empty, adds a ranking by sparse dot product with the
+	// stored sparse vectors.
+	Sparse SparseVector
+	// VectorWeight, TextWeight and SparseWeight scale the rankings. If all
+	// are zero, they weigh equally.
+	VectorWeight, TextWeight, SparseWeight float64
+	// RRFK is the FusionRRF rank constant. Zero means 60.
+	RRFK float64
+	// Candidates is how many results each ranking contributes before
+	// fusion. Zero means max(k, 50).
+	Candidates int
+}
+
+// SearchHybrid ranks vectors by fusing a k-NN search for query with a BM25
+// search for text, unless text is empty, and a sparse search for
+// opts.Sparse, unless it is empty, all taken at a single point in time.
+// Each result carries the fused Score (higher is better, and the order of
+// results) along with its vector Distance, BM25 TextScore and sparse
+// SparseScore; a component is 0 when the vector does not match it.
+func (s *VectorStore) SearchHybrid(query []float32, text string, k int, opts HybridOptions) ([]SearchResult, error) {
+	if text != "" && s.text == nil {
+		return nil, ErrTextIndexDisabled
+	}
+	if len(query) != s.dimension {
+		return nil, ErrDimensionMismatch
+	}
+	if err := opts.Sparse.Validate(); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return []SearchResult{}, nil
+	}
+	wv, wt, ws := opts.VectorWeight, opts.TextWeight, opts.SparseWeight
+	if wv == 0 && wt == 0 && ws == 0 {
+		wv, wt, ws = 1, 1, 1
+	}
+	rrfK := opts.RRFK
+	if rrfK == 0 {
+		rrfK = 60
+	}
+	depth := opts.Candidates
+	if depth <= 0 {
+		depth = max(k, 50)
+	}
+
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	now := time.Now().UnixNano()
+	set := s.layout.Load()
+	metric := opts.Metric
+	distFn := metric.scanDistance()
+
+	type fused struct {
+		res         SearchResult
+		score       float64
+		hasDistance bool
+	}
+	byID := make(map[string]*fused)
+	entry := func(id string) *fused {
+		f := byID[id]
+		if f == nil {
+			f = &fused{res: SearchResult{ID: id}}
+			byID[id] = f
+		}
+		return f
+	}
+	fuse := func(f *fused, weight float64, rank int, score, lo, hi float64) {
+		switch opts.Fusion {
+		case FusionWeighted:
+			f.score += weight * normalize(score, lo, hi)
+		default:
+			f.score += weight / (rrfK + float64(rank+1))
+		}
+	}
+
+	// Text and sparse rankings. Every matching row's score is kept so
+	// candidates from the other rankings get their component score even
+	// outside this ranking's top list.
+	rank := func(hits []rowHit, weight float64, component func(*SearchResult) *float32) {
+		sortHits(hits)
+		for _, h := range hits {
+			*component(&entry(h.rows.ids[h.row]).res) = float32(h.score)
+		}
+		top := hits[:min(depth, len(hits))]
+		for i, h := range top {
+			f := byID[h.rows.ids[h.row]]
+			fuse(f, weight, i, h.score, top[len(top)-1].score, top[0].score)
+			if !f.hasDistance {
+				f.res.Distance = metric.finalize(distFn(query, h.rows.data[int(h.row)*s.dimension:int(h.row+1)*s.dimension]))
+				f.hasDistance = true
+			}
+		}
+	}
+	if text != "" {
+		rank(s.bm25(set, text, pin.epoch, now), wt, func(r *SearchResult) *float32 { return &r.TextScore })
+	}
+	if len(opts.Sparse.Indices) > 0 {
+		rank(s.sparseScores(set, opts.Sparse, pin.epoch, now), ws, func(r *SearchResult) *float32 { return &r.SparseScore })
+	}
+
+	// Vector ranking. Closer is better, so the nearest maps to 1.
+	near := s.searchAt(nil, set, query, depth, metric, pin.epoch, now)
+	for i, r := range near {
+		f := entry(r.ID)
+		fuse(f, wv, i, -float64(r.Distance), -float64(near[len(near)-1].Distance), -float64(near[0].Distance))
+		f.res.Distance = r.Distance
+		f.hasDistance = true
+	}
+
+	out := make([]SearchResult, 0, len(byID))
+	for _, f := range byID {
+		if !f.hasDistance {
+			continue // matched below the candidate depth of every ranking
+		}
+		f.res.Score = float32(f.score)
+		out = append(out, f.res)
+	}
+	slices.SortFunc(out, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out[:min(k, len(out))], nil
+}
+
+// normalize maps x from [lo, hi] to [0, 1]; a degenerate range maps to 1.
+func normalize(x, lo, hi float64) float64 {
+	if hi <= lo {
+		return 1
+	}
+	return (x - lo) / (hi - lo)
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Get returns a copy of the vector stored under id.
+func (s *VectorStore) Get(id string) (Vector, error) {
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+
+	sh := s.layout.Load().shardFor(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	idx, exists := sh.lookup(id, pin.epoch)
+	if !exists || sh.w.expiredAt(idx, time.Now().UnixNano()) {
+		return Vector{}, ErrNotFound
+	}
+	return sh.w.vectorAt(idx, s.dimension, true), nil
+}
+
+// GetBatch returns copies of the vectors stored under ids, in request order,
+// all as of the same point in time. IDs that are not in the store are skipped.
+func (s *VectorStore) GetBatch(ids []string) []Vector {
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+
+	now := time.Now().UnixNano()
+	set := s.layout.Load()
+	out := make([]Vector, 0, len(ids))
+	for _, id := range ids {
+		sh := set.shardFor(id)
+		sh.mu.RLock()
+		if idx, exists := sh.lookup(id, pin.epoch); exists && !sh.w.expiredAt(idx, now) {
+			out = append(out, sh.w.vectorAt(idx, s.dimension, true))
+		}
+		sh.mu.RUnlock()
+	}
+	return out
+}
+
+// Contains reports whether a vector with the given id is stored.
+func (s *VectorStore) Contains(id string) bool {
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+
+	sh := s.layout.Load().shardFor(id)
+	sh.mu.RLock()
+	idx, exists := sh.lookup(id, pin.epoch)
+	exists = exists && !sh.w.expiredAt(idx, time.Now().UnixNano())
+	sh.mu.RUnlock()
+	return exists
+}
+
+// ScanOptions configures Scan.
+type ScanOptions struct {
+	// Payloads includes each vector's payload in the yielded Vector.
+	Payloads bool
+	// Expired includes vectors that have expired but not yet been reaped.
+	Expired bool
+}
+
+// Scan returns an iterator over all stored vectors, one shard at a time.
+// The whole iteration observes a single point in time across all shards.
+// Each shard's visible rows are copied before its vectors are yielded, so
+// the loop body may call back into the store.
+func (s *VectorStore) Scan(opts ScanOptions) iter.Seq[Vector] {
+	return func(yield func(Vector) bool) {
+		pin := s.clock.pin()
+		defer s.clock.unpin(pin)
+		now := time.Now().UnixNano()
+		if opts.Expired {
+			now = 0 // no row counts as expired
+		}
+
+		set := s.layout.Load()
+		for i := range set.shards {
+			rows := set.shards[i].rows.Load()
+			for _, v := range rows.snapshot(s.dimension, pin.epoch, now, opts.Payloads) {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// vectorAt copies row idx out as a Vector.
+func (r *shardRows) vectorAt(idx, dim int, withPayload bool) Vector {
+	v := Vector{
+		ID:        r.ids[idx],
+		Data:      make([]float32, dim),
+		ExpiresAt: unixTime(r.expires[idx]),
+	}
+	copy(v.Data, r.data[idx*dim:(idx+1)*dim])
+	v.Sparse = r.sparseAt(idx).clone()
+	v.Tokens = splitTokens(slices.Clone(r.tokensAt(idx, dim)), dim)
+	if withPayload {
+		v.Payload = maps.Clone(r.payloads[idx])
+	}
+	return v
+}
+
+// snapshot copies every row visible at epoch and unexpired at now; now == 0
+// keeps expired rows that have not been reaped yet. Vector data shares one
+// backing allocation to keep the copy SoA-sized.
+func (r *shardRows) snapshot(dim int, epoch uint64, now int64, withPayload bool) []Vector {
+	n := r.countAt(epoch, now)
+	data := make([]float32, 0, n*dim)
+	out := make([]Vector, 0, n)
+	for i := range r.ids {
+		if !r.aliveAt(i, epoch, now) {
+			continue
+		}
+		start := len(data)
+		data = append(data, r.data[i*dim:(i+1)*dim]...)
+		v := Vector{ID: r.ids[i], Data: data[start:len(data):len(data)], Sparse: r.sparseAt(i).clone(), Tokens: splitTokens(slices.Clone(r.tokensAt(i, dim)), dim), ExpiresAt: unixTime(r.expires[i])}
+		if withPayload {
+			v.Payload = maps.Clone(r.payloads[i])
+		}
+		out = append(out, v)
+	}
+	return out
+}
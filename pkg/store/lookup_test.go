@@ -0,0 +1,106 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "a", Data: []float32{1, 2}, Payload: Payload{"doc": "x"}})
+	s.Insert(Vector{ID: "b", Data: []float32{3, 4}})
+
+	v, err := s.Get("a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if v.ID != "a" || v.Data[0] != 1 || v.Data[1] != 2 || v.Payload["doc"] != "x" {
+		t.Fatalf("unexpected vector: %+v", v)
+	}
+
+	// The returned data is a copy.
+	v.Data[0] = 100
+	v.Payload["doc"] = "changed"
+	again, _ := s.Get("a")
+	if again.Data[0] != 1 || again.Payload["doc"] != "x" {
+		t.Fatalf("Get returned shared memory: %+v", again)
+	}
+
+	if _, err := s.Get("missing"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// Upsert replaces data and payload; delete makes it unreachable.
+	s.Insert(Vector{ID: "a", Data: []float32{5, 6}})
+	v, _ = s.Get("a")
+	if v.Data[0] != 5 || v.Payload != nil {
+		t.Fatalf("unexpected vector after update: %+v", v)
+	}
+	s.Delete("a")
+	if _, err := s.Get("a"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if v, _ := s.Get("b"); v.Data[0] != 3 {
+		t.Fatalf("unexpected vector b: %+v", v)
+	}
+}
+
+func TestGetBatchAndContains(t *testing.T) {
+	s := NewVectorStore(1)
+	for i := 0; i < 10; i++ {
+		s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(i)}})
+	}
+
+	got := s.GetBatch([]string{"v-7", "missing", "v-2"})
+	if len(got) != 2 || got[0].ID != "v-7" || got[0].Data[0] != 7 || got[1].ID != "v-2" {
+		t.Fatalf("unexpected batch: %+v", got)
+	}
+
+	if !s.Contains("v-3") || s.Contains("v-10") {
+		t.Fatal("Contains reported wrong membership")
+	}
+}
+
+func TestScan(t *testing.T) {
+	s := NewVectorStore(2, WithShards(4))
+	n := 100
+	for i := 0; i < n; i++ {
+		s.Insert(Vector{
+			ID:      fmt.Sprintf("v-%d", i),
+			Data:    []float32{float32(i), float32(-i)},
+			Payload: Payload{"i": i},
+		})
+	}
+
+	seen := make(map[string]bool)
+	for v := range s.Scan(ScanOptions{Payloads: true}) {
+		var i int
+		fmt.Sscanf(v.ID, "v-%d", &i)
+		if v.Data[0] != float32(i) || v.Data[1] != float32(-i) || v.Payload["i"] != i {
+			t.Fatalf("unexpected vector from Scan: %+v", v)
+		}
+		seen[v.ID] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("expected %d vectors, scanned %d", n, len(seen))
+	}
+
+	for v := range s.Scan(ScanOptions{}) {
+		if v.Payload != nil {
+			t.Fatalf("expected no payload, got %+v", v.Payload)
+		}
+	}
+
+	// Early break and writes from the loop body are allowed.
+	count := 0
+	for v := range s.Scan(ScanOptions{}) {
+		s.Delete(v.ID)
+		count++
+		if count == 10 {
+			break
+		}
+	}
+	if s.Count() != n-10 {
+		t.Fatalf("expected %d after deleting during scan, got %d", n-10, s.Count())
+	}
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"vexor/pkg/distance"
+)
+
+// A multi-vector document stores its token vectors back to back in the
+// shard's tokens buffer, in row order, so scoring a document reads one
+// contiguous run the way a dense scan reads data. Its Data row holds the
+// pooled embedding. SearchMultiVector first lets every query token nominate
+// the documents whose pooled embedding it is most similar to, which costs
+// one dot product per document rather than one per token, then scores only
+// those candidates exactly. A document without tokens counts as a single
+// token, its Data.
+
+// rowVectors returns the Data row to store for v, pooling its tokens when
+// Data is empty, and v's tokens flattened into a new slice.
+func (s *VectorStore) rowVectors(v Vector) ([]float32, []float32) {
+	if len(v.Tokens) == 0 {
+		return v.Data, nil
+	}
+	dim := s.dimension
+	tokens := make([]float32, 0, len(v.Tokens)*dim)
+	for _, t := range v.Tokens {
+		tokens = append(tokens, t...)
+	}
+	if len(v.Data) > 0 {
+		return v.Data, tokens
+	}
+	mean := make([]float32, dim)
+	for j := 0; j < len(tokens); j += dim {
+		for i, x := range tokens[j : j+dim] {
+			mean[i] += x
+		}
+	}
+	for i := range mean {
+		mean[i] /= float32(len(v.Tokens))
+	}
+	return mean, tokens
+}
+
+// appendTokens stores the tokens of the row just appended. The offsets are
+// created by the first row that has tokens; earlier rows have none.
+func (r *shardRows) appendTokens(tokens []float32, dim int) {
+	if r.tokenEnd == nil {
+		r.tokenEnd = make([]int, len(r.ids)-1, cap(r.ids))
+	}
+	r.tokens = append(r.tokens, tokens...)
+	r.tokenEnd = append(r.tokenEnd, len(r.tokens)/dim)
+}
+
+// tokensAt returns row idx's token vectors, shared with the row, or nil if
+// it has none.
+func (r *shardRows) tokensAt(idx, dim int) []float32 {
+	if idx >= len(r.tokenEnd) {
+		return nil
+	}
+	start, end := 0, r.tokenEnd[idx]
+	if idx > 0 {
+		start = r.tokenEnd[idx-1]
+	}
+	if start == end {
+		return nil
+	}
+	return r.tokens[start*dim : end*dim]
+}
+
+// splitTokens slices flat token vectors into one slice per token, sharing
+// tokens' memory.
+func splitTokens(tokens []float32, dim int) [][]float32 {
+	if len(tokens) == 0 {
+		return nil
+	}
+	out := make([][]float32, 0, len(tokens)/dim)
+	for j := 0; j < len(tokens); j += dim {
+		out = append(out, tokens[j:j+dim:j+dim])
+	}
+	return out
+}
+
+func cloneTokens(tokens [][]float32) [][]float32 {
+	if len(tokens) == 0 {
+		return nil
+	}
+	out := make([][]float32, len(tokens))
+	for i, t := range tokens {
+		out[i] = slices.Clone(t)
+	}
+	return out
+}
+
+// MultiVectorOptions configures SearchMultiVector.
+type MultiVectorOptions struct {
+	// Candidates is how many documents each query token nominates, by dot
+	// product with their pooled Data, for exact scoring. Zero means
+	// max(4k, 64).
+	Candidates int
+	// Exhaustive skips candidate generation and scores every document.
+	Exhaustive bool
+}
+
+// rowRef identifies a row in a published shardRows.
+type rowRef struct {
+	rows *shardRows
+	row  int32
+}
+
+// SearchMultiVector returns the k documents with the highest late
+// interaction (MaxSim) score for the query token vectors: the sum over
+// query tokens of the largest dot product with any of the document's
+// tokens. Each result's Score holds the MaxSim score; Distance is unset.
+// Unless opts.Exhaustive is set, only the candidates nominated by the
+// query tokens are scored, so a document that no token nominates is missed
+// even if its exact score would rank.
+func (s *VectorStore) SearchMultiVector(query [][]float32, k int, opts MultiVectorOptions) ([]SearchResult, error) {
+	for _, q := range query {
+		if len(q) != s.dimension {
+			return nil, ErrDimensionMismatch
+		}
+	}
+	if k <= 0 || len(query) == 0 {
+		return []SearchResult{}, nil
+	}
+	depth := opts.Candidates
+	if depth <= 0 {
+		depth = max(4*k, 64)
+	}
+
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	now := time.Now().UnixNano()
+	set := s.layout.Load()
+	dim := s.dimension
+	nWorkers := set.numWorkers()
+
+	// Heaps keep the negated score as their distance.
+	heaps := make([]topK[string], nWorkers)
+	for i := range heaps {
+		heaps[i].reset(k)
+	}
+	score := func(h *topK[string], rows *shardRows, i int) {
+		tokens := rows.tokensAt(i, dim)
+		if tokens == nil {
+			tokens = rows.data[i*dim : (i+1)*dim]
+		}
+		if d := -maxSim(query, tokens, dim); h.wants(d) {
+			h.offer(SearchResult{ID: rows.ids[i], Distance: d})
+		}
+	}
+	if opts.Exhaustive {
+		set.scanParallel(nWorkers, func(workerID int, rows *shardRows) bool {
+			all := rows.allAliveAt(pin.epoch, now)
+			for i := range rows.ids {
+				if all || rows.aliveAt(i, pin.epoch, now) {
+					score(&heaps[workerID], rows, i)
+				}
+			}
+			return true
+		})
+	} else {
+		cands := s.multiVectorCandidates(set, query, depth, pin.epoch, now)
+		parallelRanges(len(cands), min(nWorkers, max(len(cands), 1)), func(workerID, start, end int) {
+			for _, c := range cands[start:end] {
+				score(&heaps[workerID], c.rows, int(c.row))
+			}
+		})
+	}
+
+	var final topK[string]
+	final.reset(k)
+	for i := range heaps {
+		for _, r := range heaps[i].items {
+			final.offer(r)
+		}
+	}
+	out := final.appendSorted(nil)
+	for i := range out {
+		out[i].Score = -out[i].Distance
+		out[i].Distance = 0
+	}
+	slices.SortFunc(out, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+// multiVectorCandidates returns the union of the depth rows whose Data has
+// the largest dot product with each query token, among rows visible at
+// epoch and unexpired at now.
+func (s *VectorStore) multiVectorCandidates(set *shardSet, query [][]float32, depth int, epoch uint64, now int64) []rowRef {
+	dim := s.dimension
+	nWorkers := set.numWorkers()
+	heaps := make([][]topK[rowRef], nWorkers)
+	for w := range heaps {
+		heaps[w] = make([]topK[rowRef], len(query))
+		for q := range query {
+			heaps[w][q].reset(depth)
+		}
+	}
+	set.scanParallel(nWorkers, func(workerID int, rows *shardRows) bool {
+		hs := heaps[workerID]
+		all := rows.allAliveAt(epoch, now)
+		for i := range rows.ids {
+			if !all && !rows.aliveAt(i, epoch, now) {
+				continue
+			}
+			vec := rows.data[i*dim : (i+1)*dim]
+			for q, tok := range query {
+				if d := -distance.DotProduct(tok, vec); hs[q].wants(d) {
+					hs[q].offer(Result[rowRef]{ID: rowRef{rows, int32(i)}, Distance: d})
+				}
+			}
+		}
+		return true
+	})
+
+	seen := make(map[rowRef]bool)
+	var out []rowRef
+	for q := range query {
+		var h topK[rowRef]
+		h.reset(depth)
+		for w := range heaps {
+			for _, r := range heaps[w][q].items {
+				h.offer(r)
+			}
+		}
+		for _, r := range h.items {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				out = append(out, r.ID)
+			}
+		}
+	}
+	return out
+}
+
+// maxSim returns the sum over query tokens of the largest dot product with
+// any of the flat token vectors.
+func maxSim(query [][]float32, tokens []float32, dim int) float32 {
+	var total float32
+	for _, q := range query {
+		best := float32(math.Inf(-1))
+		for j := 0; j < len(tokens); j += dim {
+			best = max(best, distance.DotProduct(q, tokens[j:j+dim]))
+		}
+		total += best
+	}
+	return total
+}
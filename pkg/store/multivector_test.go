@@ -0,0 +1,147 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func randomTokens(rng *rand.Rand, n, dim int) [][]float32 {
+	tokens := make([][]float32, n)
+	for i := range tokens {
+		tokens[i] = make([]float32, dim)
+		for j := range tokens[i] {
+			tokens[i][j] = rng.Float32()*2 - 1
+		}
+	}
+	return tokens
+}
+
+func TestMultiVectorStored(t *testing.T) {
+	s := NewVectorStore(2)
+	tokens := [][]float32{{1, 0}, {3, 4}}
+	if err := s.Insert(Vector{ID: "doc", Tokens: tokens}); err != nil {
+		t.Fatal(err)
+	}
+	tokens[0][0] = 99 // the store keeps its own copy
+
+	v, err := s.Get("doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v.Tokens, [][]float32{{1, 0}, {3, 4}}) || !reflect.DeepEqual(v.Data, []float32{2, 2}) {
+		t.Errorf("Get: %+v, want tokens kept and Data pooled", v)
+	}
+
+	if err := s.Insert(Vector{ID: "bad", Tokens: [][]float32{{1, 0}, {1}}}); err != ErrDimensionMismatch {
+		t.Errorf("expected ErrDimensionMismatch for a short token, got %v", err)
+	}
+	if err := s.Insert(Vector{ID: "bad", Data: []float32{1}, Tokens: [][]float32{{1, 0}}}); err != ErrDimensionMismatch {
+		t.Errorf("expected ErrDimensionMismatch for short Data, got %v", err)
+	}
+	if err := s.Insert(Vector{ID: "bad"}); err != ErrDimensionMismatch {
+		t.Errorf("expected ErrDimensionMismatch without Data or Tokens, got %v", err)
+	}
+	if _, err := s.SearchMultiVector([][]float32{{1}}, 1, MultiVectorOptions{}); err != ErrDimensionMismatch {
+		t.Errorf("expected ErrDimensionMismatch for the query, got %v", err)
+	}
+}
+
+func TestSearchMultiVector(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "a", Tokens: [][]float32{{1, 0}, {0, 1}}})
+	s.Insert(Vector{ID: "b", Tokens: [][]float32{{1, 0}, {1, 0}, {1, 0}}})
+	s.Insert(Vector{ID: "single", Data: []float32{0.5, 0.5}})
+
+	// MaxSim for a: 1 + 1; for b: 1 + 0; for single: 0.5 + 0.5.
+	res, err := s.SearchMultiVector([][]float32{{1, 0}, {0, 1}}, 3, MultiVectorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SearchResult{{ID: "a", Score: 2}, {ID: "b", Score: 1}, {ID: "single", Score: 1}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+}
+
+func TestSearchMultiVectorMixedShard(t *testing.T) {
+	// Dense rows that share a shard with token rows still count as one token.
+	s := NewVectorStore(2, WithShards(1))
+	s.Insert(Vector{ID: "dense1", Data: []float32{1, 0}})
+	s.Insert(Vector{ID: "multi", Tokens: [][]float32{{0, 1}, {0, -1}}})
+	s.Insert(Vector{ID: "dense2", Data: []float32{1, 0}})
+
+	res, err := s.SearchMultiVector([][]float32{{1, 0}}, 3, MultiVectorOptions{Exhaustive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SearchResult{{ID: "dense1", Score: 1}, {ID: "dense2", Score: 1}, {ID: "multi", Score: 0}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+	if v, _ := s.Get("dense2"); v.Tokens != nil {
+		t.Errorf("dense row reports tokens %v", v.Tokens)
+	}
+}
+
+func TestSearchMultiVectorCandidates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const dim = 8
+	s := NewVectorStore(dim, WithShards(4))
+	for i := range 600 {
+		s.Insert(Vector{ID: fmt.Sprintf("d%d", i), Tokens: randomTokens(rng, 1+rng.Intn(12), dim)})
+	}
+	for i := 0; i < 600; i += 3 {
+		s.Delete(fmt.Sprintf("d%d", i))
+	}
+	query := randomTokens(rng, 4, dim)
+
+	exact, err := s.SearchMultiVector(query, 10, MultiVectorOptions{Exhaustive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Scoring every document agrees with brute force.
+	best := ""
+	var bestScore float32
+	for v := range s.Scan(ScanOptions{}) {
+		var flat []float32
+		for _, tok := range v.Tokens {
+			flat = append(flat, tok...)
+		}
+		if score := maxSim(query, flat, dim); best == "" || score > bestScore {
+			best, bestScore = v.ID, score
+		}
+	}
+	if exact[0].ID != best || exact[0].Score != bestScore {
+		t.Fatalf("exhaustive top result %+v, brute force %s %v", exact[0], best, bestScore)
+	}
+
+	// Nominating every document gives the exhaustive answer.
+	all, _ := s.SearchMultiVector(query, 10, MultiVectorOptions{Candidates: 600})
+	if !reflect.DeepEqual(all, exact) {
+		t.Errorf("full candidate set: got %v, want %v", all, exact)
+	}
+
+	// A small candidate set still finds most of the top results.
+	approx, _ := s.SearchMultiVector(query, 10, MultiVectorOptions{Candidates: 40})
+	found := 0
+	for _, r := range approx {
+		for _, e := range exact {
+			if r.ID == e.ID {
+				found++
+			}
+		}
+	}
+	if found < 5 {
+		t.Errorf("candidate search found %d of the top 10", found)
+	}
+
+	// Token vectors survive compaction and resharding.
+	if err := s.Reshard(3); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.SearchMultiVector(query, 10, MultiVectorOptions{Exhaustive: true}); !reflect.DeepEqual(got, exact) {
+		t.Errorf("after reshard: got %v, want %v", got, exact)
+	}
+}
@@ -0,0 +1,362 @@
+package store
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Every write is stamped with a version from the store's epochClock. Rows are
+// never updated in place: an upsert tombstones the old row and appends a new
+// one, and a delete only tombstones. A reader pins the current visible
+// version (its epoch), loads each shard's published rows without locking,
+// and sees exactly the rows with
+//
+//	created <= epoch && (deleted == 0 || deleted > epoch)
+//
+// so a search and a Count observe one point in time across all shards, and a
+// write spanning several shards (Rename) becomes visible all at once.
+// Writers serialize per shard on shard.mu, edit the shard's working version
+// and publish it as a new immutable header. Tombstoned rows are removed by a
+// copy-on-write compaction once no pinned epoch can still see them.
+
+// compactMinDead is the minimum number of tombstones a shard accumulates
+// before a write triggers a compaction pass.
+const compactMinDead = 32
+
+// readerSlots is the number of lock-free reader pin slots. Readers beyond
+// this many at once fall back to a mutex-protected map.
+const readerSlots = 128
+
+// epochClock hands out write versions and tracks which of them are visible.
+// Writers serialize on mu; readers pin epochs through atomic slots and only
+// take mu when every slot is busy.
+type epochClock struct {
+	mu       sync.Mutex
+	last     uint64              // last version handed out by begin
+	inflight map[uint64]struct{} // versions begun but not yet committed
+	overflow map[uint64]int      // epochs pinned by readers without a slot
+	// log, when the change log is enabled, receives each version's changes
+	// once the version becomes visible; pending holds them until then.
+	log     *changeLog
+	pending map[uint64][]Change
+	// visible is the newest version such that it and every older version
+	// have committed. Readers pin it as their epoch.
+	visible atomic.Uint64
+
+	slots    [readerSlots]paddedSlot // pinned epoch + 1, 0 when free
+	nextSlot atomic.Uint32
+}
+
+// paddedSlot keeps each reader slot on its own cache line.
+type paddedSlot struct {
+	epoch atomic.Uint64
+	_     [56]byte
+}
+
+// readPin is a reader's pinned epoch, released with epochClock.unpin.
+type readPin struct {
+	epoch uint64
+	slot  int // -1 when pinned in the overflow map
+}
+
+func newEpochClock() *epochClock {
+	return &epochClock{
+		inflight: make(map[uint64]struct{}),
+		overflow: make(map[uint64]int),
+		pending:  make(map[uint64][]Change),
+	}
+}
+
+// begin allocates the version for a write. Callers must hold the write lock
+// of every shard the write touches, so versions within a shard are applied
+// in order.
+func (c *epochClock) begin() uint64 {
+	c.mu.Lock()
+	c.last++
+	v := c.last
+	c.inflight[v] = struct{}{}
+	c.mu.Unlock()
+	return v
+}
+
+// commit marks version v as fully applied and advances the visible version
+// past every committed prefix. changes are the mutations v applied; they
+// reach the change log in version order as the visible version passes them.
+func (c *epochClock) commit(v uint64, changes []Change) {
+	c.mu.Lock()
+	delete(c.inflight, v)
+	if len(changes) > 0 && c.log != nil {
+		c.pending[v] = changes
+	}
+	visible := c.last
+	for pending := range c.inflight {
+		if pending <= visible {
+			visible = pending - 1
+		}
+	}
+	// Publish the version before logging its changes, so a change in the
+	// log is always visible to a reader that pins after reading it.
+	prev := c.visible.Load()
+	c.visible.Store(visible)
+	if c.log != nil {
+		for ver := prev + 1; ver <= visible; ver++ {
+			if ch, ok := c.pending[ver]; ok {
+				c.log.append(ch)
+				delete(c.pending, ver)
+			}
+		}
+	}
+	c.mu.Unlock()
+}
+
+// awaitLog waits until the changes of every visible version are in the log.
+// commit advances visible and logs its changes under c.mu, so once the lock
+// is free nothing visible is left unlogged.
+func (c *epochClock) awaitLog() {
+	c.mu.Lock()
+	c.mu.Unlock()
+}
+
+// pin returns the current visible version and keeps rows visible at it from
+// being compacted until unpin.
+//
+// After publishing its slot the reader re-reads visible and retries until
+// the two agree. horizon reads visible before scanning the slots, so either
+// it sees this slot or the epoch it would have missed is at least the
+// visible value horizon started from.
+func (c *epochClock) pin() readPin {
+	start := int(c.nextSlot.Add(1))
+	for n := 0; n < readerSlots; n++ {
+		i := (start + n) % readerSlots
+		slot := &c.slots[i].epoch
+		e := c.visible.Load()
+		if !slot.CompareAndSwap(0, e+1) {
+			continue
+		}
+		for {
+			again := c.visible.Load()
+			if again == e {
+				return readPin{epoch: e, slot: i}
+			}
+			e = again
+			slot.Store(e + 1)
+		}
+	}
+
+	c.mu.Lock()
+	e := c.visible.Load()
+	c.overflow[e]++
+	c.mu.Unlock()
+	return readPin{epoch: e, slot: -1}
+}
+
+func (c *epochClock) unpin(p readPin) {
+	if p.slot >= 0 {
+		c.slots[p.slot].epoch.Store(0)
+		return
+	}
+	c.mu.Lock()
+	if c.overflow[p.epoch] <= 1 {
+		delete(c.overflow, p.epoch)
+	} else {
+		c.overflow[p.epoch]--
+	}
+	c.mu.Unlock()
+}
+
+// horizon returns the oldest epoch any current or future reader can observe.
+// Rows deleted at or before it are invisible to everyone.
+func (c *epochClock) horizon() uint64 {
+	h := c.visible.Load()
+	for i := range c.slots {
+		if e := c.slots[i].epoch.Load(); e != 0 && e-1 < h {
+			h = e - 1
+		}
+	}
+	c.mu.Lock()
+	for e := range c.overflow {
+		if e < h {
+			h = e
+		}
+	}
+	c.mu.Unlock()
+	return h
+}
+
+// visibleAt reports whether row i exists at epoch. deleted is written in
+// place by writers, so it is read atomically.
+func (r *shardRows) visibleAt(i int, epoch uint64) bool {
+	d := atomic.LoadUint64(&r.deleted[i])
+	return r.created[i] <= epoch && (d == 0 || d > epoch)
+}
+
+// allVisibleAt reports whether every row exists at epoch, which lets scans
+// skip the per-row version check. Later tombstones carry versions newer than
+// any epoch that could have loaded these rows, so they do not invalidate it.
+func (r *shardRows) allVisibleAt(epoch uint64) bool {
+	return r.dead == 0 && r.lastVersion <= epoch
+}
+
+// countAt returns the number of rows visible at epoch and unexpired at now.
+func (r *shardRows) countAt(epoch uint64, now int64) int {
+	if r.lastVersion <= epoch && !r.mayExpireBy(now) {
+		return r.live
+	}
+	n := 0
+	for i := range r.ids {
+		if r.aliveAt(i, epoch, now) {
+			n++
+		}
+	}
+	return n
+}
+
+// lookup returns the row holding id at epoch in the writer's version.
+// Caller holds sh.mu.
+func (sh *shard) lookup(id string, epoch uint64) (int, bool) {
+	w := &sh.w
+	idx, exists := sh.idIndex[id]
+	if exists && w.created[idx] <= epoch {
+		return idx, true
+	}
+	if w.lastVersion <= epoch {
+		// Nothing in this shard changed after epoch, so the index is exact.
+		return 0, false
+	}
+	// id was written after epoch; find the row version the reader sees.
+	for i, rowID := range w.ids {
+		if rowID == id && w.visibleAt(i, epoch) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// publish makes the writer's version visible to lock-free readers.
+// Caller holds sh.mu.
+func (sh *shard) publish() {
+	r := sh.w
+	sh.rows.Store(&r)
+}
+
+// appendRow adds a live row created at version. Appends write past the end
+// of every published version, so readers never observe them until the next
+// publish. Caller holds sh.mu.
+func (sh *shard) appendRow(id string, vec []float32, sparse SparseVector, tokens []float32, payload Payload, expires int64, version uint64) {
+	sh.idIndex[id] = len(sh.w.ids)
+	sh.w.appendVersion(id, vec, sparse, tokens, payload, expires, version, 0)
+	sh.w.lastVersion = version
+}
+
+// appendVersion appends a row with explicit versions without touching
+// lastVersion. Reshard uses it to carry rows, tombstones included, into a
+// new layout.
+func (r *shardRows) appendVersion(id string, vec []float32, sparse SparseVector, tokens []float32, payload Payload, expires int64, created, deleted uint64) {
+	r.ids = append(r.ids, id)
+	r.data = append(r.data, vec...)
+	r.payloads = append(r.payloads, payload)
+	r.created = append(r.created, created)
+	r.deleted = append(r.deleted, deleted)
+	r.expires = append(r.expires, expires)
+	if expires != 0 && (r.minExpiry == 0 || expires < r.minExpiry) {
+		r.minExpiry = expires
+	}
+	if deleted != 0 {
+		r.dead++
+	} else {
+		r.live++
+	}
+	if r.sparse != nil || len(sparse.Indices) > 0 {
+		r.indexSparse(sparse)
+	}
+	if r.tokenEnd != nil || len(tokens) > 0 {
+		r.appendTokens(tokens, len(vec))
+	}
+	if r.text != nil {
+		r.indexRow(payload, deleted)
+	}
+}
+
+// markDeleted tombstones live row idx at version. The row may be visible
+// to readers, so the version is stored atomically. Caller holds sh.mu.
+func (sh *shard) markDeleted(idx int, version uint64) {
+	w := &sh.w
+	atomic.StoreUint64(&w.deleted[idx], version)
+	delete(sh.idIndex, w.ids[idx])
+	w.unindexRow(idx)
+	w.live--
+	w.dead++
+	w.lastVersion = version
+}
+
+// maybeCompact removes tombstones once enough have accumulated. The next
+// pass is scheduled a quarter-shard of tombstones later, so a reader pinning
+// an old epoch cannot turn every write into a full-shard scan.
+// Caller holds sh.mu.
+func (sh *shard) maybeCompact(dim int, clock *epochClock) {
+	if sh.w.dead < sh.compactAt {
+		return
+	}
+	sh.compact(dim, clock.horizon())
+	sh.compactAt = sh.w.dead + max(len(sh.w.ids)/4, compactMinDead)
+}
+
+// compact copies the rows that some reader may still see into fresh arrays,
+// dropping rows deleted at or before horizon. Published versions keep their
+// arrays, so readers scanning them are unaffected. Caller holds sh.mu.
+func (sh *shard) compact(dim int, horizon uint64) {
+	old := &sh.w
+	keep := len(old.ids)
+	for i := range old.ids {
+		if d := old.deleted[i]; d != 0 && d <= horizon {
+			keep--
+		}
+	}
+	if keep == len(old.ids) {
+		return
+	}
+
+	next := shardRows{
+		ids:         make([]string, 0, keep),
+		data:        make([]float32, 0, keep*dim),
+		payloads:    make([]Payload, 0, keep),
+		created:     make([]uint64, 0, keep),
+		deleted:     make([]uint64, 0, keep),
+		expires:     make([]int64, 0, keep),
+		lastVersion: old.lastVersion,
+	}
+	if old.text != nil {
+		next.text = newTextIndex(old.text.cfg)
+		next.textLen = make([]int32, 0, keep)
+	}
+	for i, id := range old.ids {
+		d := old.deleted[i]
+		if d != 0 && d <= horizon {
+			continue
+		}
+		if d == 0 {
+			sh.idIndex[id] = len(next.ids)
+		}
+		next.appendVersion(id, old.data[i*dim:(i+1)*dim], old.sparseAt(i), old.tokensAt(i, dim), old.payloads[i], old.expires[i], old.created[i], d)
+	}
+	sh.w = next
+}
+
+// lockShards write-locks the shards at the given indexes in ascending order,
+// so concurrent multi-shard writes cannot deadlock. It returns the sorted,
+// deduplicated indexes to pass to unlockShards.
+func (set *shardSet) lockShards(indexes []int) []int {
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+	for _, i := range indexes {
+		set.shards[i].mu.Lock()
+	}
+	return indexes
+}
+
+func (set *shardSet) unlockShards(indexes []int) {
+	for _, i := range indexes {
+		set.shards[i].mu.Unlock()
+	}
+}
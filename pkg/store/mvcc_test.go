@@ -0,0 +1,246 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEpochClockOutOfOrderCommit(t *testing.T) {
+	c := newEpochClock()
+	v1 := c.begin()
+	v2 := c.begin()
+
+	c.commit(v2, nil)
+	if got := c.visible.Load(); got != v1-1 {
+		t.Fatalf("v2 visible before v1 committed: visible=%d", got)
+	}
+	c.commit(v1, nil)
+	if got := c.visible.Load(); got != v2 {
+		t.Fatalf("expected visible=%d, got %d", v2, got)
+	}
+
+	p := c.pin()
+	v3 := c.begin()
+	c.commit(v3, nil)
+	if h := c.horizon(); h != p.epoch {
+		t.Fatalf("expected horizon pinned at %d, got %d", p.epoch, h)
+	}
+	c.unpin(p)
+	if h := c.horizon(); h != v3 {
+		t.Fatalf("expected horizon %d after unpin, got %d", v3, h)
+	}
+}
+
+// TestPinnedEpochSeesOldVersion checks a reader's epoch keeps seeing the
+// data that existed when it was pinned.
+func TestPinnedEpochSeesOldVersion(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "a", Data: []float32{1, 1}})
+	s.Insert(Vector{ID: "b", Data: []float32{2, 2}})
+
+	pin := s.clock.pin()
+	epoch := pin.epoch
+	s.Insert(Vector{ID: "a", Data: []float32{9, 9}})
+	s.Delete("b")
+	s.Insert(Vector{ID: "c", Data: []float32{3, 3}})
+
+	set := s.layout.Load()
+	sh := set.shardFor("a")
+	idx, ok := sh.lookup("a", epoch)
+	if !ok || sh.w.data[idx*2] != 1 {
+		t.Fatalf("pinned epoch did not see old version of a")
+	}
+	if _, ok := set.shardFor("b").lookup("b", epoch); !ok {
+		t.Fatal("pinned epoch did not see deleted b")
+	}
+	if _, ok := set.shardFor("c").lookup("c", epoch); ok {
+		t.Fatal("pinned epoch saw c inserted after it")
+	}
+	total := 0
+	for i := range set.shards {
+		total += set.shards[i].rows.Load().countAt(epoch, 0)
+	}
+	if total != 2 {
+		t.Fatalf("expected 2 vectors at pinned epoch, got %d", total)
+	}
+	s.clock.unpin(pin)
+
+	// New readers see the latest state.
+	if v, _ := s.Get("a"); v.Data[0] != 9 {
+		t.Fatalf("expected updated a, got %+v", v)
+	}
+	if s.Contains("b") || !s.Contains("c") || s.Count() != 2 {
+		t.Fatal("unexpected state after writes")
+	}
+}
+
+// TestCompaction checks tombstones from updates and deletes are reclaimed.
+func TestCompaction(t *testing.T) {
+	s := NewVectorStore(2, WithShards(1))
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 100; i++ {
+			s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(round), 0}})
+		}
+	}
+	for i := 0; i < 50; i++ {
+		s.Delete(fmt.Sprintf("v-%d", i))
+	}
+
+	sh := &s.layout.Load().shards[0]
+	if rows := len(sh.rows.Load().ids); rows > 100 {
+		t.Fatalf("expected at most 100 physical rows after compaction, got %d", rows)
+	}
+	if s.Count() != 50 {
+		t.Fatalf("expected 50, got %d", s.Count())
+	}
+	for i := 50; i < 100; i++ {
+		v, err := s.Get(fmt.Sprintf("v-%d", i))
+		if err != nil || v.Data[0] != 49 {
+			t.Fatalf("v-%d: unexpected %+v, %v", i, v, err)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "a", Data: []float32{1, 2}, Payload: Payload{"k": "v"}})
+	s.Insert(Vector{ID: "b", Data: []float32{3, 4}})
+
+	if err := s.Rename("a", "z"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if s.Contains("a") {
+		t.Fatal("old ID still present after Rename")
+	}
+	v, err := s.Get("z")
+	if err != nil || v.Data[0] != 1 || v.Payload["k"] != "v" {
+		t.Fatalf("unexpected renamed vector: %+v, %v", v, err)
+	}
+
+	// Renaming onto an existing ID replaces it.
+	if err := s.Rename("z", "b"); err != nil {
+		t.Fatalf("Rename onto existing failed: %v", err)
+	}
+	if v, _ := s.Get("b"); v.Data[0] != 1 || s.Count() != 1 {
+		t.Fatalf("unexpected state after replacing rename: %+v, count=%d", v, s.Count())
+	}
+
+	if err := s.Rename("missing", "x"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.Rename("b", ""); err != ErrEmptyID {
+		t.Fatalf("expected ErrEmptyID, got %v", err)
+	}
+}
+
+// TestSnapshotIsolationAcrossShards renames vectors back and forth between
+// IDs in different shards while readers check every vector is seen exactly
+// once by Count, Scan and Search.
+func TestSnapshotIsolationAcrossShards(t *testing.T) {
+	const n = 64
+	s := NewVectorStore(2, WithShards(16))
+	for i := 0; i < n; i++ {
+		s.Insert(Vector{ID: fmt.Sprintf("x-%d", i), Data: []float32{float32(i), 0}})
+	}
+
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for i := w; i < n; i += 4 {
+					if s.Rename(fmt.Sprintf("x-%d", i), fmt.Sprintf("y-%d", i)) != nil {
+						s.Rename(fmt.Sprintf("y-%d", i), fmt.Sprintf("x-%d", i))
+					}
+				}
+			}
+		}(w)
+	}
+
+	for iter := 0; iter < 200; iter++ {
+		if c := s.Count(); c != n {
+			t.Fatalf("Count saw %d vectors, want %d", c, n)
+		}
+		seen := make(map[float32]int)
+		for v := range s.Scan(ScanOptions{}) {
+			seen[v.Data[0]]++
+		}
+		if len(seen) != n {
+			t.Fatalf("Scan saw %d distinct vectors, want %d", len(seen), n)
+		}
+		for x, c := range seen {
+			if c != 1 {
+				t.Fatalf("Scan saw vector %v %d times", x, c)
+			}
+		}
+		results, _ := s.Search([]float32{0, 0}, n)
+		if len(results) != n {
+			t.Fatalf("Search returned %d vectors, want %d", len(results), n)
+		}
+	}
+	close(stop)
+	writers.Wait()
+}
+
+// TestSearchDoesNotBlockOnWriters holds every shard's writer lock and checks
+// searches and Count still complete against the published rows.
+func TestSearchDoesNotBlockOnWriters(t *testing.T) {
+	s := NewVectorStore(2, WithShards(4))
+	for i := 0; i < 100; i++ {
+		s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(i), 0}})
+	}
+
+	set := s.layout.Load()
+	for i := range set.shards {
+		set.shards[i].mu.Lock()
+	}
+	done := make(chan []SearchResult)
+	go func() {
+		results, _ := s.Search([]float32{0, 0}, 3)
+		s.Count()
+		done <- results
+	}()
+	results := <-done
+	for i := range set.shards {
+		set.shards[i].mu.Unlock()
+	}
+
+	if len(results) != 3 || results[0].ID != "v-0" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+// TestPinOverflow pins more readers than there are slots.
+func TestPinOverflow(t *testing.T) {
+	c := newEpochClock()
+	c.commit(c.begin(), nil)
+
+	pins := make([]readPin, readerSlots+10)
+	for i := range pins {
+		pins[i] = c.pin()
+	}
+	if pins[len(pins)-1].slot != -1 {
+		t.Fatalf("expected overflow pin, got slot %d", pins[len(pins)-1].slot)
+	}
+	c.commit(c.begin(), nil)
+	if h := c.horizon(); h != 1 {
+		t.Fatalf("expected horizon 1 while pinned, got %d", h)
+	}
+	for _, p := range pins {
+		c.unpin(p)
+	}
+	if h := c.horizon(); h != 2 {
+		t.Fatalf("expected horizon 2 after unpin, got %d", h)
+	}
+	if len(c.overflow) != 0 {
+		t.Fatalf("overflow map not drained: %v", c.overflow)
+	}
+}
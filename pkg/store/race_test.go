@@ -0,0 +1,7 @@
+//go:build race
+
+package store
+
+// raceEnabled reports whether tests run under the race detector, which
+// randomly drops sync.Pool entries and so defeats allocation checks.
+const raceEnabled = true
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"iter"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// RangeOptions configures SearchRange.
+type RangeOptions struct {
+	// Metric selects the distance function. Defaults to Euclidean.
+	Metric Metric
+	// Limit caps the number of results. Zero means no cap.
+	Limit int
+	// Sorted returns results in ascending distance order. Combined with
+	// Limit, the Limit closest matches are returned; without Sorted the scan
+	// stops as soon as Limit matches have been found.
+	Sorted bool
+}
+
+// SearchRange returns all vectors whose distance to query is at most radius.
+// Matches are collected by the same shard-parallel workers as Search;
+// SearchRangeSeq yields them as they are found instead.
+func (s *VectorStore) SearchRange(query []float32, radius float32, opts RangeOptions) ([]SearchResult, error) {
+	if len(query) != s.dimension {
+		return nil, ErrDimensionMismatch
+	}
+	if radius < 0 {
+		return nil, ErrNegativeRadius
+	}
+
+	dim := s.dimension
+	metric := opts.Metric
+	distFn := metric.scanDistance()
+	bound := metric.toScan(radius)
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	epoch := pin.epoch
+	now := time.Now().UnixNano()
+	set := s.layout.Load()
+	nWorkers := set.numWorkers()
+
+	// A sorted, limited search keeps the Limit closest matches per worker.
+	// An unsorted, limited search claims result slots from a shared counter
+	// and lets every worker stop once the cap is reached.
+	bounded := opts.Limit > 0 && opts.Sorted
+	var claimed atomic.Int64
+
+	matches := make([][]SearchResult, nWorkers)
+	heaps := make([]topK[string], nWorkers)
+	for i := range heaps {
+		heaps[i].reset(opts.Limit)
+	}
+	set.scanParallel(nWorkers, func(workerID int, rows *shardRows) bool {
+		m := &matches[workerID]
+		n := len(rows.ids)
+		all := rows.allAliveAt(epoch, now)
+		for i := 0; i < n; i++ {
+			if !all && !rows.aliveAt(i, epoch, now) {
+				continue
+			}
+			dist := distFn(query, rows.data[i*dim:(i+1)*dim])
+			if dist > bound {
+				continue
+			}
+			r := SearchResult{ID: rows.ids[i], Distance: dist}
+			switch {
+			case bounded:
+				heaps[workerID].offer(r)
+			case opts.Limit > 0:
+				if claimed.Add(1) > int64(opts.Limit) {
+					return false
+				}
+				*m = append(*m, r)
+			default:
+				*m = append(*m, r)
+			}
+		}
+		return true
+	})
+
+	if bounded {
+		for i := range heaps {
+			matches[i] = heaps[i].items
+		}
+	}
+
+	total := 0
+	for _, m := range matches {
+		total += len(m)
+	}
+	results := make([]SearchResult, 0, total)
+	for _, m := range matches {
+		for _, r := range m {
+			r.Distance = metric.finalize(r.Distance)
+			results = append(results, r)
+		}
+	}
+
+	if opts.Sorted {
+		sort.Slice(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	}
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// SearchRangeSeq is the streaming form of SearchRange: the returned iterator
+// yields each match as the scan finds it, one shard at a time, so the first
+// results arrive before the scan is done and stopping early skips the rest
+// of it. The whole iteration observes a single point in time, and the loop
+// body may call back into the store. Results come in no particular order;
+// opts.Sorted needs every match before the first, so it is rejected with
+// ErrSortedStream. opts.Limit stops the iteration after that many results.
+func (s *VectorStore) SearchRangeSeq(query []float32, radius float32, opts RangeOptions) (iter.Seq[SearchResult], error) {
+	if len(query) != s.dimension {
+		return nil, ErrDimensionMismatch
+	}
+	if radius < 0 {
+		return nil, ErrNegativeRadius
+	}
+	if opts.Sorted {
+		return nil, ErrSortedStream
+	}
+
+	dim := s.dimension
+	metric := opts.Metric
+	distFn := metric.scanDistance()
+	bound := metric.toScan(radius)
+	return func(yield func(SearchResult) bool) {
+		pin := s.clock.pin()
+		defer s.clock.unpin(pin)
+		now := time.Now().UnixNano()
+
+		found := 0
+		set := s.layout.Load()
+		for i := range set.shards {
+			// Published rows never change, so no lock is held while the
+			// loop body runs.
+			rows := set.shards[i].rows.Load()
+			all := rows.allAliveAt(pin.epoch, now)
+			for j := range rows.ids {
+				if !all && !rows.aliveAt(j, pin.epoch, now) {
+					continue
+				}
+				dist := distFn(query, rows.data[j*dim:(j+1)*dim])
+				if dist > bound {
+					continue
+				}
+				if !yield(SearchResult{ID: rows.ids[j], Distance: metric.finalize(dist)}) {
+					return
+				}
+				if found++; found == opts.Limit {
+					return
+				}
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+)
+
+func newLineStore(n int) *VectorStore {
+	s := NewVectorStore(2)
+	for i := 0; i < n; i++ {
+		s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(i), 0}})
+	}
+	return s
+}
+
+func TestSearchRangeEuclidean(t *testing.T) {
+	s := newLineStore(100)
+
+	results, err := s.SearchRange([]float32{50, 0}, 3, RangeOptions{Sorted: true})
+	if err != nil {
+		t.Fatalf("SearchRange failed: %v", err)
+	}
+	// v-47 .. v-53 are within distance 3 (inclusive).
+	if len(results) != 7 {
+		t.Fatalf("expected 7 results, got %d: %+v", len(results), results)
+	}
+	if results[0].ID != "v-50" || results[0].Distance != 0 {
+		t.Fatalf("expected v-50 first, got %+v", results[0])
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Distance < results[i-1].Distance {
+			t.Fatalf("results not sorted: %+v", results)
+		}
+	}
+	if last := results[len(results)-1].Distance; last != 3 {
+		t.Fatalf("expected furthest distance 3, got %v", last)
+	}
+}
+
+func TestSearchRangeUnsorted(t *testing.T) {
+	s := newLineStore(100)
+
+	results, err := s.SearchRange([]float32{10, 0}, 2.5, RangeOptions{})
+	if err != nil {
+		t.Fatalf("SearchRange failed: %v", err)
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	sort.Strings(ids)
+	want := []string{"v-10", "v-11", "v-12", "v-8", "v-9"}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, ids)
+	}
+}
+
+func TestSearchRangeLimit(t *testing.T) {
+	s := newLineStore(100)
+
+	// Sorted with a limit returns the closest matches.
+	results, _ := s.SearchRange([]float32{50.1, 0}, 10, RangeOptions{Limit: 3, Sorted: true})
+	if len(results) != 3 || results[0].ID != "v-50" || results[1].ID != "v-51" || results[2].ID != "v-49" {
+		t.Fatalf("unexpected sorted limited results: %+v", results)
+	}
+
+	// Unsorted with a limit returns any matches up to the cap.
+	results, _ = s.SearchRange([]float32{50, 0}, 10, RangeOptions{Limit: 4})
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Distance > 10 {
+			t.Fatalf("result outside radius: %+v", r)
+		}
+	}
+}
+
+func TestSearchRangeCosine(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "same_dir", Data: []float32{2, 0}})
+	s.Insert(Vector{ID: "close", Data: []float32{1, 0.1}})
+	s.Insert(Vector{ID: "perp", Data: []float32{0, 1}})
+	s.Insert(Vector{ID: "opposite", Data: []float32{-1, 0}})
+
+	results, err := s.SearchRange([]float32{1, 0}, 0.1, RangeOptions{Metric: Cosine, Sorted: true})
+	if err != nil {
+		t.Fatalf("SearchRange failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "same_dir" || results[1].ID != "close" {
+		t.Fatalf("unexpected cosine range results: %+v", results)
+	}
+	if math.Abs(float64(results[0].Distance)) > 1e-6 {
+		t.Fatalf("expected ~0 distance for same direction, got %v", results[0].Distance)
+	}
+}
+
+func TestSearchRangeErrors(t *testing.T) {
+	s := newLineStore(10)
+	if _, err := s.SearchRange([]float32{1}, 1, RangeOptions{}); err != ErrDimensionMismatch {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := s.SearchRange([]float32{1, 0}, -1, RangeOptions{}); err != ErrNegativeRadius {
+		t.Fatalf("expected ErrNegativeRadius, got %v", err)
+	}
+	results, err := s.SearchRange([]float32{1000, 0}, 1, RangeOptions{})
+	if err != nil || len(results) != 0 {
+		t.Fatalf("expected no results, got %v, %+v", err, results)
+	}
+}
+
+func TestSearchRangeSeq(t *testing.T) {
+	s := newLineStore(100)
+
+	seq, err := s.SearchRangeSeq([]float32{10, 0}, 2.5, RangeOptions{})
+	if err != nil {
+		t.Fatalf("SearchRangeSeq failed: %v", err)
+	}
+	var ids []string
+	for r := range seq {
+		// The loop body may write to the store; the iteration keeps its
+		// point in time.
+		s.Insert(Vector{ID: "x-" + r.ID, Data: []float32{10, 0}})
+		ids = append(ids, r.ID)
+	}
+	sort.Strings(ids)
+	if want := []string{"v-10", "v-11", "v-12", "v-8", "v-9"}; fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, ids)
+	}
+
+	seq, _ = s.SearchRangeSeq([]float32{50, 0}, 10, RangeOptions{Limit: 4})
+	n := 0
+	for r := range seq {
+		if r.Distance > 10 {
+			t.Fatalf("result outside radius: %+v", r)
+		}
+		n++
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 results with Limit, got %d", n)
+	}
+	for range seq {
+		break // stopping early is fine
+	}
+
+	if _, err := s.SearchRangeSeq([]float32{1, 0}, 1, RangeOptions{Sorted: true}); err != ErrSortedStream {
+		t.Fatalf("expected ErrSortedStream, got %v", err)
+	}
+	if _, err := s.SearchRangeSeq([]float32{1}, 1, RangeOptions{}); err != ErrDimensionMismatch {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoExamples     = errors.New("recommendation needs at least one example")
+	ErrNegativeWeight = errors.New("example weight cannot be negative")
+)
+
+// Example is a positive or negative example for Recommend: the vector
+// stored under ID, or Vector if it is set.
+type Example struct {
+	ID     string
+	Vector []float32
+	// Weight scales the example's influence. Zero means 1.
+	Weight float64
+}
+
+// RecommendStrategy selects how Recommend combines its examples.
+type RecommendStrategy int
+
+const (
+	// RecommendAverage searches for one Rocchio-style query vector: the
+	// weighted mean of the positives, moved away from the weighted mean of
+	// the negatives by the distance between the two, avgP + (avgP - avgN).
+	// Without negatives it is the mean of the positives. It needs at least
+	// one positive.
+	RecommendAverage RecommendStrategy = iota
+	// RecommendBestScore scores every vector by its similarity to the
+	// closest positive minus its similarity to the closest negative,
+	// where similarity is weight / (1 + distance). Without positives it
+	// finds the vectors farthest from every negative.
+	RecommendBestScore
+)
+
+// RecommendOptions configures Recommend. The embedded SearchOptions apply
+// as they do to SearchWithOptions, except that RecommendBestScore ignores
+// MMR and GroupBy.
+type RecommendOptions struct {
+	SearchOptions
+	Strategy RecommendStrategy
+}
+
+// example is an Example resolved to its vector.
+type example struct {
+	vec    []float32
+	weight float64
+}
+
+// Recommend returns the k vectors most like the positive examples and
+// least like the negative ones, never returning an example given by ID.
+// Examples given by ID are read at the same point in time as the search;
+// a missing one fails with ErrNotFound. RecommendBestScore results carry
+// their score in Score and their distance to the closest positive in
+// Distance.
+func (s *VectorStore) Recommend(positive, negative []Example, k int, opts RecommendOptions) ([]SearchResult, error) {
+	if len(positive) == 0 && (len(negative) == 0 || opts.Strategy == RecommendAverage) {
+		return nil, ErrNoExamples
+	}
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	now := time.Now().UnixNano()
+	set := s.layout.Load()
+
+	search := opts.SearchOptions
+	search.Exclude = slices.Clip(search.Exclude)
+	resolve := func(examples []Example) ([]example, error) {
+		out := make([]example, len(examples))
+		for i, e := range examples {
+			if e.Weight < 0 {
+				return nil, ErrNegativeWeight
+			}
+			out[i] = example{vec: e.Vector, weight: e.Weight}
+			if out[i].weight == 0 {
+				out[i].weight = 1
+			}
+			if e.Vector == nil {
+				vec, ok := s.lookupVector(set, e.ID, pin.epoch, now)
+				if !ok {
+					return nil, fmt.Errorf("example %q: %w", e.ID, ErrNotFound)
+				}
+				out[i].vec = vec
+				search.Exclude = append(search.Exclude, e.ID)
+			} else if len(e.Vector) != s.dimension {
+				return nil, ErrDimensionMismatch
+			}
+		}
+		return out, nil
+	}
+	pos, err := resolve(positive)
+	if err != nil {
+		return nil, err
+	}
+	neg, err := resolve(negative)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return []SearchResult{}, nil
+	}
+
+	if opts.Strategy == RecommendBestScore {
+		return s.bestScore(set, pos, neg, k, search, pin.epoch, now), nil
+	}
+	query := weightedMean(pos, s.dimension)
+	if len(neg) > 0 {
+		avgN := weightedMean(neg, s.dimension)
+		for i := range query {
+			query[i] += query[i] - avgN[i]
+		}
+	}
+	return s.searchWith(set, query, k, search, pin.epoch, now), nil
+}
+
+func weightedMean(examples []example, dim int) []float32 {
+	mean := make([]float64, dim)
+	var total float64
+	for _, e := range examples {
+		for i, x := range e.vec {
+			mean[i] += e.weight * float64(x)
+		}
+		total += e.weight
+	}
+	out := make([]float32, dim)
+	for i := range out {
+		out[i] = float32(mean[i] / total)
+	}
+	return out
+}
+
+// bestScore scans every vector for RecommendBestScore. The heaps keep the
+// negated score as their distance.
+func (s *VectorStore) bestScore(set *shardSet, pos, neg []example, k int, opts SearchOptions, epoch uint64, now int64) []SearchResult {
+	dim := s.dimension
+	metric := opts.Metric
+	distFn := metric.scanDistance()
+	skip := excludeSet(opts.Exclude)
+	closest := func(examples []example, vec []float32) float64 {
+		best := 0.0
+		for _, e := range examples {
+			d := float64(metric.finalize(distFn(e.vec, vec)))
+			best = max(best, e.weight/(1+d))
+		}
+		return best
+	}
+
+	nWorkers := set.numWorkers()
+	sc := getScratch[rowRef](&rowScratchPool, nWorkers, k)
+	set.scanParallel(nWorkers, func(workerID int, rows *shardRows) bool {
+		h := &sc.heaps[workerID]
+		all := rows.allAliveAt(epoch, now)
+		for i := range rows.ids {
+			if !all && !rows.aliveAt(i, epoch, now) {
+				continue
+			}
+			vec := rows.data[i*dim : (i+1)*dim]
+			score := float32(closest(pos, vec) - closest(neg, vec))
+			if h.wants(-score) && !skip[rows.ids[i]] {
+				h.offer(Result[rowRef]{ID: rowRef{rows, int32(i)}, Distance: -score})
+			}
+		}
+		return true
+	})
+	for i := range sc.heaps {
+		for _, r := range sc.heaps[i].items {
+			sc.final.offer(r)
+		}
+	}
+	hits := sc.final.appendSorted(nil)
+	putScratch(&rowScratchPool, sc)
+
+	out := make([]SearchResult, len(hits))
+	for i, h := range hits {
+		r := SearchResult{ID: h.ID.rows.ids[h.ID.row], Score: -h.Distance}
+		if len(pos) > 0 {
+			vec := h.ID.vector(dim)
+			d := float32(math.Inf(1))
+			for _, e := range pos {
+				d = min(d, metric.finalize(distFn(e.vec, vec)))
+			}
+			r.Distance = d
+		}
+		out[i] = r
+	}
+	slices.SortFunc(out, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
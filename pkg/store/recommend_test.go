@@ -0,0 +1,94 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func recommendStore() *VectorStore {
+	s := NewVectorStore(2, WithShards(2))
+	s.Insert(Vector{ID: "liked", Data: []float32{1, 0}})
+	s.Insert(Vector{ID: "disliked", Data: []float32{0, 1}})
+	s.Insert(Vector{ID: "similar", Data: []float32{0.9, 0.1}})
+	s.Insert(Vector{ID: "between", Data: []float32{0.6, 0.6}})
+	s.Insert(Vector{ID: "away", Data: []float32{1.5, -0.5}})
+	s.Insert(Vector{ID: "near-disliked", Data: []float32{0.1, 0.9}})
+	return s
+}
+
+func TestRecommendAverage(t *testing.T) {
+	s := recommendStore()
+	pos := []Example{{ID: "liked"}}
+
+	res, err := s.Recommend(pos, nil, 2, RecommendOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"similar", "away"}) {
+		t.Errorf("positives only: %v", got)
+	}
+
+	// The negative moves the query to (2, -1), away from disliked.
+	res, _ = s.Recommend(pos, []Example{{ID: "disliked"}}, 2, RecommendOptions{})
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"away", "similar"}) {
+		t.Errorf("with a negative: %v", got)
+	}
+
+	// Weights pull the mean; example vectors are not excluded.
+	res, _ = s.Recommend([]Example{{ID: "liked"}, {Vector: []float32{0, 1}, Weight: 3}}, nil, 2, RecommendOptions{})
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"near-disliked", "disliked"}) {
+		t.Errorf("weighted: %v", got)
+	}
+
+	// Search options apply to the combined query.
+	res, _ = s.Recommend(pos, nil, 1, RecommendOptions{SearchOptions: SearchOptions{Exclude: []string{"similar"}}})
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"away"}) {
+		t.Errorf("with exclusions: %v", got)
+	}
+
+	// Grouping leaves out the group of an example that is its only member.
+	s.Insert(Vector{ID: "liked", Data: []float32{1, 0}, Payload: Payload{"kind": "liked"}})
+	s.Insert(Vector{ID: "similar", Data: []float32{0.9, 0.1}, Payload: Payload{"kind": "other"}})
+	res, err = s.Recommend(pos, nil, 2, RecommendOptions{SearchOptions: SearchOptions{GroupBy: "kind"}})
+	if got := textIDs(res); err != nil || !reflect.DeepEqual(got, []string{"similar"}) {
+		t.Errorf("grouped: %v, %v", got, err)
+	}
+}
+
+func TestRecommendBestScore(t *testing.T) {
+	s := recommendStore()
+	opts := RecommendOptions{Strategy: RecommendBestScore}
+	res, err := s.Recommend([]Example{{ID: "liked"}}, []Example{{ID: "disliked"}}, 3, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"similar", "away", "between"}) {
+		t.Errorf("got %v", got)
+	}
+	if res[0].Score <= res[1].Score || res[0].Distance <= 0.14 || res[0].Distance >= 0.15 {
+		t.Errorf("scores and distances: %+v", res)
+	}
+
+	// Negatives alone find what is farthest from them.
+	res, _ = s.Recommend(nil, []Example{{ID: "disliked"}}, 1, opts)
+	if len(res) != 1 || res[0].ID != "away" || res[0].Distance != 0 {
+		t.Errorf("negatives only: %+v", res)
+	}
+}
+
+func TestRecommendErrors(t *testing.T) {
+	s := recommendStore()
+	if _, err := s.Recommend(nil, []Example{{ID: "disliked"}}, 1, RecommendOptions{}); err != ErrNoExamples {
+		t.Errorf("expected ErrNoExamples, got %v", err)
+	}
+	if _, err := s.Recommend([]Example{{ID: "missing"}}, nil, 1, RecommendOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Recommend([]Example{{Vector: []float32{1}}}, nil, 1, RecommendOptions{}); err != ErrDimensionMismatch {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := s.Recommend([]Example{{ID: "liked", Weight: -1}}, nil, 1, RecommendOptions{}); err != ErrNegativeWeight {
+		t.Errorf("expected ErrNegativeWeight, got %v", err)
+	}
+}
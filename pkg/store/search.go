@@ -0,0 +1,351 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoGroupField is returned by SearchGroups without SearchOptions.GroupBy.
+var ErrNoGroupField = errors.New("no group-by field")
+
+// SearchOptions configures SearchWithOptions.
+type SearchOptions struct {
+	// Metric selects the distance function. Defaults to Euclidean.
+	Metric Metric
+	// MMR re-ranks the Candidates nearest vectors by maximal marginal
+	// relevance, trading closeness to the query against closeness to the
+	// results already picked, so near-duplicates do not crowd out the rest.
+	MMR bool
+	// Lambda weighs relevance against diversity for MMR, from 1 (nearest
+	// first, as without MMR) down towards 0 (most diverse). Zero means 0.5.
+	Lambda float64
+	// Candidates is how many nearest vectors MMR picks from. Zero means 4k;
+	// fewer than k means k.
+	Candidates int
+	// GroupBy groups results by this payload field: k counts groups, each
+	// holding up to GroupSize of its nearest vectors. Vectors without the
+	// field, or whose value is not a string, number or bool, are skipped.
+	// MMR does not apply to grouped searches.
+	GroupBy string
+	// GroupSize is how many hits each group keeps. Zero means 1.
+	GroupSize int
+	// Exclude lists IDs the search skips as if they were not stored, so
+	// the k results are found among the remaining vectors.
+	Exclude []string
+}
+
+// SearchGroup is one group of a grouped search: the payload value its
+// vectors share and its nearest hits in ascending distance order. Numeric
+// values are float64.
+type SearchGroup struct {
+	Value any
+	Hits  []SearchResult
+}
+
+// SearchWithOptions performs a k-NN search configured by opts. Results are
+// in ascending distance order, or in MMR pick order with opts.MMR. With
+// opts.GroupBy they are the hits of SearchGroups, group after group.
+func (s *VectorStore) SearchWithOptions(query []float32, k int, opts SearchOptions) ([]SearchResult, error) {
+	if len(query) != s.dimension {
+		return nil, ErrDimensionMismatch
+	}
+	if k <= 0 {
+		return []SearchResult{}, nil
+	}
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	return s.searchWith(s.layout.Load(), query, k, opts, pin.epoch, time.Now().UnixNano()), nil
+}
+
+// SearchByID searches for the k vectors nearest to the one stored under
+// id, excluding id itself, as SearchWithOptions would with its vector as
+// the query. It returns ErrNotFound if id is not stored.
+func (s *VectorStore) SearchByID(id string, k int, opts SearchOptions) ([]SearchResult, error) {
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	now := time.Now().UnixNano()
+	set := s.layout.Load()
+
+	query, ok := s.lookupVector(set, id, pin.epoch, now)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if k <= 0 {
+		return []SearchResult{}, nil
+	}
+	opts.Exclude = append(slices.Clip(opts.Exclude), id)
+	return s.searchWith(set, query, k, opts, pin.epoch, now), nil
+}
+
+// lookupVector copies the vector stored under id as seen at epoch and now.
+func (s *VectorStore) lookupVector(set *shardSet, id string, epoch uint64, now int64) ([]float32, bool) {
+	sh := set.shardFor(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	idx, exists := sh.lookup(id, epoch)
+	if !exists || sh.w.expiredAt(idx, now) {
+		return nil, false
+	}
+	dim := s.dimension
+	return slices.Clone(sh.w.data[idx*dim : (idx+1)*dim]), true
+}
+
+// searchWith runs SearchWithOptions at epoch and now.
+func (s *VectorStore) searchWith(set *shardSet, query []float32, k int, opts SearchOptions, epoch uint64, now int64) []SearchResult {
+	skip := excludeSet(opts.Exclude)
+	if opts.GroupBy != "" {
+		var out []SearchResult
+		for _, g := range s.groupsAt(set, query, k, opts, skip, epoch, now) {
+			out = append(out, g.Hits...)
+		}
+		return out
+	}
+	depth := k
+	if opts.MMR {
+		depth = opts.Candidates
+		if depth == 0 {
+			depth = 4 * k
+		}
+		depth = max(depth, k)
+	}
+
+	hits := s.scanRows(set, query, depth, opts.Metric, skip, epoch, now)
+	if opts.MMR {
+		lambda := opts.Lambda
+		if lambda == 0 {
+			lambda = 0.5
+		}
+		hits = s.mmr(hits, k, lambda, opts.Metric)
+	}
+
+	out := make([]SearchResult, len(hits))
+	for i, h := range hits {
+		out[i] = SearchResult{ID: h.ID.rows.ids[h.ID.row], Distance: h.Distance}
+	}
+	return out
+}
+
+// excludeSet returns ids as a set, or nil if there are none.
+func excludeSet(ids []string) map[string]bool {
+	if len(ids) == 0 {
+		return nil
+	}
+	skip := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		skip[id] = true
+	}
+	return skip
+}
+
+// SearchGroups returns the k groups, by the payload field opts.GroupBy,
+// whose nearest vector is closest to query, nearest group first, each with
+// its opts.GroupSize nearest hits. Ties between groups go to the smaller
+// ID of their nearest hit.
+func (s *VectorStore) SearchGroups(query []float32, k int, opts SearchOptions) ([]SearchGroup, error) {
+	if len(query) != s.dimension {
+		return nil, ErrDimensionMismatch
+	}
+	if opts.GroupBy == "" {
+		return nil, ErrNoGroupField
+	}
+	if k <= 0 {
+		return []SearchGroup{}, nil
+	}
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	return s.groupsAt(s.layout.Load(), query, k, opts, excludeSet(opts.Exclude), pin.epoch, time.Now().UnixNano()), nil
+}
+
+// groupsAt runs SearchGroups at epoch and now, skipping the IDs in skip.
+func (s *VectorStore) groupsAt(set *shardSet, query []float32, k int, opts SearchOptions, skip map[string]bool, epoch uint64, now int64) []SearchGroup {
+	size := max(opts.GroupSize, 1)
+	heaps := s.scanGroups(set, query, opts.GroupBy, size, opts.Metric, skip, epoch, now)
+
+	groups := make([]SearchGroup, 0, len(heaps))
+	for key, h := range heaps {
+		hits := h.appendSorted(make([]Result[rowRef], 0, h.len()))
+		g := SearchGroup{Value: key, Hits: make([]SearchResult, len(hits))}
+		for i, hit := range hits {
+			g.Hits[i] = SearchResult{ID: hit.ID.rows.ids[hit.ID.row], Distance: opts.Metric.finalize(hit.Distance)}
+		}
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b SearchGroup) int {
+		if c := cmp.Compare(a.Hits[0].Distance, b.Hits[0].Distance); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Hits[0].ID, b.Hits[0].ID)
+	})
+	return groups[:min(k, len(groups))]
+}
+
+// scanGroups scans like searchAt, but each worker keeps a bounded heap per
+// group value instead of one heap, and the workers' heaps are merged group
+// by group. Distances are scan distances.
+func (s *VectorStore) scanGroups(set *shardSet, query []float32, field string, size int, metric Metric, skip map[string]bool, epoch uint64, now int64) map[any]*topK[rowRef] {
+	dim := s.dimension
+	distFn := metric.scanDistance()
+	nWorkers := set.numWorkers()
+
+	perWorker := make([]map[any]*topK[rowRef], nWorkers)
+	set.scanParallel(nWorkers, func(workerID int, rows *shardRows) bool {
+		groups := perWorker[workerID]
+		if groups == nil {
+			groups = make(map[any]*topK[rowRef])
+			perWorker[workerID] = groups
+		}
+		all := rows.allAliveAt(epoch, now)
+		for i := range rows.ids {
+			if !all && !rows.aliveAt(i, epoch, now) {
+				continue
+			}
+			key, ok := groupKey(rows.payloads[i][field])
+			if !ok || skip[rows.ids[i]] {
+				continue
+			}
+			h := groups[key]
+			if h == nil {
+				h = &topK[rowRef]{}
+				h.reset(size)
+				groups[key] = h
+			}
+			dist := distFn(query, rows.data[i*dim:(i+1)*dim])
+			if h.wants(dist) {
+				h.offer(Result[rowRef]{ID: rowRef{rows, int32(i)}, Distance: dist})
+			}
+		}
+		return true
+	})
+
+	merged := make(map[any]*topK[rowRef])
+	for _, groups := range perWorker {
+		for key, h := range groups {
+			into := merged[key]
+			if into == nil {
+				merged[key] = h
+				continue
+			}
+			for _, r := range h.items {
+				into.offer(r)
+			}
+		}
+	}
+	return merged
+}
+
+// groupKey returns the map key grouping a payload value: strings and bools
+// as they are, numbers as float64.
+func groupKey(v any) (any, bool) {
+	switch v := v.(type) {
+	case string, bool, float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return nil, false
+}
+
+var rowScratchPool = sync.Pool{
+	New: func() any { return new(searchScratch[rowRef]) },
+}
+
+// scanRows is searchAt for searches that need the rows of their results
+// after the scan: it keeps row references rather than IDs. IDs in skip are
+// passed over; they are only looked up for rows the heap would keep.
+// Results are in ascending order of final distance.
+func (s *VectorStore) scanRows(set *shardSet, query []float32, k int, metric Metric, skip map[string]bool, epoch uint64, now int64) []Result[rowRef] {
+	dim := s.dimension
+	distFn := metric.scanDistance()
+	nWorkers := set.numWorkers()
+
+	sc := getScratch[rowRef](&rowScratchPool, nWorkers, k)
+	set.scanParallel(nWorkers, func(workerID int, rows *shardRows) bool {
+		h := &sc.heaps[workerID]
+		all := rows.allAliveAt(epoch, now)
+		for i := range rows.ids {
+			if !all && !rows.aliveAt(i, epoch, now) {
+				continue
+			}
+			dist := distFn(query, rows.data[i*dim:(i+1)*dim])
+			if h.wants(dist) && !skip[rows.ids[i]] {
+				h.offer(Result[rowRef]{ID: rowRef{rows, int32(i)}, Distance: dist})
+			}
+		}
+		return true
+	})
+	for i := range sc.heaps {
+		for _, r := range sc.heaps[i].items {
+			sc.final.offer(r)
+		}
+	}
+	out := sc.final.appendSorted(make([]Result[rowRef], 0, sc.final.len()))
+	for i := range out {
+		out[i].Distance = metric.finalize(out[i].Distance)
+	}
+	putScratch(&rowScratchPool, sc)
+	return out
+}
+
+// vector returns the row's stored vector, shared with the row.
+func (r rowRef) vector(dim int) []float32 {
+	return r.rows.data[int(r.row)*dim : int(r.row+1)*dim]
+}
+
+// mmr picks k of hits, which are in ascending distance order, by maximal
+// marginal relevance. Similarity is negated distance, so each step picks
+// the hit maximizing
+//
+//	-lambda*dist(query, hit) + (1-lambda)*min dist(hit, picked)
+//
+// with ties going to the nearer hit.
+func (s *VectorStore) mmr(hits []Result[rowRef], k int, lambda float64, metric Metric) []Result[rowRef] {
+	dim := s.dimension
+	distFn := metric.scanDistance()
+	// nearest[i] is hit i's distance to the closest picked hit.
+	nearest := make([]float64, len(hits))
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+	picked := make([]bool, len(hits))
+	out := make([]Result[rowRef], 0, min(k, len(hits)))
+	for len(out) < cap(out) {
+		best, bestScore := -1, math.Inf(-1)
+		for i, h := range hits {
+			if picked[i] {
+				continue
+			}
+			score := -lambda * float64(h.Distance)
+			if len(out) > 0 {
+				score += (1 - lambda) * nearest[i]
+			}
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		picked[best] = true
+		out = append(out, hits[best])
+		vec := hits[best].ID.vector(dim)
+		for i, h := range hits {
+			if !picked[i] {
+				d := float64(metric.finalize(distFn(h.ID.vector(dim), vec)))
+				nearest[i] = min(nearest[i], d)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSearchWithOptionsMatchesSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := NewVectorStore(4, WithShards(3))
+	for i := range 500 {
+		s.Insert(Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{rng.Float32(), rng.Float32(), rng.Float32(), rng.Float32()}})
+	}
+	query := []float32{0.5, 0.5, 0.5, 0.5}
+	for _, metric := range []Metric{Euclidean, Cosine} {
+		want, _ := s.searchInto(nil, query, 10, metric)
+		got, err := s.SearchWithOptions(query, 10, SearchOptions{Metric: metric})
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("metric %d: got %v, %v, want %v", metric, got, err, want)
+		}
+	}
+	if _, err := s.SearchWithOptions([]float32{1}, 1, SearchOptions{}); err != ErrDimensionMismatch {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestSearchMMR(t *testing.T) {
+	s := NewVectorStore(2)
+	// Near-duplicate chunks closest to the query, then two distinct ones.
+	s.Insert(Vector{ID: "dup1", Data: []float32{1, 0}})
+	s.Insert(Vector{ID: "dup2", Data: []float32{1, 0.01}})
+	s.Insert(Vector{ID: "dup3", Data: []float32{1, -0.02}})
+	s.Insert(Vector{ID: "up", Data: []float32{0, 1}})
+	s.Insert(Vector{ID: "down", Data: []float32{0.2, -1}})
+	query := []float32{0.9, 0}
+
+	plain, _ := s.SearchWithOptions(query, 3, SearchOptions{})
+	if got := textIDs(plain); !reflect.DeepEqual(got, []string{"dup1", "dup2", "dup3"}) {
+		t.Fatalf("plain search: %v", got)
+	}
+	res, err := s.SearchWithOptions(query, 3, SearchOptions{MMR: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"dup1", "up", "down"}) {
+		t.Errorf("MMR: got %v", got)
+	}
+	if res[0].Distance != plain[0].Distance || res[1].Distance <= res[0].Distance {
+		t.Errorf("MMR results should keep their query distances: %+v", res)
+	}
+
+	// Lambda 1 is plain relevance order.
+	if res, _ := s.SearchWithOptions(query, 3, SearchOptions{MMR: true, Lambda: 1}); !reflect.DeepEqual(res, plain) {
+		t.Errorf("lambda 1: got %v, want %v", res, plain)
+	}
+	// Candidates bound the pool MMR picks from: among the duplicates alone
+	// it still prefers the one farther from dup1.
+	if res, _ := s.SearchWithOptions(query, 3, SearchOptions{MMR: true, Candidates: 3}); !reflect.DeepEqual(textIDs(res), []string{"dup1", "dup3", "dup2"}) {
+		t.Errorf("3 candidates: got %v", textIDs(res))
+	}
+}
+
+func TestSearchGroups(t *testing.T) {
+	s := NewVectorStore(1, WithShards(4))
+	// Document a has the four nearest chunks; b and c are farther.
+	for i := range 4 {
+		s.Insert(Vector{ID: fmt.Sprintf("a%d", i), Data: []float32{float32(i)}, Payload: Payload{"doc": "a"}})
+	}
+	s.Insert(Vector{ID: "b0", Data: []float32{5}, Payload: Payload{"doc": "b"}})
+	s.Insert(Vector{ID: "c0", Data: []float32{7}, Payload: Payload{"doc": "c"}})
+	s.Insert(Vector{ID: "c1", Data: []float32{6}, Payload: Payload{"doc": "c"}})
+	s.Insert(Vector{ID: "n0", Data: []float32{8}, Payload: Payload{"doc": 3}})
+	s.Insert(Vector{ID: "n1", Data: []float32{9}, Payload: Payload{"doc": 3.0}})
+	s.Insert(Vector{ID: "none", Data: []float32{0}})
+
+	groups, err := s.SearchGroups([]float32{0}, 3, SearchOptions{GroupBy: "doc", GroupSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SearchGroup{
+		{Value: "a", Hits: []SearchResult{{ID: "a0", Distance: 0}, {ID: "a1", Distance: 1}}},
+		{Value: "b", Hits: []SearchResult{{ID: "b0", Distance: 5}}},
+		{Value: "c", Hits: []SearchResult{{ID: "c1", Distance: 6}, {ID: "c0", Distance: 7}}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got %+v, want %+v", groups, want)
+	}
+
+	// Integer and float values group together.
+	groups, _ = s.SearchGroups([]float32{10}, 1, SearchOptions{GroupBy: "doc", GroupSize: 5})
+	if len(groups) != 1 || groups[0].Value != 3.0 || len(groups[0].Hits) != 2 {
+		t.Errorf("numeric group: %+v", groups)
+	}
+
+	flat, _ := s.SearchWithOptions([]float32{0}, 2, SearchOptions{GroupBy: "doc"})
+	if got := textIDs(flat); !reflect.DeepEqual(got, []string{"a0", "b0"}) {
+		t.Errorf("flattened groups: %v", got)
+	}
+	if _, err := s.SearchGroups([]float32{0}, 2, SearchOptions{}); err != ErrNoGroupField {
+		t.Errorf("expected ErrNoGroupField, got %v", err)
+	}
+}
+
+func TestSearchByIDAndExclude(t *testing.T) {
+	s := NewVectorStore(1, WithShards(4))
+	for i := range 10 {
+		s.Insert(Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{float32(i)}, Payload: Payload{"parity": i % 2}})
+	}
+
+	res, err := s.SearchByID("v5", 3, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// v4 and v6 tie at distance 1, then v3 and v7 at 2; v5 itself is left out.
+	if len(res) != 3 || res[0].Distance != 1 || res[1].Distance != 1 || res[2].Distance != 2 {
+		t.Errorf("SearchByID: %+v", res)
+	}
+
+	// Exclusions are skipped during the scan, so k results still come back.
+	res, _ = s.SearchByID("v5", 3, SearchOptions{Exclude: []string{"v4", "v6", "v3", "v7"}})
+	if len(res) != 3 || res[0].Distance != 3 || res[2].Distance != 4 {
+		t.Errorf("SearchByID with exclusions: %+v", res)
+	}
+	res, _ = s.SearchWithOptions([]float32{0}, 2, SearchOptions{Exclude: []string{"v0", "v1"}})
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"v2", "v3"}) {
+		t.Errorf("Exclude: %v", got)
+	}
+	groups, err := s.SearchGroups([]float32{0}, 2, SearchOptions{GroupBy: "parity", Exclude: []string{"v0"}})
+	if len(groups) != 2 || groups[0].Hits[0].ID != "v1" || groups[1].Hits[0].ID != "v2" {
+		t.Errorf("grouped Exclude: %+v", groups)
+	}
+
+	// An excluded vector alone in its group leaves no empty group behind.
+	s.Insert(Vector{ID: "lone", Data: []float32{20}, Payload: Payload{"parity": "lone"}})
+	groups, err = s.SearchGroups([]float32{20}, 3, SearchOptions{GroupBy: "parity", Exclude: []string{"lone"}})
+	if err != nil || len(groups) != 2 || groups[0].Value != 1.0 {
+		t.Errorf("groups without their only member: %+v, %v", groups, err)
+	}
+	res, _ = s.SearchByID("lone", 2, SearchOptions{GroupBy: "parity"})
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"v9", "v8"}) {
+		t.Errorf("grouped SearchByID: %v", got)
+	}
+
+	if _, err := s.SearchByID("missing", 3, SearchOptions{}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	s.Delete("v5")
+	if _, err := s.SearchByID("v5", 3, SearchOptions{}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a deleted vector, got %v", err)
+	}
+}
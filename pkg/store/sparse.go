@@ -0,0 +1,299 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vexor/pkg/distance"
+)
+
+// ErrInvalidSparse is returned for a sparse vector whose indices are not
+// strictly increasing, whose values do not match its indices one to one,
+// or which holds a NaN or infinite value.
+var ErrInvalidSparse = errors.New("invalid sparse vector")
+
+// SparseVector is a sparse embedding, such as a learned SPLADE expansion:
+// dimension Indices[i] has weight Values[i] and every other dimension is
+// zero. Indices are strictly increasing.
+type SparseVector struct {
+	Indices []uint32
+	Values  []float32
+}
+
+// Len returns the number of non-zero dimensions.
+func (v SparseVector) Len() int {
+	return len(v.Indices)
+}
+
+// Dot returns the dot product of v and o.
+func (v SparseVector) Dot(o SparseVector) float32 {
+	return distance.SparseDotProduct(v.Indices, v.Values, o.Indices, o.Values)
+}
+
+// Validate returns ErrInvalidSparse unless Indices and Values have the
+// same length, the indices strictly increase and every value is finite.
+// Inserts and sparse searches apply the same check.
+func (v SparseVector) Validate() error {
+	if len(v.Indices) != len(v.Values) {
+		return ErrInvalidSparse
+	}
+	for i, x := range v.Values {
+		if i > 0 && v.Indices[i] <= v.Indices[i-1] {
+			return ErrInvalidSparse
+		}
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return ErrInvalidSparse
+		}
+	}
+	return nil
+}
+
+// clone copies v so the store never aliases caller memory. An empty
+// vector clones to the zero value.
+func (v SparseVector) clone() SparseVector {
+	if len(v.Indices) == 0 {
+		return SparseVector{}
+	}
+	return SparseVector{Indices: slices.Clone(v.Indices), Values: slices.Clone(v.Values)}
+}
+
+// Sparse vectors are kept per row like the dense ones, and an inverted
+// index maps each dimension to the rows that have it, in row order. As with
+// the text index, postings only grow: readers filter them by visibility and
+// compaction rebuilds the index. Each list also keeps the range of its
+// values, which bounds what the dimension can add to any row's score and
+// lets SearchSparse skip rows that cannot reach the top k (MaxScore).
+
+type sparsePosting struct {
+	row   int32
+	value float32
+}
+
+type sparseList struct {
+	postings []sparsePosting
+	lo, hi   float32 // smallest and largest value in postings
+}
+
+// sparseIndex holds the posting lists of one lineage of shardRows, with the
+// same locking as textIndex.
+type sparseIndex struct {
+	mu    sync.RWMutex
+	lists map[uint32]sparseList
+}
+
+// lookup returns dim's posting list cut to the first n rows, the rows a
+// reader's published shardRows hold.
+func (x *sparseIndex) lookup(dim uint32, n int) sparseList {
+	x.mu.RLock()
+	l := x.lists[dim]
+	x.mu.RUnlock()
+	ps := l.postings
+	l.postings = ps[:sort.Search(len(ps), func(i int) bool { return int(ps[i].row) >= n })]
+	return l
+}
+
+// indexSparse stores the sparse vector of the row just appended and posts
+// its dimensions. The index and the per-row column are created by the first
+// row that has a sparse vector; earlier rows have none.
+func (r *shardRows) indexSparse(sv SparseVector) {
+	row := len(r.ids) - 1
+	if r.sparse == nil {
+		r.sparse = &sparseIndex{lists: make(map[uint32]sparseList)}
+		r.sparseRows = make([]SparseVector, row, cap(r.ids))
+	}
+	r.sparseRows = append(r.sparseRows, sv)
+	if len(sv.Indices) == 0 {
+		return
+	}
+	r.sparse.mu.Lock()
+	for i, dim := range sv.Indices {
+		v := sv.Values[i]
+		l := r.sparse.lists[dim]
+		if len(l.postings) == 0 {
+			l.lo, l.hi = v, v
+		} else {
+			l.lo, l.hi = min(l.lo, v), max(l.hi, v)
+		}
+		l.postings = append(l.postings, sparsePosting{row: int32(row), value: v})
+		r.sparse.lists[dim] = l
+	}
+	r.sparse.mu.Unlock()
+}
+
+// sparseAt returns row idx's sparse vector, shared with the row.
+func (r *shardRows) sparseAt(idx int) SparseVector {
+	if idx < len(r.sparseRows) {
+		return r.sparseRows[idx]
+	}
+	return SparseVector{}
+}
+
+// SearchSparse returns the k vectors with the largest sparse dot product
+// with query, best first. Only vectors sharing a dimension with query are
+// candidates. Each result's SparseScore and Score hold the dot product;
+// Distance is unset.
+func (s *VectorStore) SearchSparse(query SparseVector, k int) ([]SearchResult, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if k <= 0 || len(query.Indices) == 0 {
+		return []SearchResult{}, nil
+	}
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	now := time.Now().UnixNano()
+
+	// The heap keeps the negated score as its distance, so its root is the
+	// weakest result kept.
+	var h topK[string]
+	h.reset(k)
+	set := s.layout.Load()
+	for i := range set.shards {
+		rows := set.shards[i].rows.Load()
+		if rows.sparse != nil {
+			rows.maxScore(query, pin.epoch, now, &h)
+		}
+	}
+	out := h.appendSorted(nil)
+	for i := range out {
+		out[i].Score = -out[i].Distance
+		out[i].SparseScore = out[i].Score
+		out[i].Distance = 0
+	}
+	slices.SortFunc(out, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
+
+// sparseCursor walks one query dimension's posting list.
+type sparseCursor struct {
+	postings []sparsePosting
+	pos      int
+	weight   float64 // query value
+	bound    float64 // most this dimension can add to a score
+}
+
+func (c *sparseCursor) row() int32 {
+	if c.pos < len(c.postings) {
+		return c.postings[c.pos].row
+	}
+	return math.MaxInt32
+}
+
+// maxScore offers every row of r visible at epoch and unexpired at now that
+// could enter h, scoring rows document at a time. Dimensions are ordered by
+// bound; once the bounds of the weakest ones sum to no more than the score
+// h needs, those are non-essential: a row that only they match cannot
+// qualify, so candidates come from the essential lists alone and the
+// non-essential ones are only probed while the row can still qualify.
+func (r *shardRows) maxScore(query SparseVector, epoch uint64, now int64, h *topK[string]) {
+	n := len(r.ids)
+	cursors := make([]sparseCursor, 0, len(query.Indices))
+	for i, dim := range query.Indices {
+		l := r.sparse.lookup(dim, n)
+		if len(l.postings) == 0 {
+			continue
+		}
+		w := float64(query.Values[i])
+		cursors = append(cursors, sparseCursor{
+			postings: l.postings,
+			weight:   w,
+			bound:    max(0, w*float64(l.lo), w*float64(l.hi)),
+		})
+	}
+	slices.SortFunc(cursors, func(a, b sparseCursor) int { return cmp.Compare(a.bound, b.bound) })
+	// prefix[j] bounds the total of cursors[:j+1].
+	prefix := make([]float64, len(cursors))
+	var sum float64
+	for j, c := range cursors {
+		sum += c.bound
+		prefix[j] = sum
+	}
+
+	essential := 0 // cursors[:essential] are non-essential
+	for {
+		full := h.len() == h.k
+		var threshold float64
+		if full {
+			threshold = float64(-h.items[0].Distance)
+			for essential < len(cursors) && prefix[essential] <= threshold {
+				essential++
+			}
+		}
+		if essential == len(cursors) {
+			return
+		}
+
+		row := int32(math.MaxInt32)
+		for j := essential; j < len(cursors); j++ {
+			row = min(row, cursors[j].row())
+		}
+		if row == math.MaxInt32 {
+			return
+		}
+		var score float64
+		for j := essential; j < len(cursors); j++ {
+			c := &cursors[j]
+			if c.row() == row {
+				score += c.weight * float64(c.postings[c.pos].value)
+				c.pos++
+			}
+		}
+		if !r.aliveAt(int(row), epoch, now) {
+			continue
+		}
+		qualifies := true
+		for j := essential - 1; j >= 0; j-- {
+			if full && score+prefix[j] <= threshold {
+				qualifies = false
+				break
+			}
+			c := &cursors[j]
+			rest := c.postings[c.pos:]
+			c.pos += sort.Search(len(rest), func(i int) bool { return rest[i].row >= row })
+			if c.row() == row {
+				score += c.weight * float64(c.postings[c.pos].value)
+				c.pos++
+			}
+		}
+		if qualifies && h.wants(float32(-score)) {
+			h.offer(SearchResult{ID: r.ids[row], Distance: float32(-score)})
+		}
+	}
+}
+
+// sparseScores scores every row visible at epoch and unexpired at now that
+// shares a dimension with query, for fusion in SearchHybrid.
+func (s *VectorStore) sparseScores(set *shardSet, query SparseVector, epoch uint64, now int64) []rowHit {
+	var hits []rowHit
+	scores := make(map[int32]float64)
+	for i := range set.shards {
+		rows := set.shards[i].rows.Load()
+		if rows.sparse == nil {
+			continue
+		}
+		clear(scores)
+		for j, dim := range query.Indices {
+			w := float64(query.Values[j])
+			for _, p := range rows.sparse.lookup(dim, len(rows.ids)).postings {
+				if rows.aliveAt(int(p.row), epoch, now) {
+					scores[p.row] += w * float64(p.value)
+				}
+			}
+		}
+		for row, score := range scores {
+			hits = append(hits, rowHit{rows: rows, row: row, score: score})
+		}
+	}
+	return hits
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func randomSparse(rng *rand.Rand, dims, nnz int, negative bool) SparseVector {
+	idx := rng.Perm(dims)[:1+rng.Intn(nnz)]
+	slices.Sort(idx)
+	v := SparseVector{Indices: make([]uint32, len(idx)), Values: make([]float32, len(idx))}
+	for i, d := range idx {
+		v.Indices[i] = uint32(d)
+		v.Values[i] = rng.Float32()
+		if negative && rng.Intn(4) == 0 {
+			v.Values[i] = -v.Values[i]
+		}
+	}
+	return v
+}
+
+// bruteSparse ranks every stored vector sharing a dimension with query.
+func bruteSparse(s *VectorStore, query SparseVector, k int) []SearchResult {
+	var out []SearchResult
+	for v := range s.Scan(ScanOptions{}) {
+		shared := false
+		for _, d := range v.Sparse.Indices {
+			if _, ok := slices.BinarySearch(query.Indices, d); ok {
+				shared = true
+			}
+		}
+		if shared {
+			score := float32(0)
+			for i, d := range query.Indices {
+				if j, ok := slices.BinarySearch(v.Sparse.Indices, d); ok {
+					score += query.Values[i] * v.Sparse.Values[j]
+				}
+			}
+			out = append(out, SearchResult{ID: v.ID, Score: score, SparseScore: score})
+		}
+	}
+	slices.SortFunc(out, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out[:min(k, len(out))]
+}
+
+func TestSparseVectorValidate(t *testing.T) {
+	s := NewVectorStore(2)
+	bad := []SparseVector{
+		{Indices: []uint32{1, 2}, Values: []float32{1}},
+		{Indices: []uint32{2, 2}, Values: []float32{1, 1}},
+		{Indices: []uint32{3, 1}, Values: []float32{1, 1}},
+	}
+	for _, sv := range bad {
+		if err := s.Insert(Vector{ID: "a", Data: []float32{0, 0}, Sparse: sv}); err != ErrInvalidSparse {
+			t.Errorf("Insert(%v): expected ErrInvalidSparse, got %v", sv, err)
+		}
+		if _, err := s.SearchSparse(sv, 1); err != ErrInvalidSparse {
+			t.Errorf("SearchSparse(%v): expected ErrInvalidSparse, got %v", sv, err)
+		}
+	}
+	if err := s.InsertBatch([]Vector{{ID: "a", Data: []float32{0, 0}, Sparse: bad[0]}}); err != ErrInvalidSparse {
+		t.Errorf("InsertBatch: expected ErrInvalidSparse, got %v", err)
+	}
+	txn := s.Begin()
+	txn.Insert(Vector{ID: "a", Data: []float32{0, 0}, Sparse: bad[1]})
+	if err := txn.Commit(); err != ErrInvalidSparse {
+		t.Errorf("Txn: expected ErrInvalidSparse, got %v", err)
+	}
+}
+
+func TestSparseVectorStored(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "dense", Data: []float32{0, 0}})
+	sv := SparseVector{Indices: []uint32{5, 1000}, Values: []float32{0.5, 1.5}}
+	s.Insert(Vector{ID: "sparse", Data: []float32{1, 1}, Sparse: sv})
+	sv.Values[0] = 99 // the store keeps its own copy
+
+	v, err := s.Get("sparse")
+	if err != nil || !reflect.DeepEqual(v.Sparse, SparseVector{Indices: []uint32{5, 1000}, Values: []float32{0.5, 1.5}}) {
+		t.Fatalf("Get: %+v, %v", v, err)
+	}
+	if v, _ := s.Get("dense"); v.Sparse.Len() != 0 {
+		t.Errorf("dense vector got a sparse part: %+v", v.Sparse)
+	}
+	s.Rename("sparse", "moved")
+	if v, _ := s.Get("moved"); v.Sparse.Len() != 2 {
+		t.Errorf("Rename lost the sparse vector: %+v", v)
+	}
+	if res, _ := s.SearchSparse(SparseVector{Indices: []uint32{1000}, Values: []float32{2}}, 5); len(res) != 1 || res[0].ID != "moved" || res[0].SparseScore != 3 {
+		t.Errorf("SearchSparse: %+v", res)
+	}
+}
+
+func TestSearchSparseMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := NewVectorStore(2, WithShards(3))
+	for i := range 2000 {
+		id := fmt.Sprintf("v%d", rng.Intn(1500))
+		v := Vector{ID: id, Data: []float32{0, 0}}
+		if i%10 != 0 {
+			v.Sparse = randomSparse(rng, 200, 20, false)
+		}
+		s.Insert(v)
+		if i%7 == 0 {
+			s.Delete(fmt.Sprintf("v%d", rng.Intn(1500)))
+		}
+	}
+	check := func(name string, negative bool) {
+		t.Helper()
+		for q := range 50 {
+			query := randomSparse(rng, 200, 8, negative)
+			k := 1 + q%20
+			got, err := s.SearchSparse(query, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := bruteSparse(s, query, k)
+			if len(got) != len(want) {
+				t.Fatalf("%s: query %d: got %d results, want %d", name, q, len(got), len(want))
+			}
+			for i := range want {
+				if diff := got[i].Score - want[i].Score; diff > 1e-4 || diff < -1e-4 {
+					t.Fatalf("%s: query %d rank %d: got %+v, want %+v", name, q, i, got[i], want[i])
+				}
+			}
+		}
+	}
+	check("positive", false)
+	check("negative weights", true)
+	if err := s.Reshard(5); err != nil {
+		t.Fatal(err)
+	}
+	check("resharded", false)
+}
+
+func TestSearchHybridSparse(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "near", Data: []float32{0, 0}})
+	s.Insert(Vector{ID: "both", Data: []float32{1, 0}, Sparse: SparseVector{Indices: []uint32{7}, Values: []float32{1}}})
+	s.Insert(Vector{ID: "sparse", Data: []float32{9, 0}, Sparse: SparseVector{Indices: []uint32{7}, Values: []float32{3}}})
+
+	query := SparseVector{Indices: []uint32{7}, Values: []float32{2}}
+	res, err := s.SearchHybrid([]float32{0, 0}, "", 3, HybridOptions{Sparse: query, Candidates: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"both", "near", "sparse"}) {
+		t.Fatalf("got %v", got)
+	}
+	byID := make(map[string]SearchResult)
+	for _, r := range res {
+		byID[r.ID] = r
+	}
+	if byID["sparse"].Distance != 9 || byID["sparse"].SparseScore != 6 || byID["both"].SparseScore != 2 || byID["near"].SparseScore != 0 {
+		t.Errorf("component scores: %+v", res)
+	}
+
+	res, _ = s.SearchHybrid([]float32{0, 0}, "", 1, HybridOptions{Sparse: query, Fusion: FusionWeighted, SparseWeight: 1})
+	if len(res) != 1 || res[0].ID != "sparse" {
+		t.Errorf("sparse-only weights: got %+v", res)
+	}
+}
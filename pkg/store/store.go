@@ -1,12 +1,14 @@
 package store
 
 import (
-	"container/heap"
 	"errors"
-	"hash/fnv"
+	"maps"
 	"math"
 	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"vexor/pkg/distance"
 )
@@ -15,117 +17,461 @@ var (
 	ErrDimensionMismatch = errors.New("vector dimension does not match store dimension")
 	ErrEmptyID           = errors.New("vector ID cannot be empty")
 	ErrNotFound          = errors.New("vector not found")
+	ErrInvalidShardCount = errors.New("shard count must be positive")
+	ErrNegativeRadius    = errors.New("search radius cannot be negative")
+	ErrSortedStream      = errors.New("sorted range results cannot be streamed")
+	ErrTxnClosed         = errors.New("transaction already committed or rolled back")
+	ErrChangeLogDisabled = errors.New("change log is not enabled")
+	ErrChangesTruncated  = errors.New("requested changes are older than the retained change log")
+	ErrChangeLogReset    = errors.New("change log position is from another change log")
 )
 
-const numShards = 16
+// DefaultNumShards is the shard count used when WithShards is not given.
+const DefaultNumShards = 16
 
-// Vector represents a vector with an ID and float32 data.
+// Payload holds optional metadata stored alongside a vector.
+type Payload map[string]any
+
+// Vector represents a vector with an ID, float32 data and an optional payload.
 type Vector struct {
 	ID   string
 	Data []float32
+	// Sparse is an optional sparse embedding, searched by SearchSparse.
+	Sparse SparseVector
+	// Tokens makes the vector a multi-vector document: a bag of token
+	// embeddings of the store's dimension, scored by SearchMultiVector.
+	// Data is then the pooled embedding used to find candidates; if it is
+	// empty, the mean of the tokens is stored.
+	Tokens  [][]float32
+	Payload Payload
+	// ExpiresAt is when the vector expires; the zero value means never, or
+	// the store's default TTL on insert. See WithDefaultTTL.
+	ExpiresAt time.Time
+}
+
+// Clone returns a copy of v that shares no slices with it. The payload map
+// is copied but not its values.
+func (v Vector) Clone() Vector {
+	v.Data = slices.Clone(v.Data)
+	v.Sparse = v.Sparse.clone()
+	v.Tokens = cloneTokens(v.Tokens)
+	v.Payload = maps.Clone(v.Payload)
+	return v
 }
 
-// SearchResult represents a search result with distance information.
-type SearchResult struct {
-	ID       string
+// Result represents a search result with distance information, generic
+// over the ID type of the store it came from.
+type Result[ID comparable] struct {
+	ID       ID
 	Distance float32
+	// Score is the relevance of a text or hybrid search result, higher
+	// first; TextScore is its BM25 component. Both are 0 for plain vector
+	// searches.
+	Score     float32
+	TextScore float32
+	// SparseScore is the sparse dot product of a SearchSparse or sparse
+	// hybrid result, 0 otherwise.
+	SparseScore float32
 }
 
-// shard uses SoA (Structure of Arrays) layout for cache-friendly access.
-// Vector i's data lives at data[i*dim : (i+1)*dim] in a contiguous allocation.
+// SearchResult is a search result from a VectorStore.
+type SearchResult = Result[string]
+
+// shard is one partition of the keyspace. Searches load the published rows
+// and scan them without taking a lock; writers serialize on mu, edit the
+// working version w and publish a copy of its header. See mvcc.go.
 type shard struct {
-	ids     []string
-	data    []float32 // contiguous: vector i at data[i*dim : (i+1)*dim]
-	idIndex map[string]int
-	mu      sync.RWMutex
+	rows      atomic.Pointer[shardRows] // published version
+	w         shardRows                 // working version; guarded by mu
+	idIndex   map[string]int            // live row of each ID; guarded by mu
+	compactAt int                       // dead count that triggers the next compaction
+	mu        sync.RWMutex              // held by writers; point lookups hold it shared
+}
+
+// shardRows uses SoA (Structure of Arrays) layout for cache-friendly access.
+// Vector i's data lives at data[i*dim : (i+1)*dim] in a contiguous allocation.
+// Rows carry the versions that created and deleted them.
+type shardRows struct {
+	ids      []string
+	data     []float32 // contiguous: vector i at data[i*dim : (i+1)*dim]
+	payloads []Payload // payload of vector i, nil if none
+	created  []uint64  // version that inserted row i
+	deleted  []uint64  // version that deleted row i, 0 while live; atomic
+	expires  []int64   // Unix nanoseconds when row i expires, 0 if never
+
+	live        int    // rows with deleted == 0
+	dead        int    // tombstoned rows not yet compacted
+	lastVersion uint64 // newest version applied to these rows
+	minExpiry   int64  // no row expires before this; 0 if none expires
+
+	// Sparse vectors and their inverted index, both nil until a row with
+	// a sparse vector is appended. See sparse.go.
+	sparse     *sparseIndex
+	sparseRows []SparseVector // sparse vector of row i; rows past the end have none
+
+	// Token vectors of multi-vector rows, contiguous like data; both nil
+	// until a row with tokens is appended. See multivector.go.
+	tokens   []float32
+	tokenEnd []int // row i's tokens end at tokens[tokenEnd[i]*dim]; rows past the end have none
+
+	// Text index, nil unless WithTextIndex is set. See text.go.
+	text       *textIndex
+	textLen    []int32 // token count of row i's text field
+	textDocs   int     // live rows with a non-empty text field
+	textTokens int64   // total token count of those rows
+}
+
+// shardSet is one shard layout. Reshard builds a new set and swaps it in
+// atomically, so readers holding the old set can finish their scan.
+type shardSet struct {
+	shards []shard
+}
+
+func newShardSet(n int, text *textConfig) *shardSet {
+	set := &shardSet{shards: make([]shard, n)}
+	for i := range set.shards {
+		sh := &set.shards[i]
+		sh.w = shardRows{
+			ids:      make([]string, 0),
+			data:     make([]float32, 0),
+			payloads: make([]Payload, 0),
+			created:  make([]uint64, 0),
+			deleted:  make([]uint64, 0),
+			text:     newTextIndex(text),
+		}
+		sh.idIndex = make(map[string]int)
+		sh.compactAt = compactMinDead
+		sh.publish()
+	}
+	return set
+}
+
+// shardFor returns the shard that owns id in this layout.
+func (set *shardSet) shardFor(id string) *shard {
+	return &set.shards[shardIndex(id, len(set.shards))]
 }
 
 // VectorStore is an in-memory store for vectors supporting k-NN search.
-// Uses 16 shards with per-shard locks and SoA memory layout.
+// Uses a configurable number of shards (16 by default) with per-shard locks
+// and SoA memory layout. Reads see a consistent snapshot across all shards.
 type VectorStore struct {
-	shards    [numShards]shard
-	dimension int
+	layout atomic.Pointer[shardSet]
+	// reshardMu is held shared by writers and exclusively by Reshard, so the
+	// layout never changes under a write. Readers do not take it.
+	reshardMu  sync.RWMutex
+	clock      *epochClock
+	dimension  int
+	defaultTTL time.Duration
+	text       *textConfig
+}
+
+// Option configures a VectorStore at construction time.
+type Option func(*config)
+
+type config struct {
+	numShards       int
+	changeRetention int
+	defaultTTL      time.Duration
+	// text is applied only when textIndex is set, so the text options
+	// work in any order.
+	text      textConfig
+	textIndex bool
+}
+
+// WithShards sets the initial number of shards. Values <= 0 are ignored.
+func WithShards(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.numShards = n
+		}
+	}
 }
 
 // NewVectorStore creates a new VectorStore with the specified dimension.
-func NewVectorStore(dimension int) *VectorStore {
-	vs := &VectorStore{dimension: dimension}
-	for i := range vs.shards {
-		vs.shards[i].ids = make([]string, 0)
-		vs.shards[i].data = make([]float32, 0)
-		vs.shards[i].idIndex = make(map[string]int)
+func NewVectorStore(dimension int, opts ...Option) *VectorStore {
+	cfg := config{
+		numShards: DefaultNumShards,
+		text:      textConfig{tokenize: Tokenize, k1: defaultBM25K1, b: defaultBM25B},
 	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var text *textConfig
+	if cfg.textIndex {
+		text = &cfg.text
+	}
+	vs := &VectorStore{dimension: dimension, clock: newEpochClock(), defaultTTL: cfg.defaultTTL, text: text}
+	if cfg.changeRetention > 0 {
+		vs.clock.log = newChangeLog(cfg.changeRetention)
+	}
+	vs.layout.Store(newShardSet(cfg.numShards, text))
 	return vs
 }
 
-func shardIndex(id string) int {
-	h := fnv.New32a()
-	h.Write([]byte(id))
-	return int(h.Sum32() % numShards)
+// shardIndex routes id to one of n shards by FNV-1a hash. The hash is
+// computed inline to avoid allocating a hash.Hash per call.
+func shardIndex(id string, n int) int {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= prime32
+	}
+	return int(h % uint32(n))
 }
 
-// Insert adds a vector to the store.
-func (s *VectorStore) Insert(v Vector) error {
+// NumShards returns the number of shards in the current layout.
+func (s *VectorStore) NumShards() int {
+	return len(s.layout.Load().shards)
+}
+
+// Reshard redistributes all vectors into a new layout with n shards.
+// Searches keep running against the old layout while the new one is built;
+// writes block until the new layout has been published.
+func (s *VectorStore) Reshard(n int) error {
+	if n <= 0 {
+		return ErrInvalidShardCount
+	}
+
+	s.reshardMu.Lock()
+	defer s.reshardMu.Unlock()
+
+	old := s.layout.Load()
+	if len(old.shards) == n {
+		return nil
+	}
+
+	// Row versions move with the rows, so readers pinned to an older epoch
+	// see the same snapshot in either layout. Tombstones no reader can see
+	// are dropped along the way.
+	dim := s.dimension
+	horizon := s.clock.horizon()
+	next := newShardSet(n, s.text)
+	for i := range old.shards {
+		src := &old.shards[i].w
+		for j, id := range src.ids {
+			deleted := src.deleted[j]
+			if deleted != 0 && deleted <= horizon {
+				continue
+			}
+			dst := next.shardFor(id)
+			if deleted == 0 {
+				dst.idIndex[id] = len(dst.w.ids)
+			}
+			dst.w.appendVersion(id, src.data[j*dim:(j+1)*dim], src.sparseAt(j), src.tokensAt(j, dim), src.payloads[j], src.expires[j], src.created[j], deleted)
+			dst.w.lastVersion = max(dst.w.lastVersion, src.lastVersion)
+		}
+	}
+	for i := range next.shards {
+		next.shards[i].publish()
+	}
+
+	s.layout.Store(next)
+	return nil
+}
+
+// checkShape validates v's dense, multi-vector and sparse parts. Data may
+// only be empty when Tokens supplies it.
+func (s *VectorStore) checkShape(v Vector) error {
+	if len(v.Data) != s.dimension && (len(v.Data) > 0 || len(v.Tokens) == 0) {
+		return ErrDimensionMismatch
+	}
+	for _, t := range v.Tokens {
+		if len(t) != s.dimension {
+			return ErrDimensionMismatch
+		}
+	}
+	return v.Sparse.Validate()
+}
+
+// Validate returns the error Insert would return for v's ID and shape,
+// without inserting it.
+func (s *VectorStore) Validate(v Vector) error {
 	if v.ID == "" {
 		return ErrEmptyID
 	}
-	if len(v.Data) != s.dimension {
-		return ErrDimensionMismatch
+	return s.checkShape(v)
+}
+
+// Insert adds a vector to the store. The payload map is copied.
+func (s *VectorStore) Insert(v Vector) error {
+	if err := s.Validate(v); err != nil {
+		return err
 	}
 
-	sh := &s.shards[shardIndex(v.ID)]
-	sh.mu.Lock()
-	defer sh.mu.Unlock()
+	s.reshardMu.RLock()
+	defer s.reshardMu.RUnlock()
 
-	dim := s.dimension
+	sh := s.layout.Load().shardFor(v.ID)
+	sh.mu.Lock()
+	version := s.clock.begin()
 
+	kind := ChangeInsert
 	if idx, exists := sh.idIndex[v.ID]; exists {
-		// Update existing: copy new data into the contiguous slice
-		copy(sh.data[idx*dim:(idx+1)*dim], v.Data)
-		return nil
+		// Update existing: readers at older epochs keep the old row
+		sh.markDeleted(idx, version)
+		kind = ChangeUpsert
 	}
+	expires := s.expiryOf(v)
+	var tokens []float32
+	v.Data, tokens = s.rowVectors(v)
+	sh.appendRow(v.ID, v.Data, v.Sparse.clone(), tokens, maps.Clone(v.Payload), expires, version)
+	sh.maybeCompact(s.dimension, s.clock)
+	sh.publish()
+
+	sh.mu.Unlock()
+	v.ExpiresAt = unixTime(expires)
+	s.clock.commit(version, s.recordChange(nil, kind, v, version))
+	return nil
+}
+
+// InsertBatch inserts or upserts vecs, taking each shard's lock once for
+// all of its vectors. Every vector is validated before any is written.
+// Vectors landing in the same shard become visible together; unlike a
+// Txn, the batch is not atomic across shards. Later duplicates of an ID
+// win. Payload maps are copied.
+func (s *VectorStore) InsertBatch(vecs []Vector) error {
+	for _, v := range vecs {
+		if v.ID == "" {
+			return ErrEmptyID
+		}
+		if err := s.checkShape(v); err != nil {
+			return err
+		}
+	}
+
+	s.reshardMu.RLock()
+	defer s.reshardMu.RUnlock()
 
-	sh.idIndex[v.ID] = len(sh.ids)
-	sh.ids = append(sh.ids, v.ID)
-	sh.data = append(sh.data, v.Data...)
+	set := s.layout.Load()
+	groups := make([][]int, len(set.shards))
+	for i, v := range vecs {
+		j := shardIndex(v.ID, len(set.shards))
+		groups[j] = append(groups[j], i)
+	}
+	for j, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		sh := &set.shards[j]
+		sh.mu.Lock()
+		version := s.clock.begin()
+		var changes []Change
+		for _, i := range group {
+			v := vecs[i]
+			kind := ChangeInsert
+			if idx, exists := sh.idIndex[v.ID]; exists {
+				sh.markDeleted(idx, version)
+				kind = ChangeUpsert
+			}
+			expires := s.expiryOf(v)
+			var tokens []float32
+			v.Data, tokens = s.rowVectors(v)
+			sh.appendRow(v.ID, v.Data, v.Sparse.clone(), tokens, maps.Clone(v.Payload), expires, version)
+			v.ExpiresAt = unixTime(expires)
+			changes = s.recordChange(changes, kind, v, version)
+		}
+		sh.maybeCompact(s.dimension, s.clock)
+		sh.publish()
+		sh.mu.Unlock()
+		s.clock.commit(version, changes)
+	}
 	return nil
 }
 
-// Delete removes a vector from the store by ID.
+// Delete removes a vector from the store by ID. An expired vector is not
+// found, as it is to reads.
 func (s *VectorStore) Delete(id string) error {
-	sh := &s.shards[shardIndex(id)]
+	s.reshardMu.RLock()
+	defer s.reshardMu.RUnlock()
+
+	sh := s.layout.Load().shardFor(id)
 	sh.mu.Lock()
-	defer sh.mu.Unlock()
 
 	idx, exists := sh.idIndex[id]
-	if !exists {
+	if !exists || sh.w.expiredAt(idx, time.Now().UnixNano()) {
+		sh.mu.Unlock()
 		return ErrNotFound
 	}
 
-	dim := s.dimension
-	lastIdx := len(sh.ids) - 1
+	// Tombstone now; the row is reclaimed by compaction once no reader can
+	// still see it.
+	version := s.clock.begin()
+	sh.markDeleted(idx, version)
+	sh.maybeCompact(s.dimension, s.clock)
+	sh.publish()
+
+	sh.mu.Unlock()
+	s.clock.commit(version, s.recordChange(nil, ChangeDelete, Vector{ID: id}, version))
+	return nil
+}
 
-	if idx != lastIdx {
-		// Swap with last: copy last vector's data into the deleted slot
-		sh.ids[idx] = sh.ids[lastIdx]
-		copy(sh.data[idx*dim:(idx+1)*dim], sh.data[lastIdx*dim:(lastIdx+1)*dim])
-		sh.idIndex[sh.ids[idx]] = idx
+// Rename moves the vector stored under oldID to newID, replacing any vector
+// already stored under newID. The two IDs usually live in different shards;
+// readers see the vector under exactly one of them, never both or neither.
+func (s *VectorStore) Rename(oldID, newID string) error {
+	if newID == "" {
+		return ErrEmptyID
+	}
+	if oldID == newID {
+		if !s.Contains(oldID) {
+			return ErrNotFound
+		}
+		return nil
 	}
 
-	sh.ids = sh.ids[:lastIdx]
-	sh.data = sh.data[:lastIdx*dim]
-	delete(sh.idIndex, id)
+	s.reshardMu.RLock()
+	defer s.reshardMu.RUnlock()
+
+	set := s.layout.Load()
+	n := len(set.shards)
+	src := &set.shards[shardIndex(oldID, n)]
+	dst := &set.shards[shardIndex(newID, n)]
+	locked := set.lockShards([]int{shardIndex(oldID, n), shardIndex(newID, n)})
+	defer set.unlockShards(locked)
 
+	idx, exists := src.idIndex[oldID]
+	if !exists {
+		return ErrNotFound
+	}
+
+	dim := s.dimension
+	version := s.clock.begin()
+	vec := src.w.data[idx*dim : (idx+1)*dim]
+	sparse := src.w.sparseAt(idx)
+	tokens := src.w.tokensAt(idx, dim)
+	payload := src.w.payloads[idx]
+	expires := src.w.expires[idx]
+	src.markDeleted(idx, version)
+	changes := s.recordChange(nil, ChangeDelete, Vector{ID: oldID}, version)
+	kind := ChangeInsert
+	if old, exists := dst.idIndex[newID]; exists {
+		dst.markDeleted(old, version)
+		kind = ChangeUpsert
+	}
+	dst.appendRow(newID, vec, sparse, tokens, payload, expires, version)
+	changes = s.recordChange(changes, kind, Vector{ID: newID, Data: vec, Sparse: sparse, Tokens: splitTokens(tokens, dim), Payload: payload, ExpiresAt: unixTime(expires)}, version)
+	src.maybeCompact(dim, s.clock)
+	dst.maybeCompact(dim, s.clock)
+	src.publish()
+	dst.publish()
+	s.clock.commit(version, changes)
 	return nil
 }
 
-// Count returns the number of vectors in the store.
+// Count returns the number of vectors in the store at a single point in time.
 func (s *VectorStore) Count() int {
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	now := time.Now().UnixNano()
+
+	set := s.layout.Load()
 	total := 0
-	for i := range s.shards {
-		s.shards[i].mu.RLock()
-		total += len(s.shards[i].ids)
-		s.shards[i].mu.RUnlock()
+	for i := range set.shards {
+		total += set.shards[i].rows.Load().countAt(pin.epoch, now)
 	}
 	return total
 }
@@ -135,195 +481,214 @@ func (s *VectorStore) Dimension() int {
 	return s.dimension
 }
 
-// Search performs a k-NN search using Euclidean distance.
-// Parallelizes across shards using multiple goroutines.
-func (s *VectorStore) Search(query []float32, k int) ([]SearchResult, error) {
-	if len(query) != s.dimension {
-		return nil, ErrDimensionMismatch
+// Metric selects the distance function used by a search.
+type Metric int
+
+const (
+	// Euclidean is the L2 distance. Scans compare squared distances and
+	// only take the square root of returned results.
+	Euclidean Metric = iota
+	// Cosine is 1 - cosine similarity.
+	Cosine
+)
+
+// scanDistance returns the distance function used during the shard scan.
+// For Euclidean this is the squared distance.
+func (m Metric) scanDistance() func(a, b []float32) float32 {
+	if m == Cosine {
+		return distance.CosineDistance
 	}
-	if k <= 0 {
-		return []SearchResult{}, nil
+	return distance.EuclideanDistanceSquared
+}
+
+// finalize converts a scan distance into the distance reported to callers.
+func (m Metric) finalize(d float32) float32 {
+	if m == Cosine {
+		return d
 	}
+	return sqrt32(d)
+}
 
-	dim := s.dimension
+// toScan converts a caller-facing distance threshold into scan units.
+func (m Metric) toScan(d float32) float32 {
+	if m == Cosine {
+		return d
+	}
+	return d * d
+}
+
+// numWorkers returns how many goroutines a parallel scan of this layout uses.
+func (set *shardSet) numWorkers() int {
+	return workerCount(len(set.shards))
+}
+
+// scanParallel partitions the shards across nWorkers goroutines and calls fn
+// with every shard's published rows. No locks are taken. Returning false
+// from fn stops the calling worker early.
+func (set *shardSet) scanParallel(nWorkers int, fn func(workerID int, rows *shardRows) bool) {
+	parallelRanges(len(set.shards), nWorkers, func(workerID, start, end int) {
+		for si := start; si < end; si++ {
+			if !fn(workerID, set.shards[si].rows.Load()) {
+				return
+			}
+		}
+	})
+}
+
+// workerCount returns how many goroutines scan numShards shards.
+func workerCount(numShards int) int {
 	nWorkers := runtime.GOMAXPROCS(0)
 	if nWorkers > numShards {
 		nWorkers = numShards
 	}
+	return nWorkers
+}
 
-	type workerResult struct {
-		results []SearchResult
+// parallelRanges splits [0, n) into contiguous ranges, one per worker, and
+// runs fn for each range on its own goroutine. A single worker runs on the
+// calling goroutine.
+func parallelRanges(n, nWorkers int, fn func(workerID, start, end int)) {
+	if nWorkers == 1 {
+		fn(0, 0, n)
+		return
 	}
-	workerResults := make([]workerResult, nWorkers)
 
-	var wg sync.WaitGroup
-	shardsPerWorker := (numShards + nWorkers - 1) / nWorkers
+	perWorker := (n + nWorkers - 1) / nWorkers
 
+	var wg sync.WaitGroup
 	for w := 0; w < nWorkers; w++ {
+		start := w * perWorker
+		end := start + perWorker
+		if end > n {
+			end = n
+		}
+		if start >= end {
+			break
+		}
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			h := &maxHeap{}
-			heap.Init(h)
-
-			start := workerID * shardsPerWorker
-			end := start + shardsPerWorker
-			if end > numShards {
-				end = numShards
-			}
-
-			for si := start; si < end; si++ {
-				sh := &s.shards[si]
-				sh.mu.RLock()
-				n := len(sh.ids)
-				for i := 0; i < n; i++ {
-					vec := sh.data[i*dim : (i+1)*dim]
-					dist := distance.EuclideanDistanceSquared(query, vec)
-					if h.Len() < k {
-						heap.Push(h, SearchResult{ID: sh.ids[i], Distance: dist})
-					} else if dist < (*h)[0].Distance {
-						heap.Pop(h)
-						heap.Push(h, SearchResult{ID: sh.ids[i], Distance: dist})
-					}
-				}
-				sh.mu.RUnlock()
-			}
-
-			results := make([]SearchResult, h.Len())
-			for i := h.Len() - 1; i >= 0; i-- {
-				results[i] = heap.Pop(h).(SearchResult)
-			}
-			workerResults[workerID] = workerResult{results: results}
+			fn(workerID, start, end)
 		}(w)
 	}
 	wg.Wait()
+}
 
-	// Merge all worker results into final top-k
-	finalHeap := &maxHeap{}
-	heap.Init(finalHeap)
-	for _, wr := range workerResults {
-		for _, r := range wr.results {
-			if finalHeap.Len() < k {
-				heap.Push(finalHeap, r)
-			} else if r.Distance < (*finalHeap)[0].Distance {
-				heap.Pop(finalHeap)
-				heap.Push(finalHeap, r)
-			}
-		}
+// searchScratch holds the per-worker heaps of one search. It is pooled so
+// steady-state searches reuse heap storage instead of allocating.
+type searchScratch[ID comparable] struct {
+	heaps []topK[ID]
+	final topK[ID]
+}
+
+var scratchPool = sync.Pool{
+	New: func() any { return new(searchScratch[string]) },
+}
+
+func getScratch[ID comparable](pool *sync.Pool, nWorkers, k int) *searchScratch[ID] {
+	sc := pool.Get().(*searchScratch[ID])
+	if cap(sc.heaps) < nWorkers {
+		sc.heaps = make([]topK[ID], nWorkers)
 	}
+	sc.heaps = sc.heaps[:nWorkers]
+	for i := range sc.heaps {
+		sc.heaps[i].reset(k)
+	}
+	sc.final.reset(k)
+	return sc
+}
 
-	results := make([]SearchResult, finalHeap.Len())
-	for i := finalHeap.Len() - 1; i >= 0; i-- {
-		r := heap.Pop(finalHeap).(SearchResult)
-		r.Distance = sqrt32(r.Distance)
-		results[i] = r
+func putScratch[ID comparable](pool *sync.Pool, sc *searchScratch[ID]) {
+	// Drop ID references so pooled scratch does not pin deleted strings.
+	for i := range sc.heaps {
+		clear(sc.heaps[i].items[:cap(sc.heaps[i].items)])
 	}
+	clear(sc.final.items[:cap(sc.final.items)])
+	pool.Put(sc)
+}
 
-	return results, nil
+// Search performs a k-NN search using Euclidean distance.
+// Parallelizes across shards using multiple goroutines.
+func (s *VectorStore) Search(query []float32, k int) ([]SearchResult, error) {
+	return s.searchInto(nil, query, k, Euclidean)
 }
 
 // SearchCosine performs a k-NN search using cosine distance.
 func (s *VectorStore) SearchCosine(query []float32, k int) ([]SearchResult, error) {
+	return s.searchInto(nil, query, k, Cosine)
+}
+
+// SearchInto is like Search but appends the results to dst[:0] and returns
+// the extended slice. Passing a dst with capacity >= k lets repeated queries
+// run without allocating result memory.
+func (s *VectorStore) SearchInto(dst []SearchResult, query []float32, k int) ([]SearchResult, error) {
+	return s.searchInto(dst[:0], query, k, Euclidean)
+}
+
+// SearchCosineInto is the cosine-distance counterpart of SearchInto.
+func (s *VectorStore) SearchCosineInto(dst []SearchResult, query []float32, k int) ([]SearchResult, error) {
+	return s.searchInto(dst[:0], query, k, Cosine)
+}
+
+func (s *VectorStore) searchInto(dst []SearchResult, query []float32, k int, metric Metric) ([]SearchResult, error) {
 	if len(query) != s.dimension {
 		return nil, ErrDimensionMismatch
 	}
 	if k <= 0 {
-		return []SearchResult{}, nil
-	}
-
-	dim := s.dimension
-	nWorkers := runtime.GOMAXPROCS(0)
-	if nWorkers > numShards {
-		nWorkers = numShards
-	}
-
-	type workerResult struct {
-		results []SearchResult
+		if dst == nil {
+			dst = []SearchResult{}
+		}
+		return dst, nil
 	}
-	workerResults := make([]workerResult, nWorkers)
-
-	var wg sync.WaitGroup
-	shardsPerWorker := (numShards + nWorkers - 1) / nWorkers
-
-	for w := 0; w < nWorkers; w++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			h := &maxHeap{}
-			heap.Init(h)
 
-			start := workerID * shardsPerWorker
-			end := start + shardsPerWorker
-			if end > numShards {
-				end = numShards
-			}
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	return s.searchAt(dst, s.layout.Load(), query, k, metric, pin.epoch, time.Now().UnixNano()), nil
+}
 
-			for si := start; si < end; si++ {
-				sh := &s.shards[si]
-				sh.mu.RLock()
-				n := len(sh.ids)
-				for i := 0; i < n; i++ {
-					vec := sh.data[i*dim : (i+1)*dim]
-					dist := distance.CosineDistance(query, vec)
-					if h.Len() < k {
-						heap.Push(h, SearchResult{ID: sh.ids[i], Distance: dist})
-					} else if dist < (*h)[0].Distance {
-						heap.Pop(h)
-						heap.Push(h, SearchResult{ID: sh.ids[i], Distance: dist})
-					}
-				}
-				sh.mu.RUnlock()
+// searchAt appends the k nearest rows of set visible at epoch and
+// unexpired at now to dst. The caller holds a pin on epoch.
+func (s *VectorStore) searchAt(dst []SearchResult, set *shardSet, query []float32, k int, metric Metric, epoch uint64, now int64) []SearchResult {
+	dim := s.dimension
+	distFn := metric.scanDistance()
+	nWorkers := set.numWorkers()
+
+	sc := getScratch[string](&scratchPool, nWorkers, k)
+	set.scanParallel(nWorkers, func(workerID int, rows *shardRows) bool {
+		h := &sc.heaps[workerID]
+		n := len(rows.ids)
+		all := rows.allAliveAt(epoch, now)
+		for i := 0; i < n; i++ {
+			if !all && !rows.aliveAt(i, epoch, now) {
+				continue
 			}
-
-			results := make([]SearchResult, h.Len())
-			for i := h.Len() - 1; i >= 0; i-- {
-				results[i] = heap.Pop(h).(SearchResult)
+			dist := distFn(query, rows.data[i*dim:(i+1)*dim])
+			if h.wants(dist) {
+				h.offer(SearchResult{ID: rows.ids[i], Distance: dist})
 			}
-			workerResults[workerID] = workerResult{results: results}
-		}(w)
-	}
-	wg.Wait()
+		}
+		return true
+	})
 
-	finalHeap := &maxHeap{}
-	heap.Init(finalHeap)
-	for _, wr := range workerResults {
-		for _, r := range wr.results {
-			if finalHeap.Len() < k {
-				heap.Push(finalHeap, r)
-			} else if r.Distance < (*finalHeap)[0].Distance {
-				heap.Pop(finalHeap)
-				heap.Push(finalHeap, r)
-			}
+	// Merge all worker results into final top-k
+	for i := range sc.heaps {
+		for _, r := range sc.heaps[i].items {
+			sc.final.offer(r)
 		}
 	}
 
-	results := make([]SearchResult, finalHeap.Len())
-	for i := finalHeap.Len() - 1; i >= 0; i-- {
-		results[i] = heap.Pop(finalHeap).(SearchResult)
+	if dst == nil {
+		dst = make([]SearchResult, 0, sc.final.len())
 	}
-
-	return results, nil
+	start := len(dst)
+	dst = sc.final.appendSorted(dst)
+	for i := start; i < len(dst); i++ {
+		dst[i].Distance = metric.finalize(dst[i].Distance)
+	}
+	putScratch(&scratchPool, sc)
+	return dst
 }
 
 func sqrt32(x float32) float32 {
 	return float32(math.Sqrt(float64(x)))
 }
-
-// maxHeap implements heap.Interface for SearchResult (max-heap by distance).
-type maxHeap []SearchResult
-
-func (h maxHeap) Len() int           { return len(h) }
-func (h maxHeap) Less(i, j int) bool { return h[i].Distance > h[j].Distance }
-func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
-
-func (h *maxHeap) Push(x any) {
-	*h = append(*h, x.(SearchResult))
-}
-
-func (h *maxHeap) Pop() any {
-	old := *h
-	n := len(old)
-	x := old[n-1]
-	*h = old[0 : n-1]
-	return x
-}
@@ -46,6 +46,40 @@ func TestInsertErrors(t *testing.T) {
 	}
 }
 
+func TestInsertBatch(t *testing.T) {
+	s := NewVectorStore(2, WithShards(4), WithChangeLog(100))
+	s.Insert(Vector{ID: "v0", Data: []float32{9, 9}})
+
+	vecs := make([]Vector, 50)
+	for i := range vecs {
+		vecs[i] = Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{float32(i), 0}}
+	}
+	vecs = append(vecs, Vector{ID: "v1", Data: []float32{-1, 0}})
+	if err := s.InsertBatch(vecs); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 50 {
+		t.Fatalf("expected 50, got %d", s.Count())
+	}
+	if v, _ := s.Get("v1"); v.Data[0] != -1 {
+		t.Fatalf("later duplicate should win, got %v", v.Data)
+	}
+	if v, _ := s.Get("v0"); v.Data[0] != 0 {
+		t.Fatalf("existing vector not upserted, got %v", v.Data)
+	}
+	if changes, err := s.Changes(1, 0); err != nil || len(changes) != 52 {
+		t.Fatalf("expected 52 changes, got %d, %v", len(changes), err)
+	}
+
+	bad := []Vector{{ID: "x", Data: []float32{1, 2}}, {ID: "y", Data: []float32{1}}}
+	if err := s.InsertBatch(bad); err != ErrDimensionMismatch {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if s.Contains("x") {
+		t.Fatal("invalid batch was partially applied")
+	}
+}
+
 func TestDelete(t *testing.T) {
 	s := NewVectorStore(2)
 	s.Insert(Vector{ID: "a", Data: []float32{1, 2}})
@@ -128,6 +162,52 @@ func TestSearchEdgeCases(t *testing.T) {
 	}
 }
 
+func TestSearchInto(t *testing.T) {
+	s := NewVectorStore(4)
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < 2000; i++ {
+		data := make([]float32, 4)
+		for j := range data {
+			data[j] = rng.Float32()
+		}
+		s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: data})
+	}
+	query := []float32{0.5, 0.5, 0.5, 0.5}
+
+	want, _ := s.Search(query, 10)
+	dst := make([]SearchResult, 3, 10)
+	got, err := s.SearchInto(dst, query, 10)
+	if err != nil {
+		t.Fatalf("SearchInto failed: %v", err)
+	}
+	if len(got) != len(want) || &got[0] != &dst[:1][0] {
+		t.Fatalf("SearchInto did not reuse dst: len=%d", len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("result %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	wantCos, _ := s.SearchCosine(query, 5)
+	gotCos, _ := s.SearchCosineInto(got, query, 5)
+	for i := range wantCos {
+		if gotCos[i] != wantCos[i] {
+			t.Fatalf("cosine result %d: got %+v, want %+v", i, gotCos[i], wantCos[i])
+		}
+	}
+
+	if raceEnabled {
+		return
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		got, _ = s.SearchInto(got, query, 10)
+	})
+	if allocs > 2 {
+		t.Errorf("SearchInto allocated %.0f times per query, want <= 2", allocs)
+	}
+}
+
 func TestDimension(t *testing.T) {
 	s := NewVectorStore(42)
 	if s.Dimension() != 42 {
@@ -160,8 +240,9 @@ func TestShardDistribution(t *testing.T) {
 	// Check that vectors are distributed (not all in one shard)
 	maxPerShard := 0
 	minPerShard := n
-	for i := range s.shards {
-		c := len(s.shards[i].ids)
+	set := s.layout.Load()
+	for i := range set.shards {
+		c := set.shards[i].rows.Load().live
 		if c > maxPerShard {
 			maxPerShard = c
 		}
@@ -176,6 +257,111 @@ func TestShardDistribution(t *testing.T) {
 	t.Logf("Shard distribution: min=%d, max=%d (of %d total)", minPerShard, maxPerShard, n)
 }
 
+func TestWithShards(t *testing.T) {
+	if got := NewVectorStore(2).NumShards(); got != DefaultNumShards {
+		t.Fatalf("expected default %d shards, got %d", DefaultNumShards, got)
+	}
+	s := NewVectorStore(2, WithShards(64))
+	if s.NumShards() != 64 {
+		t.Fatalf("expected 64 shards, got %d", s.NumShards())
+	}
+	for i := 0; i < 1000; i++ {
+		s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(i), 0}})
+	}
+	results, _ := s.Search([]float32{500, 0}, 3)
+	if len(results) != 3 || results[0].ID != "v-500" {
+		t.Fatalf("unexpected results with 64 shards: %+v", results)
+	}
+}
+
+func TestReshard(t *testing.T) {
+	s := NewVectorStore(2)
+	n := 2000
+	for i := 0; i < n; i++ {
+		s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(i), float32(i)}})
+	}
+	before, _ := s.Search([]float32{100.2, 100.2}, 5)
+
+	for _, shards := range []int{64, 3, 1, 16} {
+		if err := s.Reshard(shards); err != nil {
+			t.Fatalf("Reshard(%d) failed: %v", shards, err)
+		}
+		if s.NumShards() != shards {
+			t.Fatalf("expected %d shards, got %d", shards, s.NumShards())
+		}
+		if s.Count() != n {
+			t.Fatalf("expected %d after Reshard(%d), got %d", n, shards, s.Count())
+		}
+		after, _ := s.Search([]float32{100.2, 100.2}, 5)
+		for i := range before {
+			if after[i] != before[i] {
+				t.Fatalf("Reshard(%d) changed results: %+v vs %+v", shards, after, before)
+			}
+		}
+	}
+
+	// The new layout must route deletes and updates correctly.
+	if err := s.Delete("v-100"); err != nil {
+		t.Fatalf("Delete after reshard failed: %v", err)
+	}
+	s.Insert(Vector{ID: "v-101", Data: []float32{-1, -1}})
+	if s.Count() != n-1 {
+		t.Fatalf("expected %d, got %d", n-1, s.Count())
+	}
+
+	if err := s.Reshard(0); err != ErrInvalidShardCount {
+		t.Fatalf("expected ErrInvalidShardCount, got %v", err)
+	}
+}
+
+// TestReshardConcurrent reshards repeatedly while readers and writers run.
+func TestReshardConcurrent(t *testing.T) {
+	s := NewVectorStore(4)
+	for i := 0; i < 500; i++ {
+		s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(i), 0, 0, 0}})
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				results, err := s.Search([]float32{0, 0, 0, 0}, 5)
+				if err != nil || len(results) != 5 {
+					t.Errorf("Search during reshard: %v, %d results", err, len(results))
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 500; i < 1000; i++ {
+			s.Insert(Vector{ID: fmt.Sprintf("v-%d", i), Data: []float32{float32(i), 0, 0, 0}})
+		}
+	}()
+
+	for _, n := range []int{8, 32, 5, 16, 64} {
+		if err := s.Reshard(n); err != nil {
+			t.Fatalf("Reshard(%d) failed: %v", n, err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if s.Count() != 1000 {
+		t.Fatalf("expected 1000 after concurrent reshard, got %d", s.Count())
+	}
+}
+
 // TestConcurrentInsertDelete stress-tests concurrent inserts and deletes.
 func TestConcurrentInsertDelete(t *testing.T) {
 	s := NewVectorStore(8)
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ErrTextIndexDisabled is returned by text and hybrid searches on a store
+// created without WithTextIndex.
+var ErrTextIndexDisabled = errors.New("text index is not enabled")
+
+// The text index is an inverted index over one string payload field. Like
+// the vectors, it lives in each shard's rows: every row records its token
+// count, and a per-shard postings map lists the rows containing each term.
+// Rows are append-only, so postings only grow and a reader filters them by
+// the same visibility check as a vector scan; compaction builds a fresh
+// index along with the fresh rows. Relevance is Okapi BM25.
+
+const (
+	defaultBM25K1 = 1.2
+	defaultBM25B  = 0.75
+)
+
+type textConfig struct {
+	field    string
+	tokenize func(string) []string
+	k1, b    float64
+}
+
+// WithTextIndex indexes the string payload field for SearchText and
+// SearchHybrid. Vectors without the field, or with a non-string value, are
+// not matched by text.
+func WithTextIndex(field string) Option {
+	return func(c *config) {
+		c.text.field = field
+		c.textIndex = true
+	}
+}
+
+// WithTokenizer replaces Tokenize as the text index tokenizer. Queries are
+// tokenized the same way. It applies only if WithTextIndex is also given,
+// before or after it.
+func WithTokenizer(fn func(string) []string) Option {
+	return func(c *config) {
+		if fn != nil {
+			c.text.tokenize = fn
+		}
+	}
+}
+
+// WithBM25 sets the BM25 term-frequency saturation k1 (default 1.2) and
+// length normalization b (default 0.75). It applies only if WithTextIndex
+// is also given, before or after it.
+func WithBM25(k1, b float64) Option {
+	return func(c *config) {
+		c.text.k1, c.text.b = k1, b
+	}
+}
+
+// Tokenize is the default tokenizer: it lowercases text and splits it into
+// runs of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// posting is one row's occurrences of a term.
+type posting struct {
+	row int32
+	tf  int32
+}
+
+// textIndex holds the postings of one lineage of shardRows. Writers append
+// under mu while readers copy a term's slice header under mu.RLock and scan
+// it unlocked: appends never touch elements a reader's copy can see.
+type textIndex struct {
+	cfg      *textConfig
+	mu       sync.RWMutex
+	postings map[string][]posting
+}
+
+func newTextIndex(cfg *textConfig) *textIndex {
+	if cfg == nil {
+		return nil
+	}
+	return &textIndex{cfg: cfg, postings: make(map[string][]posting)}
+}
+
+func (t *textIndex) lookup(term string) []posting {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.postings[term]
+}
+
+// indexRow tokenizes payload's text field and posts row's terms. It runs
+// from appendVersion, after the row's other columns are appended.
+func (r *shardRows) indexRow(payload Payload, deleted uint64) {
+	text, _ := payload[r.text.cfg.field].(string)
+	tokens := r.text.cfg.tokenize(text)
+	r.textLen = append(r.textLen, int32(len(tokens)))
+	if len(tokens) == 0 {
+		return
+	}
+	tf := make(map[string]int32, len(tokens))
+	for _, tok := range tokens {
+		tf[tok]++
+	}
+	row := int32(len(r.ids) - 1)
+	r.text.mu.Lock()
+	for term, n := range tf {
+		r.text.postings[term] = append(r.text.postings[term], posting{row: row, tf: n})
+	}
+	r.text.mu.Unlock()
+	if deleted == 0 {
+		r.textDocs++
+		r.textTokens += int64(len(tokens))
+	}
+}
+
+// unindexRow removes a tombstoned row from the corpus statistics. Its
+// postings stay until compaction and are filtered out by readers.
+func (r *shardRows) unindexRow(idx int) {
+	if r.text != nil && r.textLen[idx] > 0 {
+		r.textDocs--
+		r.textTokens -= int64(r.textLen[idx])
+	}
+}
+
+// rowHit is a row matching a text or sparse query, with its score.
+type rowHit struct {
+	rows  *shardRows
+	row   int32
+	score float64
+}
+
+// bm25 scores every row visible at epoch and unexpired at now that
+// contains a query term. Corpus statistics come from each shard's
+// published counters, which track live rows.
+func (s *VectorStore) bm25(set *shardSet, query string, epoch uint64, now int64) []rowHit {
+	terms := slices.Compact(slices.Sorted(slices.Values(s.text.tokenize(query))))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	type termRows struct {
+		rows     *shardRows
+		postings []posting
+	}
+	var (
+		docs   int
+		tokens int64
+		df     = make([]int, len(terms))
+		lists  = make([][]termRows, len(terms))
+	)
+	for i := range set.shards {
+		rows := set.shards[i].rows.Load()
+		docs += rows.textDocs
+		tokens += rows.textTokens
+		for t, term := range terms {
+			ps := rows.text.lookup(term)
+			if len(ps) == 0 {
+				continue
+			}
+			lists[t] = append(lists[t], termRows{rows, ps})
+			for _, p := range ps {
+				if int(p.row) < len(rows.ids) && rows.aliveAt(int(p.row), epoch, now) {
+					df[t]++
+				}
+			}
+		}
+	}
+	if docs == 0 {
+		return nil
+	}
+	avgLen := float64(tokens) / float64(docs)
+	k1, b := s.text.k1, s.text.b
+
+	scores := make(map[rowRef]float64)
+	for t := range terms {
+		n := float64(df[t])
+		idf := math.Log(1 + (float64(docs)-n+0.5)/(n+0.5))
+		for _, tr := range lists[t] {
+			rows := tr.rows
+			for _, p := range tr.postings {
+				if int(p.row) >= len(rows.ids) || !rows.aliveAt(int(p.row), epoch, now) {
+					continue
+				}
+				tf := float64(p.tf)
+				norm := 1 - b + b*float64(rows.textLen[p.row])/avgLen
+				scores[rowRef{rows, p.row}] += idf * tf * (k1 + 1) / (tf + k1*norm)
+			}
+		}
+	}
+	hits := make([]rowHit, 0, len(scores))
+	for key, score := range scores {
+		hits = append(hits, rowHit{rows: key.rows, row: key.row, score: score})
+	}
+	return hits
+}
+
+// sortHits orders hits by descending score, breaking ties by ID.
+func sortHits(hits []rowHit) {
+	slices.SortFunc(hits, func(a, b rowHit) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.rows.ids[a.row], b.rows.ids[b.row])
+	})
+}
+
+// SearchText returns the k vectors whose text field best matches query by
+// BM25, most relevant first. Each result's TextScore and Score hold the
+// BM25 score; Distance is unset.
+func (s *VectorStore) SearchText(query string, k int) ([]SearchResult, error) {
+	if s.text == nil {
+		return nil, ErrTextIndexDisabled
+	}
+	if k <= 0 {
+		return []SearchResult{}, nil
+	}
+	pin := s.clock.pin()
+	defer s.clock.unpin(pin)
+	hits := s.bm25(s.layout.Load(), query, pin.epoch, time.Now().UnixNano())
+	sortHits(hits)
+	out := make([]SearchResult, 0, min(k, len(hits)))
+	for _, h := range hits[:min(k, len(hits))] {
+		score := float32(h.score)
+		out = append(out, SearchResult{ID: h.rows.ids[h.row], Score: score, TextScore: score})
+	}
+	return out, nil
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func textIDs(res []SearchResult) []string {
+	ids := make([]string, len(res))
+	for i, r := range res {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Hello, World! It's 2024 — naïve café")
+	want := []string{"hello", "world", "it", "s", "2024", "naïve", "café"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	s := NewVectorStore(2, WithTextIndex("body"))
+	s.Insert(Vector{ID: "a", Data: []float32{0, 0}, Payload: Payload{"body": "the quick brown fox"}})
+	s.Insert(Vector{ID: "b", Data: []float32{1, 0}, Payload: Payload{"body": "the lazy dog sleeps all day long"}})
+	s.Insert(Vector{ID: "c", Data: []float32{2, 0}, Payload: Payload{"body": "fox fox fox"}})
+	s.Insert(Vector{ID: "d", Data: []float32{3, 0}, Payload: Payload{"body": 42}})
+	s.Insert(Vector{ID: "e", Data: []float32{4, 0}})
+
+	res, err := s.SearchText("Fox", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Fatalf("got %v, want [c a]", got)
+	}
+	if res[0].TextScore <= res[1].TextScore || res[0].Score != res[0].TextScore {
+		t.Errorf("unexpected scores: %+v", res)
+	}
+
+	// A rare term outweighs a common one.
+	res, _ = s.SearchText("the dog", 10)
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("got %v, want [b a]", got)
+	}
+	if res, _ := s.SearchText("the dog", 1); len(res) != 1 || res[0].ID != "b" {
+		t.Errorf("k=1 returned %+v", res)
+	}
+	if res, _ := s.SearchText("cat", 10); len(res) != 0 {
+		t.Errorf("unmatched query returned %+v", res)
+	}
+
+	// Upserts and deletes update the index.
+	s.Insert(Vector{ID: "c", Data: []float32{2, 0}, Payload: Payload{"body": "a cat"}})
+	s.Delete("a")
+	if res, _ := s.SearchText("fox", 10); len(res) != 0 {
+		t.Errorf("stale rows matched: %+v", res)
+	}
+	if res, _ := s.SearchText("cat", 10); len(res) != 1 || res[0].ID != "c" {
+		t.Errorf("upserted text not matched: %+v", res)
+	}
+
+	if _, err := NewVectorStore(2).SearchText("fox", 10); err != ErrTextIndexDisabled {
+		t.Errorf("expected ErrTextIndexDisabled, got %v", err)
+	}
+}
+
+func TestSearchTextExpired(t *testing.T) {
+	s := NewVectorStore(2, WithTextIndex("body"))
+	s.Insert(Vector{ID: "gone", Data: []float32{0, 0}, Payload: Payload{"body": "fox"}, ExpiresAt: time.Now().Add(-time.Second)})
+	s.Insert(Vector{ID: "kept", Data: []float32{0, 0}, Payload: Payload{"body": "fox"}})
+	if res, _ := s.SearchText("fox", 10); len(res) != 1 || res[0].ID != "kept" {
+		t.Errorf("expected only kept, got %+v", res)
+	}
+}
+
+func TestTextOptionsAnyOrder(t *testing.T) {
+	whole := func(text string) []string { return []string{text} }
+	before := NewVectorStore(2, WithTokenizer(whole), WithBM25(2, 0), WithTextIndex("body"))
+	after := NewVectorStore(2, WithTextIndex("body"), WithTokenizer(whole), WithBM25(2, 0))
+	defaults := NewVectorStore(2, WithTextIndex("body"))
+	for _, s := range []*VectorStore{before, after, defaults} {
+		s.Insert(Vector{ID: "short", Data: []float32{0, 0}, Payload: Payload{"body": "red apple"}})
+		s.Insert(Vector{ID: "other", Data: []float32{0, 0}, Payload: Payload{"body": "green pear"}})
+	}
+
+	if res, _ := before.SearchText("apple", 10); len(res) != 0 {
+		t.Errorf("tokenizer given first was ignored: %+v", res)
+	}
+	want, _ := after.SearchText("red apple", 10)
+	got, _ := before.SearchText("red apple", 10)
+	if len(want) != 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("options before WithTextIndex: got %+v, want %+v", got, want)
+	}
+	if before.text.k1 != 2 || before.text.b != 0 {
+		t.Errorf("BM25 given first was ignored: k1 %v, b %v", before.text.k1, before.text.b)
+	}
+	if NewVectorStore(2, WithBM25(2, 0)).text != nil {
+		t.Error("WithBM25 alone enabled the text index")
+	}
+	if res, _ := defaults.SearchText("apple", 10); len(res) != 1 {
+		t.Errorf("default tokenizer: %+v", res)
+	}
+}
+
+func TestTextIndexCompactAndReshard(t *testing.T) {
+	s := NewVectorStore(2, WithShards(2), WithTextIndex("body"),
+		WithTokenizer(func(text string) []string { return Tokenize(text) }))
+	for i := range 200 {
+		body := "even"
+		if i%2 == 1 {
+			body = "odd"
+		}
+		s.Insert(Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{float32(i), 0}, Payload: Payload{"body": body}})
+	}
+	// Enough deletes to compact both shards.
+	for i := 0; i < 200; i += 4 {
+		s.Delete(fmt.Sprintf("v%d", i))
+	}
+	if res, _ := s.SearchText("even", 200); len(res) != 50 {
+		t.Errorf("expected 50 even matches after compaction, got %d", len(res))
+	}
+	if err := s.Reshard(3); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := s.SearchText("odd", 200); len(res) != 100 {
+		t.Errorf("expected 100 odd matches after reshard, got %d", len(res))
+	}
+}
+
+func TestSearchHybrid(t *testing.T) {
+	s := NewVectorStore(2, WithTextIndex("body"))
+	s.Insert(Vector{ID: "near", Data: []float32{0, 0}, Payload: Payload{"body": "unrelated words"}})
+	s.Insert(Vector{ID: "both", Data: []float32{1, 0}, Payload: Payload{"body": "red apple"}})
+	s.Insert(Vector{ID: "text", Data: []float32{9, 0}, Payload: Payload{"body": "apple apple apple"}})
+	s.Insert(Vector{ID: "far", Data: []float32{10, 0}})
+
+	// With a candidate depth of 2 "text" reaches the fusion only through the
+	// text ranking, and still gets its distance. It ties with "near", each
+	// ranked first by one list, and the tie goes to the smaller ID.
+	opts := HybridOptions{Candidates: 2}
+	res, err := s.SearchHybrid([]float32{0, 0}, "apple", 4, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"both", "near", "text"}) {
+		t.Fatalf("RRF: got %v", got)
+	}
+	byID := make(map[string]SearchResult)
+	for _, r := range res {
+		byID[r.ID] = r
+	}
+	if byID["text"].Distance != 9 || byID["text"].TextScore <= byID["both"].TextScore {
+		t.Errorf("text-only candidate: %+v", byID["text"])
+	}
+	if byID["near"].TextScore != 0 || byID["both"].Distance != 1 || byID["both"].TextScore == 0 {
+		t.Errorf("component scores: %+v", res)
+	}
+
+	// Weighted fusion: with text weighted out, vector order wins.
+	opts = HybridOptions{Fusion: FusionWeighted, VectorWeight: 1}
+	res, _ = s.SearchHybrid([]float32{0, 0}, "apple", 2, opts)
+	if got := textIDs(res); !reflect.DeepEqual(got, []string{"near", "both"}) {
+		t.Errorf("vector-only weights: got %v", got)
+	}
+	opts = HybridOptions{Fusion: FusionWeighted, TextWeight: 1}
+	res, _ = s.SearchHybrid([]float32{0, 0}, "apple", 1, opts)
+	if len(res) != 1 || res[0].ID != "text" || res[0].Score != 1 {
+		t.Errorf("text-only weights: got %+v", res)
+	}
+
+	if _, err := s.SearchHybrid([]float32{0}, "apple", 1, HybridOptions{}); err != ErrDimensionMismatch {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := NewVectorStore(2).SearchHybrid([]float32{0, 0}, "apple", 1, HybridOptions{}); err != ErrTextIndexDisabled {
+		t.Errorf("expected ErrTextIndexDisabled, got %v", err)
+	}
+}
+
+func TestTextIndexConcurrent(t *testing.T) {
+	s := NewVectorStore(2, WithShards(2), WithTextIndex("body"))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 500 {
+			id := fmt.Sprintf("v%d", i%50)
+			s.Insert(Vector{ID: id, Data: []float32{float32(i), 0}, Payload: Payload{"body": fmt.Sprintf("word%d shared", i%7)}})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			if res, _ := s.SearchText("shared", 100); len(res) > 50 {
+				t.Errorf("matched %d rows of 50 vectors", len(res))
+				return
+			}
+			s.SearchHybrid([]float32{1, 0}, "word3", 5, HybridOptions{})
+		}
+	}()
+	wg.Wait()
+	if res, _ := s.SearchText("shared", 100); len(res) != 50 {
+		t.Errorf("expected 50 matches, got %d", len(res))
+	}
+}
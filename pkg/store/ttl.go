@@ -0,0 +1,156 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// Expired vectors disappear from searches, lookups, Count, Scan and deletes
+// as soon as their expiry passes, but stay in the shard until the reaper
+// tombstones them. Other writes act on stored rows, so until it is reaped an
+// expired vector can still be renamed or overwritten.
+
+// DefaultReapInterval is the StartReaper interval used for values <= 0.
+const DefaultReapInterval = time.Minute
+
+// WithDefaultTTL makes vectors inserted without an ExpiresAt expire ttl
+// after insertion. Values <= 0 are ignored.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		if ttl > 0 {
+			c.defaultTTL = ttl
+		}
+	}
+}
+
+// DefaultTTL returns the TTL set by WithDefaultTTL, or 0 if there is none.
+func (s *VectorStore) DefaultTTL() time.Duration {
+	return s.defaultTTL
+}
+
+// expiryOf returns the row expiry for v in Unix nanoseconds, 0 for never.
+func (s *VectorStore) expiryOf(v Vector) int64 {
+	if !v.ExpiresAt.IsZero() {
+		return v.ExpiresAt.UnixNano()
+	}
+	if s.defaultTTL > 0 {
+		return time.Now().Add(s.defaultTTL).UnixNano()
+	}
+	return 0
+}
+
+// unixTime converts a row expiry back to a time, keeping 0 as the zero time.
+func unixTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// expiredAt reports whether row i has expired at now.
+func (r *shardRows) expiredAt(i int, now int64) bool {
+	e := r.expires[i]
+	return e != 0 && e <= now
+}
+
+// mayExpireBy reports whether any row may have expired at now. minExpiry is
+// recomputed by compaction and by each reaper pass, so it can be stale-low
+// between them but never high.
+func (r *shardRows) mayExpireBy(now int64) bool {
+	return r.minExpiry != 0 && r.minExpiry <= now
+}
+
+// aliveAt reports whether row i is visible at epoch and unexpired at now.
+func (r *shardRows) aliveAt(i int, epoch uint64, now int64) bool {
+	return r.visibleAt(i, epoch) && !r.expiredAt(i, now)
+}
+
+// allAliveAt reports whether every row is visible at epoch and unexpired at
+// now, letting scans skip the per-row checks.
+func (r *shardRows) allAliveAt(epoch uint64, now int64) bool {
+	return r.allVisibleAt(epoch) && !r.mayExpireBy(now)
+}
+
+// ReapExpired tombstones every expired vector and returns how many it
+// removed. Each shard's expired vectors are deleted under one version, and
+// the rows are reclaimed by compaction like any other delete. The pass also
+// recomputes the shard's earliest expiry, so scans regain their fast path.
+func (s *VectorStore) ReapExpired() int {
+	s.reshardMu.RLock()
+	defer s.reshardMu.RUnlock()
+
+	now := time.Now().UnixNano()
+	set := s.layout.Load()
+	total := 0
+	for i := range set.shards {
+		sh := &set.shards[i]
+		if !sh.rows.Load().mayExpireBy(now) {
+			continue
+		}
+
+		sh.mu.Lock()
+		var (
+			version   uint64
+			changes   []Change
+			minExpiry int64
+		)
+		for idx, id := range sh.w.ids {
+			if e := sh.w.expires[idx]; e > now {
+				if minExpiry == 0 || e < minExpiry {
+					minExpiry = e
+				}
+				continue
+			}
+			if sh.w.deleted[idx] != 0 || !sh.w.expiredAt(idx, now) {
+				continue
+			}
+			if version == 0 {
+				version = s.clock.begin()
+			}
+			sh.markDeleted(idx, version)
+			changes = s.recordChange(changes, ChangeDelete, Vector{ID: id}, version)
+			total++
+		}
+		// Every row expiring by now is tombstoned, so only later expiries
+		// remain for readers to check.
+		sh.w.minExpiry = minExpiry
+		if version != 0 {
+			sh.maybeCompact(s.dimension, s.clock)
+		}
+		sh.publish()
+		sh.mu.Unlock()
+		if version != 0 {
+			s.clock.commit(version, changes)
+		}
+	}
+	return total
+}
+
+// StartReaper runs ReapExpired every interval in a background goroutine
+// until the returned stop function is called. stop waits for a pass in
+// progress to finish. Intervals <= 0 mean DefaultReapInterval.
+func (s *VectorStore) StartReaper(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.ReapExpired()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
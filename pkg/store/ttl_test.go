@@ -0,0 +1,217 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExpiredHiddenFromReads(t *testing.T) {
+	s := NewVectorStore(2)
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+	s.Insert(Vector{ID: "gone", Data: []float32{0, 0}, ExpiresAt: past})
+	s.Insert(Vector{ID: "later", Data: []float32{1, 1}, ExpiresAt: future})
+	s.Insert(Vector{ID: "forever", Data: []float32{2, 2}})
+
+	if n := s.Count(); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+	if s.Contains("gone") {
+		t.Error("Contains reported an expired vector")
+	}
+	if _, err := s.Get("gone"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for expired vector, got %v", err)
+	}
+	if got := s.GetBatch([]string{"gone", "later"}); len(got) != 1 || got[0].ID != "later" {
+		t.Errorf("unexpected GetBatch result: %+v", got)
+	}
+	if v, _ := s.Get("later"); !v.ExpiresAt.Equal(future) {
+		t.Errorf("ExpiresAt = %v, want %v", v.ExpiresAt, future)
+	}
+
+	res, _ := s.Search([]float32{0, 0}, 3)
+	if len(res) != 2 || res[0].ID != "later" {
+		t.Errorf("unexpected search results: %+v", res)
+	}
+	if res, _ := s.SearchRange([]float32{0, 0}, 10, RangeOptions{}); len(res) != 2 {
+		t.Errorf("expected 2 range results, got %d", len(res))
+	}
+	n := 0
+	for v := range s.Scan(ScanOptions{}) {
+		if v.ID == "gone" {
+			t.Error("Scan yielded an expired vector")
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("Scan yielded %d vectors, want 2", n)
+	}
+	n = 0
+	for v := range s.Scan(ScanOptions{Expired: true}) {
+		if v.ID == "gone" && !v.ExpiresAt.Equal(past) {
+			t.Errorf("expired vector scanned with ExpiresAt %v", v.ExpiresAt)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("Scan with Expired yielded %d vectors, want 3", n)
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	s := NewVectorStore(2, WithDefaultTTL(50*time.Millisecond))
+	s.Insert(Vector{ID: "short", Data: []float32{1, 1}})
+	s.Insert(Vector{ID: "pinned", Data: []float32{1, 1}, ExpiresAt: time.Now().Add(time.Hour)})
+	if s.Count() != 2 {
+		t.Fatalf("expected 2 vectors before expiry, got %d", s.Count())
+	}
+	time.Sleep(60 * time.Millisecond)
+	if s.Count() != 1 || !s.Contains("pinned") {
+		t.Fatalf("expected only the pinned vector after expiry, got %d", s.Count())
+	}
+}
+
+func TestReapExpired(t *testing.T) {
+	s := NewVectorStore(2, WithChangeLog(1000))
+	past := time.Now().Add(-time.Second)
+	for i := range 100 {
+		v := Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{float32(i), 0}}
+		if i%2 == 0 {
+			v.ExpiresAt = past
+		}
+		s.Insert(v)
+	}
+	_, next, _ := s.ChangeLogBounds()
+
+	if n := s.ReapExpired(); n != 50 {
+		t.Fatalf("ReapExpired removed %d, want 50", n)
+	}
+	if n := s.ReapExpired(); n != 0 {
+		t.Fatalf("second pass removed %d, want 0", n)
+	}
+	if s.Count() != 50 {
+		t.Fatalf("Count = %d, want 50", s.Count())
+	}
+
+	changes, _ := s.Changes(next, 0)
+	if len(changes) != 50 {
+		t.Fatalf("expected 50 logged deletes, got %d", len(changes))
+	}
+	for _, c := range changes {
+		if c.Kind != ChangeDelete {
+			t.Fatalf("unexpected change %+v", c)
+		}
+	}
+
+	// A reaped ID can be inserted again.
+	s.Insert(Vector{ID: "v0", Data: []float32{0, 0}})
+	if !s.Contains("v0") {
+		t.Fatal("reinserted vector missing")
+	}
+}
+
+func TestReapRestoresFastPath(t *testing.T) {
+	s := NewVectorStore(2, WithShards(1))
+	later := time.Now().Add(time.Hour)
+	for i := range 10 {
+		s.Insert(Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{1, 1}, ExpiresAt: later})
+	}
+	s.Insert(Vector{ID: "gone", Data: []float32{0, 0}, ExpiresAt: time.Now().Add(-time.Second)})
+
+	// One tombstone does not compact, but the pass still lifts the
+	// shard's earliest expiry past the rows it reaped.
+	if n := s.ReapExpired(); n != 1 {
+		t.Fatalf("ReapExpired removed %d, want 1", n)
+	}
+	rows := s.layout.Load().shards[0].rows.Load()
+	if rows.dead != 1 || rows.minExpiry != later.UnixNano() {
+		t.Fatalf("dead=%d minExpiry=%d, want 1 and %d", rows.dead, rows.minExpiry, later.UnixNano())
+	}
+	if rows.mayExpireBy(time.Now().UnixNano()) {
+		t.Error("shard still reports expired rows after the reap")
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "gone", Data: []float32{0, 0}, ExpiresAt: time.Now().Add(-time.Second)})
+	if err := s.Delete("gone"); err != ErrNotFound {
+		t.Errorf("Delete: expected ErrNotFound, got %v", err)
+	}
+	txn := s.Begin()
+	txn.Delete("gone")
+	if err := txn.Commit(); err != ErrNotFound {
+		t.Errorf("Txn delete: expected ErrNotFound, got %v", err)
+	}
+	// Remove tombstones it and ignores IDs that are not stored.
+	txn = s.Begin()
+	txn.Remove("gone")
+	txn.Remove("missing")
+	if err := txn.Commit(); err != nil {
+		t.Errorf("Txn remove: %v", err)
+	}
+	if rows := s.layout.Load().shardFor("gone").rows.Load(); rows.live != 0 {
+		t.Errorf("Remove left %d live rows", rows.live)
+	}
+
+	// Overwriting the expired vector in the transaction makes it deletable.
+	txn = s.Begin()
+	txn.Insert(Vector{ID: "gone", Data: []float32{1, 1}})
+	txn.Delete("gone")
+	if err := txn.Commit(); err != nil {
+		t.Errorf("Txn upsert then delete: %v", err)
+	}
+}
+
+func TestExpiryFollowsWrites(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "a", Data: []float32{1, 1}, ExpiresAt: time.Now().Add(-time.Second)})
+
+	// An upsert without ExpiresAt clears the old expiry.
+	s.Insert(Vector{ID: "a", Data: []float32{2, 2}})
+	if !s.Contains("a") {
+		t.Fatal("upserted vector still expired")
+	}
+
+	// Rename carries the expiry with the vector.
+	s.Insert(Vector{ID: "b", Data: []float32{3, 3}, ExpiresAt: time.Now().Add(-time.Second)})
+	if err := s.Rename("b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Contains("c") {
+		t.Fatal("rename revived an expired vector")
+	}
+
+	if err := s.Reshard(3); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 1 || s.Contains("c") {
+		t.Fatalf("reshard changed expiry, count=%d", s.Count())
+	}
+}
+
+func TestStartReaper(t *testing.T) {
+	s := NewVectorStore(2, WithDefaultTTL(20*time.Millisecond))
+	s.Insert(Vector{ID: "a", Data: []float32{1, 1}})
+	stop := s.StartReaper(5 * time.Millisecond)
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		set := s.layout.Load()
+		if set.shardFor("a").rows.Load().live == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reaper did not remove the expired vector")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+	stop()
+
+	// A non-positive interval falls back to the default instead of
+	// panicking.
+	s.StartReaper(0)()
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"slices"
+	"time"
+)
+
+// Txn buffers inserts and deletes and applies them atomically on Commit.
+// All operations share one version, so readers see either none of them or
+// all of them, across every shard they touch. A Txn is not safe for
+// concurrent use.
+type Txn struct {
+	s      *VectorStore
+	ops    []txnOp
+	closed bool
+}
+
+type txnOp struct {
+	del   bool
+	force bool // a Remove: no error if the ID is missing or expired
+	v     Vector
+}
+
+// Begin starts a transaction on the store.
+func (s *VectorStore) Begin() *Txn {
+	return &Txn{s: s}
+}
+
+// Insert buffers an insert or upsert. The vector's data and payload are
+// copied. Validation errors are reported by Commit.
+func (t *Txn) Insert(v Vector) {
+	t.ops = append(t.ops, txnOp{v: v.Clone()})
+}
+
+// Delete buffers a delete of id.
+func (t *Txn) Delete(id string) {
+	t.ops = append(t.ops, txnOp{del: true, v: Vector{ID: id}})
+}
+
+// Remove buffers a delete of id that, unlike Delete, also removes a vector
+// that has expired but not been reaped, and does nothing if id is not
+// stored. Replicas use it to mirror another store's deletes, including its
+// reaps, whatever their own clock says.
+func (t *Txn) Remove(id string) {
+	t.ops = append(t.ops, txnOp{del: true, force: true, v: Vector{ID: id}})
+}
+
+// Len returns the number of buffered operations.
+func (t *Txn) Len() int {
+	return len(t.ops)
+}
+
+// Rollback discards the buffered operations and closes the transaction.
+func (t *Txn) Rollback() {
+	t.ops = nil
+	t.closed = true
+}
+
+// Commit validates every buffered operation and applies them all at a
+// single version. If any insert has an empty ID or the wrong dimension, or
+// any delete targets an ID that does not exist or has expired at that
+// point in the transaction, nothing is applied and the error is returned. The
+// transaction is closed either way.
+func (t *Txn) Commit() error {
+	if t.closed {
+		return ErrTxnClosed
+	}
+	t.closed = true
+	ops := t.ops
+	t.ops = nil
+
+	s := t.s
+	for _, op := range ops {
+		if op.del {
+			continue
+		}
+		if op.v.ID == "" {
+			return ErrEmptyID
+		}
+		if err := s.checkShape(op.v); err != nil {
+			return err
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	s.reshardMu.RLock()
+	defer s.reshardMu.RUnlock()
+
+	set := s.layout.Load()
+	n := len(set.shards)
+	indexes := make([]int, len(ops))
+	for i, op := range ops {
+		indexes[i] = shardIndex(op.v.ID, n)
+	}
+	locked := set.lockShards(slices.Clone(indexes))
+	defer set.unlockShards(locked)
+
+	// Check deletes against the store as modified by earlier operations in
+	// this transaction, before anything is written.
+	exists := make(map[string]bool)
+	now := time.Now().UnixNano()
+	for i, op := range ops {
+		present, seen := exists[op.v.ID]
+		if !seen {
+			sh := &set.shards[indexes[i]]
+			idx, ok := sh.idIndex[op.v.ID]
+			present = ok && !sh.w.expiredAt(idx, now)
+		}
+		if op.del && !present && !op.force {
+			return ErrNotFound
+		}
+		exists[op.v.ID] = !op.del
+	}
+
+	version := s.clock.begin()
+	var changes []Change
+	for i, op := range ops {
+		sh := &set.shards[indexes[i]]
+		kind := ChangeInsert
+		idx, stored := sh.idIndex[op.v.ID]
+		if stored {
+			sh.markDeleted(idx, version)
+			kind = ChangeUpsert
+		}
+		if op.del {
+			if !stored {
+				continue // a Remove of a missing ID
+			}
+			kind = ChangeDelete
+		} else {
+			expires := s.expiryOf(op.v)
+			var tokens []float32
+			op.v.Data, tokens = s.rowVectors(op.v)
+			sh.appendRow(op.v.ID, op.v.Data, op.v.Sparse, tokens, op.v.Payload, expires, version)
+			op.v.ExpiresAt = unixTime(expires)
+		}
+		changes = s.recordChange(changes, kind, op.v, version)
+	}
+	for _, i := range locked {
+		set.shards[i].maybeCompact(s.dimension, s.clock)
+		set.shards[i].publish()
+	}
+	s.clock.commit(version, changes)
+	return nil
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTxnCommit(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "old-1", Data: []float32{1, 1}})
+	s.Insert(Vector{ID: "old-2", Data: []float32{2, 2}})
+
+	txn := s.Begin()
+	txn.Delete("old-1")
+	txn.Delete("old-2")
+	txn.Insert(Vector{ID: "new-1", Data: []float32{3, 3}, Payload: Payload{"doc": "d"}})
+	txn.Insert(Vector{ID: "new-2", Data: []float32{4, 4}})
+	txn.Insert(Vector{ID: "new-3", Data: []float32{5, 5}})
+	if txn.Len() != 5 {
+		t.Fatalf("expected 5 buffered ops, got %d", txn.Len())
+	}
+	if s.Count() != 2 {
+		t.Fatal("buffered operations applied before Commit")
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if s.Count() != 3 || s.Contains("old-1") || s.Contains("old-2") {
+		t.Fatalf("unexpected state after commit, count=%d", s.Count())
+	}
+	if v, _ := s.Get("new-1"); v.Payload["doc"] != "d" {
+		t.Fatalf("unexpected payload: %+v", v)
+	}
+	if err := txn.Commit(); err != ErrTxnClosed {
+		t.Fatalf("expected ErrTxnClosed, got %v", err)
+	}
+}
+
+func TestTxnValidationRollsBack(t *testing.T) {
+	s := NewVectorStore(2)
+	s.Insert(Vector{ID: "a", Data: []float32{1, 1}})
+
+	txn := s.Begin()
+	txn.Delete("a")
+	txn.Insert(Vector{ID: "b", Data: []float32{2, 2}})
+	txn.Insert(Vector{ID: "c", Data: []float32{3}})
+	if err := txn.Commit(); err != ErrDimensionMismatch {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+
+	txn = s.Begin()
+	txn.Insert(Vector{ID: "b", Data: []float32{2, 2}})
+	txn.Delete("missing")
+	if err := txn.Commit(); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	txn = s.Begin()
+	txn.Insert(Vector{ID: "", Data: []float32{2, 2}})
+	if err := txn.Commit(); err != ErrEmptyID {
+		t.Fatalf("expected ErrEmptyID, got %v", err)
+	}
+
+	if s.Count() != 1 || !s.Contains("a") || s.Contains("b") {
+		t.Fatal("failed transactions modified the store")
+	}
+
+	txn = s.Begin()
+	txn.Insert(Vector{ID: "b", Data: []float32{2, 2}})
+	txn.Rollback()
+	if err := txn.Commit(); err != ErrTxnClosed || s.Contains("b") {
+		t.Fatalf("rolled back transaction applied: %v", err)
+	}
+}
+
+func TestTxnOrderWithinTransaction(t *testing.T) {
+	s := NewVectorStore(1)
+
+	// Deleting an ID inserted earlier in the same transaction is allowed,
+	// and the last write to an ID wins.
+	txn := s.Begin()
+	txn.Insert(Vector{ID: "tmp", Data: []float32{1}})
+	txn.Delete("tmp")
+	txn.Insert(Vector{ID: "x", Data: []float32{1}})
+	txn.Insert(Vector{ID: "x", Data: []float32{2}})
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if s.Contains("tmp") || s.Count() != 1 {
+		t.Fatalf("unexpected state, count=%d", s.Count())
+	}
+	if v, _ := s.Get("x"); v.Data[0] != 2 {
+		t.Fatalf("expected last write to win, got %+v", v)
+	}
+
+	// Deleting twice fails the second time.
+	txn = s.Begin()
+	txn.Delete("x")
+	txn.Delete("x")
+	if err := txn.Commit(); err != ErrNotFound || !s.Contains("x") {
+		t.Fatalf("expected ErrNotFound with no changes, got %v", err)
+	}
+}
+
+// TestTxnAtomicVisibility replaces a document's chunks over and over while
+// readers check they never see a mix of generations.
+func TestTxnAtomicVisibility(t *testing.T) {
+	const chunks = 8
+	s := NewVectorStore(2, WithShards(16))
+	write := func(gen int) error {
+		txn := s.Begin()
+		if gen > 0 {
+			for c := 0; c < chunks; c++ {
+				txn.Delete(fmt.Sprintf("doc-g%d-c%d", gen-1, c))
+			}
+		}
+		for c := 0; c < chunks; c++ {
+			txn.Insert(Vector{ID: fmt.Sprintf("doc-g%d-c%d", gen, c), Data: []float32{float32(gen), float32(c)}})
+		}
+		return txn.Commit()
+	}
+	if err := write(0); err != nil {
+		t.Fatalf("initial write failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for gen := 1; ; gen++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := write(gen); err != nil {
+				t.Errorf("write gen %d failed: %v", gen, err)
+				return
+			}
+		}
+	}()
+
+	for iter := 0; iter < 300; iter++ {
+		results, _ := s.Search([]float32{0, 0}, 100)
+		if len(results) != chunks {
+			t.Fatalf("saw %d chunks, want %d", len(results), chunks)
+		}
+		gen := results[0].ID[:strings.Index(results[0].ID, "-c")]
+		for _, r := range results {
+			if !strings.HasPrefix(r.ID, gen+"-") {
+				t.Fatalf("saw mixed generations: %s and %s", gen, r.ID)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
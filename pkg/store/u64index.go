@@ -0,0 +1,118 @@
+package store
+
+// u64Index is an open-addressing hash map from uint64 ID to row position,
+// using linear probing and backward-shift deletion so lookups never have to
+// skip tombstones. Slots hold no pointers, so the GC does not scan them.
+type u64Index struct {
+	slots []u64Slot
+	n     int
+}
+
+type u64Slot struct {
+	key uint64
+	pos uint32 // row position + 1; 0 marks an empty slot
+}
+
+const u64IndexMinSize = 16
+
+// mix64 is the splitmix64 finalizer. It spreads sequential IDs across the
+// whole 64-bit range so both the index and shard routing stay balanced.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (m *u64Index) len() int { return m.n }
+
+// find returns the slot holding key, or the empty slot where it would go.
+func (m *u64Index) find(key uint64) (int, bool) {
+	mask := len(m.slots) - 1
+	i := int(mix64(key)) & mask
+	for {
+		sl := &m.slots[i]
+		if sl.pos == 0 {
+			return i, false
+		}
+		if sl.key == key {
+			return i, true
+		}
+		i = (i + 1) & mask
+	}
+}
+
+func (m *u64Index) get(key uint64) (int, bool) {
+	if m.n == 0 {
+		return 0, false
+	}
+	i, ok := m.find(key)
+	if !ok {
+		return 0, false
+	}
+	return int(m.slots[i].pos - 1), true
+}
+
+func (m *u64Index) set(key uint64, pos int) {
+	// Keep the load factor at or below 3/4.
+	if (m.n+1)*4 > len(m.slots)*3 {
+		m.grow()
+	}
+	i, ok := m.find(key)
+	if !ok {
+		m.n++
+	}
+	m.slots[i] = u64Slot{key: key, pos: uint32(pos) + 1}
+}
+
+func (m *u64Index) delete(key uint64) {
+	if m.n == 0 {
+		return
+	}
+	i, ok := m.find(key)
+	if !ok {
+		return
+	}
+	m.n--
+
+	// Shift later entries of the probe run back into the hole unless their
+	// home slot lies cyclically within (i, j].
+	mask := len(m.slots) - 1
+	j := i
+	for {
+		j = (j + 1) & mask
+		if m.slots[j].pos == 0 {
+			break
+		}
+		home := int(mix64(m.slots[j].key)) & mask
+		if i <= j {
+			if i < home && home <= j {
+				continue
+			}
+		} else if i < home || home <= j {
+			continue
+		}
+		m.slots[i] = m.slots[j]
+		i = j
+	}
+	m.slots[i] = u64Slot{}
+}
+
+func (m *u64Index) grow() {
+	size := len(m.slots) * 2
+	if size < u64IndexMinSize {
+		size = u64IndexMinSize
+	}
+	old := m.slots
+	m.slots = make([]u64Slot, size)
+	m.n = 0
+	for _, sl := range old {
+		if sl.pos != 0 {
+			i, _ := m.find(sl.key)
+			m.slots[i] = sl
+			m.n++
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package store
+
+import "sync"
+
+// Uint64Vector is a vector keyed by a numeric ID.
+type Uint64Vector struct {
+	ID   uint64
+	Data []float32
+}
+
+// Uint64Result is a search result from a Uint64Store.
+type Uint64Result = Result[uint64]
+
+// u64Shard is the numeric-ID counterpart of shard: the same SoA layout, with
+// IDs in a flat []uint64 and an open-addressing index instead of a map.
+type u64Shard struct {
+	ids   []uint64
+	data  []float32 // contiguous: vector i at data[i*dim : (i+1)*dim]
+	index u64Index
+	mu    sync.RWMutex
+}
+
+// Uint64Store is a VectorStore variant keyed by uint64 IDs. It avoids the
+// per-vector string header, map entry and string hashing of VectorStore,
+// and its results carry IDs by value so searches allocate no strings.
+type Uint64Store struct {
+	shards    []u64Shard
+	dimension int
+}
+
+var u64ScratchPool = sync.Pool{
+	New: func() any { return new(searchScratch[uint64]) },
+}
+
+// Uint64Option configures a Uint64Store at construction time. The store
+// has none of VectorStore's TTL, change log or text index, so it takes its
+// own options rather than Option.
+type Uint64Option func(*uint64Config)
+
+type uint64Config struct {
+	numShards int
+}
+
+// WithUint64Shards sets the number of shards. Values <= 0 are ignored.
+func WithUint64Shards(n int) Uint64Option {
+	return func(c *uint64Config) {
+		if n > 0 {
+			c.numShards = n
+		}
+	}
+}
+
+// NewUint64Store creates a new Uint64Store with the specified dimension.
+func NewUint64Store(dimension int, opts ...Uint64Option) *Uint64Store {
+	cfg := uint64Config{numShards: DefaultNumShards}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Uint64Store{
+		shards:    make([]u64Shard, cfg.numShards),
+		dimension: dimension,
+	}
+}
+
+// u64ShardIndex routes id by the high bits of its mixed hash; the shard's
+// index uses the low bits, so the two stay independent.
+func u64ShardIndex(id uint64, n int) int {
+	return int((mix64(id) >> 32) % uint64(n))
+}
+
+func (s *Uint64Store) shardFor(id uint64) *u64Shard {
+	return &s.shards[u64ShardIndex(id, len(s.shards))]
+}
+
+// Insert adds a vector to the store, replacing any vector with the same ID.
+func (s *Uint64Store) Insert(v Uint64Vector) error {
+	if len(v.Data) != s.dimension {
+		return ErrDimensionMismatch
+	}
+
+	sh := s.shardFor(v.ID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	dim := s.dimension
+
+	if idx, exists := sh.index.get(v.ID); exists {
+		copy(sh.data[idx*dim:(idx+1)*dim], v.Data)
+		return nil
+	}
+
+	sh.index.set(v.ID, len(sh.ids))
+	sh.ids = append(sh.ids, v.ID)
+	sh.data = append(sh.data, v.Data...)
+	return nil
+}
+
+// Delete removes a vector from the store by ID.
+func (s *Uint64Store) Delete(id uint64) error {
+	sh := s.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	idx, exists := sh.index.get(id)
+	if !exists {
+		return ErrNotFound
+	}
+
+	dim := s.dimension
+	lastIdx := len(sh.ids) - 1
+
+	if idx != lastIdx {
+		// Swap with last: copy last vector's data into the deleted slot
+		sh.ids[idx] = sh.ids[lastIdx]
+		copy(sh.data[idx*dim:(idx+1)*dim], sh.data[lastIdx*dim:(lastIdx+1)*dim])
+		sh.index.set(sh.ids[idx], idx)
+	}
+
+	sh.ids = sh.ids[:lastIdx]
+	sh.data = sh.data[:lastIdx*dim]
+	sh.index.delete(id)
+
+	return nil
+}
+
+// Get returns a copy of the vector stored under id.
+func (s *Uint64Store) Get(id uint64) (Uint64Vector, error) {
+	sh := s.shardFor(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	idx, exists := sh.index.get(id)
+	if !exists {
+		return Uint64Vector{}, ErrNotFound
+	}
+	dim := s.dimension
+	v := Uint64Vector{ID: id, Data: make([]float32, dim)}
+	copy(v.Data, sh.data[idx*dim:(idx+1)*dim])
+	return v, nil
+}
+
+// Contains reports whether a vector with the given id is stored.
+func (s *Uint64Store) Contains(id uint64) bool {
+	sh := s.shardFor(id)
+	sh.mu.RLock()
+	_, exists := sh.index.get(id)
+	sh.mu.RUnlock()
+	return exists
+}
+
+// Count returns the number of vectors in the store.
+func (s *Uint64Store) Count() int {
+	total := 0
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+		total += len(s.shards[i].ids)
+		s.shards[i].mu.RUnlock()
+	}
+	return total
+}
+
+// Dimension returns the dimension of vectors in this store.
+func (s *Uint64Store) Dimension() int {
+	return s.dimension
+}
+
+// Search performs a k-NN search using Euclidean distance.
+func (s *Uint64Store) Search(query []float32, k int) ([]Uint64Result, error) {
+	return s.searchInto(nil, query, k, Euclidean)
+}
+
+// SearchCosine performs a k-NN search using cosine distance.
+func (s *Uint64Store) SearchCosine(query []float32, k int) ([]Uint64Result, error) {
+	return s.searchInto(nil, query, k, Cosine)
+}
+
+// SearchInto is like Search but appends the results to dst[:0].
+func (s *Uint64Store) SearchInto(dst []Uint64Result, query []float32, k int) ([]Uint64Result, error) {
+	return s.searchInto(dst[:0], query, k, Euclidean)
+}
+
+// SearchCosineInto is the cosine-distance counterpart of SearchInto.
+func (s *Uint64Store) SearchCosineInto(dst []Uint64Result, query []float32, k int) ([]Uint64Result, error) {
+	return s.searchInto(dst[:0], query, k, Cosine)
+}
+
+func (s *Uint64Store) searchInto(dst []Uint64Result, query []float32, k int, metric Metric) ([]Uint64Result, error) {
+	if len(query) != s.dimension {
+		return nil, ErrDimensionMismatch
+	}
+	if k <= 0 {
+		if dst == nil {
+			dst = []Uint64Result{}
+		}
+		return dst, nil
+	}
+
+	dim := s.dimension
+	distFn := metric.scanDistance()
+	nWorkers := workerCount(len(s.shards))
+
+	sc := getScratch[uint64](&u64ScratchPool, nWorkers, k)
+	parallelRanges(len(s.shards), nWorkers, func(workerID, start, end int) {
+		h := &sc.heaps[workerID]
+		for si := start; si < end; si++ {
+			sh := &s.shards[si]
+			sh.mu.RLock()
+			n := len(sh.ids)
+			for i := 0; i < n; i++ {
+				dist := distFn(query, sh.data[i*dim:(i+1)*dim])
+				if h.wants(dist) {
+					h.offer(Uint64Result{ID: sh.ids[i], Distance: dist})
+				}
+			}
+			sh.mu.RUnlock()
+		}
+	})
+
+	for i := range sc.heaps {
+		for _, r := range sc.heaps[i].items {
+			sc.final.offer(r)
+		}
+	}
+
+	if dst == nil {
+		dst = make([]Uint64Result, 0, sc.final.len())
+	}
+	start := len(dst)
+	dst = sc.final.appendSorted(dst)
+	for i := start; i < len(dst); i++ {
+		dst[i].Distance = metric.finalize(dst[i].Distance)
+	}
+	putScratch(&u64ScratchPool, sc)
+
+	return dst, nil
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// TestU64IndexMatchesMap drives the open-addressing index and a Go map with
+// the same random operations and checks they agree.
+func TestU64IndexMatchesMap(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	var idx u64Index
+	ref := make(map[uint64]int)
+
+	for op := 0; op < 100000; op++ {
+		// A small key space forces collisions, updates and long probe runs.
+		key := uint64(rng.Intn(2000))
+		switch rng.Intn(3) {
+		case 0, 1:
+			idx.set(key, op)
+			ref[key] = op
+		case 2:
+			idx.delete(key)
+			delete(ref, key)
+		}
+	}
+
+	if idx.len() != len(ref) {
+		t.Fatalf("index has %d entries, map has %d", idx.len(), len(ref))
+	}
+	for key := uint64(0); key < 2000; key++ {
+		got, ok := idx.get(key)
+		want, wantOK := ref[key]
+		if ok != wantOK || got != want {
+			t.Fatalf("key %d: got (%d, %v), want (%d, %v)", key, got, ok, want, wantOK)
+		}
+	}
+}
+
+func TestUint64StoreBasic(t *testing.T) {
+	s := NewUint64Store(2)
+	s.Insert(Uint64Vector{ID: 0, Data: []float32{0, 0}})
+	s.Insert(Uint64Vector{ID: 1, Data: []float32{1, 0}})
+	s.Insert(Uint64Vector{ID: 2, Data: []float32{10, 10}})
+	if err := s.Insert(Uint64Vector{ID: 3, Data: []float32{1}}); err != ErrDimensionMismatch {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if s.Count() != 3 || s.Dimension() != 2 {
+		t.Fatalf("unexpected count %d / dimension %d", s.Count(), s.Dimension())
+	}
+
+	results, err := s.Search([]float32{0, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != 0 || results[1].ID != 1 || results[1].Distance != 1 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	cos, _ := s.SearchCosine([]float32{1, 1}, 1)
+	if cos[0].ID != 2 {
+		t.Fatalf("expected ID 2 for cosine, got %+v", cos)
+	}
+
+	// Upsert, Get, Contains, Delete.
+	s.Insert(Uint64Vector{ID: 1, Data: []float32{5, 5}})
+	v, err := s.Get(1)
+	if err != nil || v.Data[0] != 5 {
+		t.Fatalf("unexpected Get after update: %+v, %v", v, err)
+	}
+	if err := s.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if s.Contains(1) || !s.Contains(0) || s.Count() != 2 {
+		t.Fatal("unexpected membership after delete")
+	}
+	if err := s.Delete(1); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Get(1); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestUint64StoreMatchesVectorStore checks both stores return the same
+// neighbors for the same data.
+func TestUint64StoreMatchesVectorStore(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	u := NewUint64Store(8, WithUint64Shards(7))
+	if len(u.shards) != 7 {
+		t.Fatalf("got %d shards, want 7", len(u.shards))
+	}
+	s := NewVectorStore(8)
+	ids := make(map[string]uint64)
+	for i := 0; i < 3000; i++ {
+		data := make([]float32, 8)
+		for j := range data {
+			data[j] = rng.Float32()
+		}
+		id := uint64(i) * 1000003
+		u.Insert(Uint64Vector{ID: id, Data: data})
+		name := fmt.Sprintf("v-%d", i)
+		s.Insert(Vector{ID: name, Data: data})
+		ids[name] = id
+	}
+	for i := 0; i < 500; i += 3 {
+		u.Delete(uint64(i) * 1000003)
+		s.Delete(fmt.Sprintf("v-%d", i))
+	}
+
+	query := make([]float32, 8)
+	for j := range query {
+		query[j] = rng.Float32()
+	}
+	want, _ := s.Search(query, 20)
+	dst := make([]Uint64Result, 0, 20)
+	got, _ := u.SearchInto(dst, query, 20)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].ID != ids[want[i].ID] || got[i].Distance != want[i].Distance {
+			t.Fatalf("result %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUint64StoreConcurrent(t *testing.T) {
+	s := NewUint64Store(4)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				id := uint64(w*1000 + i)
+				s.Insert(Uint64Vector{ID: id, Data: []float32{float32(i), 0, 0, 0}})
+				if i%2 == 0 {
+					s.Delete(id)
+				}
+				s.Search([]float32{0, 0, 0, 0}, 3)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if s.Count() != 8*250 {
+		t.Fatalf("expected %d, got %d", 8*250, s.Count())
+	}
+}
@@ -0,0 +1,744 @@
+package vecio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"vexor/pkg/store"
+)
+
+// An Arrow IPC stream is a sequence of messages, each an 8-byte-aligned
+// flatbuffer Message (prefixed by 0xFFFFFFFF and its length) followed by a
+// body. The first message is the Schema; RecordBatch messages follow, and a
+// zero length ends the stream. This reader implements the subset needed for
+// embeddings: a utf8 ID column and a FixedSizeList<float32> vector column.
+// Other columns are skipped. Compressed bodies, big-endian data and view
+// types are not supported. See
+// https://arrow.apache.org/docs/format/Columnar.html#serialization-and-interprocess-communication-ipc
+
+// ErrBadArrow is returned for a malformed Arrow stream or one using
+// features this reader does not support.
+var ErrBadArrow = errors.New("vecio: malformed or unsupported arrow stream")
+
+const (
+	arrowContinuation = 0xFFFFFFFF
+	maxArrowMetadata  = 1 << 24
+
+	// Message header union.
+	arrowSchema          = 1
+	arrowDictionaryBatch = 2
+	arrowRecordBatch     = 3
+
+	// Type union.
+	arrowNull            = 1
+	arrowInt             = 2
+	arrowFloatingPoint   = 3
+	arrowBinary          = 4
+	arrowUtf8            = 5
+	arrowBool            = 6
+	arrowDecimal         = 7
+	arrowDate            = 8
+	arrowTime            = 9
+	arrowTimestamp       = 10
+	arrowInterval        = 11
+	arrowList            = 12
+	arrowStruct          = 13
+	arrowUnion           = 14
+	arrowFixedSizeBinary = 15
+	arrowFixedSizeList   = 16
+	arrowMap             = 17
+	arrowDuration        = 18
+	arrowLargeBinary     = 19
+	arrowLargeUtf8       = 20
+	arrowLargeList       = 21
+	arrowRunEndEncoded   = 22
+	arrowListView        = 25
+	arrowLargeListView   = 26
+)
+
+// arrowField is a schema field, with the buffer and node counts its column
+// takes in a record batch.
+type arrowField struct {
+	name     string
+	typ      byte
+	dict     bool
+	listSize int // FixedSizeList
+	float    int // FloatingPoint precision: 0 half, 1 single, 2 double
+	children []arrowField
+	buffers  int
+	nodes    int
+}
+
+// ArrowReader reads vectors from an Arrow IPC stream.
+type ArrowReader struct {
+	r       io.Reader
+	fields  []arrowField
+	idCol   int
+	vecCol  int
+	dim     int
+	skipped int
+	done    bool
+}
+
+// NewArrowReader reads the schema from r and picks the ID and vector
+// columns by name. An empty idColumn picks the first utf8 column and an
+// empty vectorColumn the first FixedSizeList of floats.
+func NewArrowReader(r io.Reader, idColumn, vectorColumn string) (*ArrowReader, error) {
+	ar := &ArrowReader{r: r}
+	typ, meta, body, err := ar.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	if typ != arrowSchema {
+		return nil, fmt.Errorf("%w: stream does not start with a schema", ErrBadArrow)
+	}
+	if len(body) != 0 {
+		return nil, fmt.Errorf("%w: schema message has a body", ErrBadArrow)
+	}
+	if err := ar.parseSchema(meta); err != nil {
+		return nil, err
+	}
+
+	ar.idCol, ar.vecCol = -1, -1
+	for i, f := range ar.fields {
+		if ar.idCol < 0 && !f.dict && (f.typ == arrowUtf8 || f.typ == arrowLargeUtf8) && (idColumn == "" || f.name == idColumn) {
+			ar.idCol = i
+		}
+		if ar.vecCol < 0 && !f.dict && f.typ == arrowFixedSizeList && (vectorColumn == "" || f.name == vectorColumn) {
+			ar.vecCol = i
+		}
+	}
+	if ar.idCol < 0 {
+		return nil, fmt.Errorf("vecio: arrow schema has no utf8 column %q", idColumn)
+	}
+	if ar.vecCol < 0 {
+		return nil, fmt.Errorf("vecio: arrow schema has no fixed-size list column %q", vectorColumn)
+	}
+	vec := ar.fields[ar.vecCol]
+	if len(vec.children) != 1 || vec.children[0].typ != arrowFloatingPoint || vec.children[0].dict {
+		return nil, fmt.Errorf("vecio: arrow column %q is not a list of floats", vec.name)
+	}
+	if vec.listSize <= 0 || vec.listSize > maxDimension {
+		return nil, fmt.Errorf("%w: list size %d", ErrBadArrow, vec.listSize)
+	}
+	ar.dim = vec.listSize
+	return ar, nil
+}
+
+// Dimension returns the vector length, the vector column's list size.
+func (ar *ArrowReader) Dimension() int {
+	return ar.dim
+}
+
+// Skipped returns how many rows so far had a null ID, vector or vector
+// element and were left out.
+func (ar *ArrowReader) Skipped() int {
+	return ar.skipped
+}
+
+// Next returns the vectors of the next record batch, or io.EOF at the end
+// of the stream. The batch's vector data is decoded into one array that
+// the returned vectors share, and the IDs share one string, so a batch
+// costs three allocations however many rows it has.
+func (ar *ArrowReader) Next() ([]store.Vector, error) {
+	for !ar.done {
+		typ, meta, body, err := ar.readMessage()
+		if err == io.EOF {
+			ar.done = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case arrowRecordBatch:
+			return ar.decodeBatch(meta, body)
+		case arrowDictionaryBatch:
+			// Dictionaries only serve dictionary-encoded columns, which
+			// are never the ID or vector column.
+		default:
+			return nil, fmt.Errorf("%w: unexpected message type %d", ErrBadArrow, typ)
+		}
+	}
+	return nil, io.EOF
+}
+
+// readMessage reads one framed message and its body. It returns io.EOF at
+// the end-of-stream marker or a clean end of input.
+func (ar *ArrowReader) readMessage() (typ byte, meta fbTable, body []byte, err error) {
+	var word [4]byte
+	if _, err := io.ReadFull(ar.r, word[:]); err != nil {
+		if err == io.EOF {
+			return 0, fbTable{}, nil, io.EOF
+		}
+		return 0, fbTable{}, nil, fmt.Errorf("vecio: arrow message: %w", err)
+	}
+	n := binary.LittleEndian.Uint32(word[:])
+	if n == arrowContinuation {
+		if _, err := io.ReadFull(ar.r, word[:]); err != nil {
+			return 0, fbTable{}, nil, fmt.Errorf("vecio: arrow message: %w", io.ErrUnexpectedEOF)
+		}
+		n = binary.LittleEndian.Uint32(word[:])
+	}
+	if n == 0 {
+		return 0, fbTable{}, nil, io.EOF
+	}
+	if n > maxArrowMetadata {
+		return 0, fbTable{}, nil, fmt.Errorf("%w: metadata length %d", ErrBadArrow, n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(ar.r, buf); err != nil {
+		return 0, fbTable{}, nil, fmt.Errorf("vecio: arrow message: %w", io.ErrUnexpectedEOF)
+	}
+
+	msg, err := fbRoot(buf)
+	if err != nil {
+		return 0, fbTable{}, nil, err
+	}
+	version, err := msg.i16(0, 0)
+	if err != nil {
+		return 0, fbTable{}, nil, err
+	}
+	if version < 3 {
+		return 0, fbTable{}, nil, fmt.Errorf("%w: metadata version V%d predates V4", ErrBadArrow, version+1)
+	}
+	if typ, err = msg.u8(1, 0); err != nil {
+		return 0, fbTable{}, nil, err
+	}
+	if meta, err = msg.table(2); err != nil {
+		return 0, fbTable{}, nil, err
+	}
+	bodyLen, err := msg.i64(3, 0)
+	if err != nil {
+		return 0, fbTable{}, nil, err
+	}
+	if bodyLen < 0 {
+		return 0, fbTable{}, nil, fmt.Errorf("%w: body length %d", ErrBadArrow, bodyLen)
+	}
+	// Grow with the data rather than trusting bodyLen up front.
+	var b bytes.Buffer
+	b.Grow(int(min(bodyLen, 1<<26)))
+	if _, err := io.CopyN(&b, ar.r, bodyLen); err != nil {
+		return 0, fbTable{}, nil, fmt.Errorf("vecio: arrow body: %w", io.ErrUnexpectedEOF)
+	}
+	return typ, meta, b.Bytes(), nil
+}
+
+func (ar *ArrowReader) parseSchema(schema fbTable) error {
+	endianness, err := schema.i16(0, 0)
+	if err != nil {
+		return err
+	}
+	if endianness != 0 {
+		return fmt.Errorf("%w: big-endian data", ErrBadArrow)
+	}
+	fields, err := schema.vector(1)
+	if err != nil {
+		return err
+	}
+	// Children vectors may point at the same tables, so the depth cap alone
+	// does not bound the work; no real schema has more fields than its
+	// metadata has room for.
+	budget := len(schema.buf) / fbMinField
+	if fields.len() > budget {
+		return fmt.Errorf("%w: schema has too many fields", ErrBadArrow)
+	}
+	ar.fields = make([]arrowField, fields.len())
+	for i := range ar.fields {
+		t, err := fields.table(i)
+		if err != nil {
+			return err
+		}
+		f, err := parseArrowField(t, 0, &budget)
+		if err != nil {
+			return err
+		}
+		ar.fields[i] = f
+	}
+	return nil
+}
+
+// parseArrowField parses a field and its children, taking each from the
+// budget of fields the schema may still declare.
+func parseArrowField(t fbTable, depth int, budget *int) (arrowField, error) {
+	if depth > 64 {
+		return arrowField{}, fmt.Errorf("%w: schema nested too deeply", ErrBadArrow)
+	}
+	if *budget == 0 {
+		return arrowField{}, fmt.Errorf("%w: schema has too many fields", ErrBadArrow)
+	}
+	*budget--
+
+	f := arrowField{nodes: 1}
+	var err error
+	if f.name, err = t.str(0); err != nil {
+		return arrowField{}, err
+	}
+	if f.typ, err = t.u8(2, 0); err != nil {
+		return arrowField{}, err
+	}
+	if f.dict, err = t.has(4); err != nil {
+		return arrowField{}, err
+	}
+	typ, err := t.table(3)
+	if err != nil {
+		return arrowField{}, err
+	}
+	kids, err := t.vector(5)
+	if err != nil {
+		return arrowField{}, err
+	}
+	for i := range kids.len() {
+		kid, err := kids.table(i)
+		if err != nil {
+			return arrowField{}, err
+		}
+		c, err := parseArrowField(kid, depth+1, budget)
+		if err != nil {
+			return arrowField{}, err
+		}
+		f.children = append(f.children, c)
+	}
+	if f.dict {
+		// The column holds integer indices into a dictionary batch.
+		f.buffers = 2
+		return f, nil
+	}
+
+	switch f.typ {
+	case arrowNull:
+		f.buffers = 0
+	case arrowInt, arrowBool, arrowDecimal, arrowDate, arrowTime, arrowTimestamp,
+		arrowInterval, arrowFixedSizeBinary, arrowDuration:
+		f.buffers = 2
+	case arrowFloatingPoint:
+		f.buffers = 2
+		precision, err := typ.i16(0, 0)
+		if err != nil {
+			return arrowField{}, err
+		}
+		f.float = int(precision)
+	case arrowBinary, arrowUtf8, arrowLargeBinary, arrowLargeUtf8:
+		f.buffers = 3
+	case arrowList, arrowLargeList, arrowMap:
+		f.buffers = 2
+	case arrowListView, arrowLargeListView:
+		f.buffers = 3
+	case arrowStruct:
+		f.buffers = 1
+	case arrowFixedSizeList:
+		f.buffers = 1
+		size, err := typ.i32(0, 0)
+		if err != nil {
+			return arrowField{}, err
+		}
+		f.listSize = int(size)
+	case arrowUnion:
+		f.buffers = 1 // type ids
+		mode, err := typ.i16(0, 0)
+		if err != nil {
+			return arrowField{}, err
+		}
+		if mode == 1 {
+			f.buffers = 2 // dense: type ids and offsets
+		}
+	case arrowRunEndEncoded:
+		f.buffers = 0
+	default:
+		return arrowField{}, fmt.Errorf("%w: column %q has unsupported type %d", ErrBadArrow, f.name, f.typ)
+	}
+	for _, c := range f.children {
+		f.buffers += c.buffers
+		f.nodes += c.nodes
+	}
+	return f, nil
+}
+
+// arrowNode is a FieldNode: a column's length and null count.
+type arrowNode struct {
+	length, nulls int64
+}
+
+func (ar *ArrowReader) decodeBatch(rb fbTable, body []byte) ([]store.Vector, error) {
+	compressed, err := rb.has(3)
+	if err != nil {
+		return nil, err
+	}
+	if compressed {
+		return nil, fmt.Errorf("%w: compressed record batch", ErrBadArrow)
+	}
+	length, err := rb.i64(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	nodesVec, err := rb.vector(1)
+	if err != nil {
+		return nil, err
+	}
+	bufsVec, err := rb.vector(2)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find where the ID and vector columns' nodes and buffers start.
+	var nodeAt, bufAt [2]int
+	node, buf := 0, 0
+	for i, f := range ar.fields {
+		switch i {
+		case ar.idCol:
+			nodeAt[0], bufAt[0] = node, buf
+		case ar.vecCol:
+			nodeAt[1], bufAt[1] = node, buf
+		}
+		node += f.nodes
+		buf += f.buffers
+	}
+	if nodesVec.len() != node || bufsVec.len() != buf {
+		return nil, fmt.Errorf("%w: record batch has %d nodes and %d buffers, schema needs %d and %d",
+			ErrBadArrow, nodesVec.len(), bufsVec.len(), node, buf)
+	}
+	nodeOf := func(i int) (arrowNode, error) {
+		s, err := nodesVec.structAt(i, 16)
+		if err != nil {
+			return arrowNode{}, err
+		}
+		return arrowNode{length: s.i64At(0), nulls: s.i64At(8)}, nil
+	}
+	bufOf := func(i int) ([]byte, error) {
+		s, err := bufsVec.structAt(i, 16)
+		if err != nil {
+			return nil, err
+		}
+		off, n := s.i64At(0), s.i64At(8)
+		if off < 0 || n < 0 || off > int64(len(body)) || n > int64(len(body))-off {
+			return nil, fmt.Errorf("%w: buffer %d out of bounds", ErrBadArrow, i)
+		}
+		return body[off : off+n], nil
+	}
+
+	if length < 0 || length > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: batch length %d", ErrBadArrow, length)
+	}
+	rows := int(length)
+	var nodes [3]arrowNode
+	for i, at := range []int{nodeAt[0], nodeAt[1], nodeAt[1] + 1} {
+		if nodes[i], err = nodeOf(at); err != nil {
+			return nil, err
+		}
+	}
+	idNode, vecNode, elemNode := nodes[0], nodes[1], nodes[2]
+	if idNode.length != length || vecNode.length != length || elemNode.length != length*int64(ar.dim) {
+		return nil, fmt.Errorf("%w: column lengths do not match the batch", ErrBadArrow)
+	}
+	var bufs [6][]byte
+	for i, at := range []int{bufAt[0], bufAt[0] + 1, bufAt[0] + 2, bufAt[1], bufAt[1] + 1, bufAt[1] + 2} {
+		b, err := bufOf(at)
+		if err != nil {
+			return nil, err
+		}
+		bufs[i] = b
+	}
+	idValid, idOffsets, idData := bufs[0], bufs[1], bufs[2]
+	vecValid, elemValid, elemData := bufs[3], bufs[4], bufs[5]
+
+	ids, err := ar.decodeIDs(rows, idOffsets, idData)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeArrowFloats(ar.fields[ar.vecCol].children[0].float, rows*ar.dim, elemData)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := func(bitmap []byte, nulls int64, i int) bool {
+		return nulls == 0 || len(bitmap) == 0 || (i/8 < len(bitmap) && bitmap[i/8]&(1<<(i%8)) != 0)
+	}
+	vecs := make([]store.Vector, 0, rows)
+rowLoop:
+	for i := range rows {
+		if !valid(idValid, idNode.nulls, i) || !valid(vecValid, vecNode.nulls, i) {
+			ar.skipped++
+			continue
+		}
+		if elemNode.nulls > 0 {
+			for j := i * ar.dim; j < (i+1)*ar.dim; j++ {
+				if !valid(elemValid, elemNode.nulls, j) {
+					ar.skipped++
+					continue rowLoop
+				}
+			}
+		}
+		vecs = append(vecs, store.Vector{ID: ids[i], Data: data[i*ar.dim : (i+1)*ar.dim : (i+1)*ar.dim]})
+	}
+	return vecs, nil
+}
+
+// decodeIDs slices every ID out of one string holding the column's data.
+func (ar *ArrowReader) decodeIDs(rows int, offsets, data []byte) ([]string, error) {
+	width := 4
+	if ar.fields[ar.idCol].typ == arrowLargeUtf8 {
+		width = 8
+	}
+	if rows == 0 {
+		return nil, nil
+	}
+	if len(offsets) < (rows+1)*width {
+		return nil, fmt.Errorf("%w: id offsets buffer too short", ErrBadArrow)
+	}
+	offset := func(i int) int64 {
+		if width == 4 {
+			return int64(int32(binary.LittleEndian.Uint32(offsets[i*4:])))
+		}
+		return int64(binary.LittleEndian.Uint64(offsets[i*8:]))
+	}
+	first, last := offset(0), offset(rows)
+	if first < 0 || last < first || last > int64(len(data)) {
+		return nil, fmt.Errorf("%w: id offsets out of bounds", ErrBadArrow)
+	}
+	all := string(data[first:last])
+	ids := make([]string, rows)
+	for i := range ids {
+		start, end := offset(i), offset(i+1)
+		if start < first || end < start || end > last {
+			return nil, fmt.Errorf("%w: id offsets out of bounds", ErrBadArrow)
+		}
+		ids[i] = all[start-first : end-first]
+	}
+	return ids, nil
+}
+
+// decodeArrowFloats decodes n little-endian floats of the given Arrow
+// precision into float32.
+func decodeArrowFloats(precision, n int, b []byte) ([]float32, error) {
+	size := [...]int{2, 4, 8}
+	if precision < 0 || precision >= len(size) {
+		return nil, fmt.Errorf("%w: float precision %d", ErrBadArrow, precision)
+	}
+	if len(b) < n*size[precision] {
+		return nil, fmt.Errorf("%w: vector data buffer too short", ErrBadArrow)
+	}
+	out := make([]float32, n)
+	switch precision {
+	case 0:
+		for i := range out {
+			out[i] = float16(binary.LittleEndian.Uint16(b[i*2:]))
+		}
+	case 1:
+		for i := range out {
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+	case 2:
+		for i := range out {
+			out[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(b[i*8:])))
+		}
+	}
+	return out, nil
+}
+
+// ImportArrow loads every vector in the Arrow IPC stream r into s, batch by
+// batch, and returns how many it inserted. Rows with nulls are skipped.
+func ImportArrow(s *store.VectorStore, r io.Reader, idColumn, vectorColumn string) (int, error) {
+	ar, err := NewArrowReader(r, idColumn, vectorColumn)
+	if err != nil {
+		return 0, err
+	}
+	if ar.Dimension() != s.Dimension() {
+		return 0, fmt.Errorf("vecio: arrow vectors have dimension %d: %w", ar.Dimension(), store.ErrDimensionMismatch)
+	}
+	n := 0
+	for {
+		vecs, err := ar.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := s.InsertBatch(vecs); err != nil {
+			return n, err
+		}
+		n += len(vecs)
+	}
+}
+
+// Flatbuffers: a buffer starts with a uint32 offset to the root table. A
+// table starts with an int32 pointing back to its vtable, which lists a
+// uint16 offset within the table for each field, 0 meaning absent. Offset
+// fields (tables, vectors, strings) hold a uint32 relative to their own
+// position. Vectors and strings start with a uint32 length. Every accessor
+// checks its reads against the buffer and returns errFBCorrupt for an
+// offset or length that leaves it.
+
+// errFBCorrupt is returned for metadata whose offsets or lengths point
+// outside the message.
+var errFBCorrupt = fmt.Errorf("%w: corrupt metadata", ErrBadArrow)
+
+// fbMinField is the fewest metadata bytes a schema field takes: its table's
+// vtable offset and its slot in the parent's vector of fields.
+const fbMinField = 8
+
+type fbTable struct {
+	buf []byte
+	pos int
+}
+
+type fbVector struct {
+	buf []byte
+	pos int // first element
+	n   int
+}
+
+type fbStruct struct {
+	buf []byte
+	pos int
+}
+
+// fbInRange reports whether the n bytes at p lie within buf.
+func fbInRange(buf []byte, p, n int) bool {
+	return p >= 0 && n >= 0 && p <= len(buf)-n
+}
+
+func fbRoot(buf []byte) (fbTable, error) {
+	if !fbInRange(buf, 0, 4) {
+		return fbTable{}, errFBCorrupt
+	}
+	return fbTable{buf: buf, pos: int(binary.LittleEndian.Uint32(buf))}, nil
+}
+
+// field returns the absolute position of field id, checking that size
+// bytes are there, or 0 if the field is absent.
+func (t fbTable) field(id, size int) (int, error) {
+	if !fbInRange(t.buf, t.pos, 4) {
+		return 0, errFBCorrupt
+	}
+	vt := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if !fbInRange(t.buf, vt, 2) {
+		return 0, errFBCorrupt
+	}
+	vtSize := int(binary.LittleEndian.Uint16(t.buf[vt:]))
+	at := 4 + 2*id
+	if at+2 > vtSize {
+		return 0, nil
+	}
+	if !fbInRange(t.buf, vt+at, 2) {
+		return 0, errFBCorrupt
+	}
+	off := int(binary.LittleEndian.Uint16(t.buf[vt+at:]))
+	if off == 0 {
+		return 0, nil
+	}
+	if !fbInRange(t.buf, t.pos+off, size) {
+		return 0, errFBCorrupt
+	}
+	return t.pos + off, nil
+}
+
+func (t fbTable) has(id int) (bool, error) {
+	p, err := t.field(id, 0)
+	return p != 0, err
+}
+
+func (t fbTable) u8(id int, def byte) (byte, error) {
+	p, err := t.field(id, 1)
+	if p == 0 {
+		return def, err
+	}
+	return t.buf[p], nil
+}
+
+func (t fbTable) i16(id int, def int16) (int16, error) {
+	p, err := t.field(id, 2)
+	if p == 0 {
+		return def, err
+	}
+	return int16(binary.LittleEndian.Uint16(t.buf[p:])), nil
+}
+
+func (t fbTable) i32(id int, def int32) (int32, error) {
+	p, err := t.field(id, 4)
+	if p == 0 {
+		return def, err
+	}
+	return int32(binary.LittleEndian.Uint32(t.buf[p:])), nil
+}
+
+func (t fbTable) i64(id int, def int64) (int64, error) {
+	p, err := t.field(id, 8)
+	if p == 0 {
+		return def, err
+	}
+	return int64(binary.LittleEndian.Uint64(t.buf[p:])), nil
+}
+
+// deref follows the offset stored at p, which the caller has checked.
+func (t fbTable) deref(p int) int {
+	return p + int(binary.LittleEndian.Uint32(t.buf[p:]))
+}
+
+// table returns the child table in field id. An absent table reads as
+// all-default fields. The child's own reads check its position.
+func (t fbTable) table(id int) (fbTable, error) {
+	p, err := t.field(id, 4)
+	if p == 0 {
+		return fbEmpty, err
+	}
+	return fbTable{buf: t.buf, pos: t.deref(p)}, nil
+}
+
+// vector returns the vector in field id, checking that its length fits the
+// buffer at one byte per element; element accessors check the rest.
+func (t fbTable) vector(id int) (fbVector, error) {
+	p, err := t.field(id, 4)
+	if p == 0 {
+		return fbVector{}, err
+	}
+	p = t.deref(p)
+	if !fbInRange(t.buf, p, 4) {
+		return fbVector{}, errFBCorrupt
+	}
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	if !fbInRange(t.buf, p+4, n) {
+		return fbVector{}, errFBCorrupt
+	}
+	return fbVector{buf: t.buf, pos: p + 4, n: n}, nil
+}
+
+func (t fbTable) str(id int) (string, error) {
+	v, err := t.vector(id)
+	if err != nil {
+		return "", err
+	}
+	return string(v.buf[v.pos : v.pos+v.n]), nil
+}
+
+func (v fbVector) len() int {
+	return v.n
+}
+
+func (v fbVector) table(i int) (fbTable, error) {
+	p := v.pos + 4*i
+	if i < 0 || i >= v.n || !fbInRange(v.buf, p, 4) {
+		return fbTable{}, errFBCorrupt
+	}
+	return fbTable{buf: v.buf, pos: p + int(binary.LittleEndian.Uint32(v.buf[p:]))}, nil
+}
+
+func (v fbVector) structAt(i, size int) (fbStruct, error) {
+	p := v.pos + size*i
+	if i < 0 || i >= v.n || !fbInRange(v.buf, p, size) {
+		return fbStruct{}, errFBCorrupt
+	}
+	return fbStruct{buf: v.buf, pos: p}, nil
+}
+
+// i64At reads the int64 at off; structAt has checked the struct's bytes.
+func (s fbStruct) i64At(off int) int64 {
+	return int64(binary.LittleEndian.Uint64(s.buf[s.pos+off:]))
+}
+
+// fbEmpty is a table with an empty vtable: every field is absent.
+var fbEmpty = fbTable{buf: []byte{4, 0, 4, 0, 4, 0, 0, 0}, pos: 4}
@@ -0,0 +1,348 @@
+package vecio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"vexor/pkg/store"
+)
+
+// fbBuilder writes flatbuffers front to back: each table's vtable comes
+// first and the objects it references follow it.
+type fbBuilder struct{ buf []byte }
+
+// fbVal is a table field: a scalar stored inline or an object written
+// after the table.
+type fbVal struct {
+	scalar []byte
+	ref    func(b *fbBuilder) int
+}
+
+func fbU8(v byte) *fbVal   { return &fbVal{scalar: []byte{v}} }
+func fbI16(v int16) *fbVal { return &fbVal{scalar: binary.LittleEndian.AppendUint16(nil, uint16(v))} }
+func fbI32(v int32) *fbVal { return &fbVal{scalar: binary.LittleEndian.AppendUint32(nil, uint32(v))} }
+func fbI64(v int64) *fbVal { return &fbVal{scalar: binary.LittleEndian.AppendUint64(nil, uint64(v))} }
+
+func fbTableRef(fields ...*fbVal) *fbVal {
+	return &fbVal{ref: func(b *fbBuilder) int { return b.table(fields...) }}
+}
+
+func fbStringRef(s string) *fbVal {
+	return &fbVal{ref: func(b *fbBuilder) int {
+		b.align(4, 0)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+		b.buf = append(append(b.buf, s...), 0)
+		return pos
+	}}
+}
+
+func fbTablesRef(tables ...[]*fbVal) *fbVal {
+	return &fbVal{ref: func(b *fbBuilder) int {
+		b.align(4, 0)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(tables)))
+		b.buf = append(b.buf, make([]byte, 4*len(tables))...)
+		for i, fields := range tables {
+			at := pos + 4 + 4*i
+			b.patch(at, b.table(fields...))
+		}
+		return pos
+	}}
+}
+
+// fbStructsRef is a vector of 16-byte structs of two int64s.
+func fbStructsRef(pairs ...int64) *fbVal {
+	return &fbVal{ref: func(b *fbBuilder) int {
+		b.align(8, 4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(pairs)/2))
+		for _, v := range pairs {
+			b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(v))
+		}
+		return pos
+	}}
+}
+
+// align pads until len(buf) % n == rem.
+func (b *fbBuilder) align(n, rem int) {
+	for len(b.buf)%n != rem {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// patch stores at position at the offset from at to target.
+func (b *fbBuilder) patch(at, target int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(target-at))
+}
+
+func (b *fbBuilder) table(fields ...*fbVal) int {
+	offs := make([]int, len(fields))
+	size := 4
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+		n := 4
+		if f.scalar != nil {
+			n = len(f.scalar)
+		}
+		for size%n != 0 {
+			size++
+		}
+		offs[i] = size
+		size += n
+	}
+
+	b.align(2, 0)
+	vt := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(fields)))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	for _, off := range offs {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(off))
+	}
+	b.align(8, 0)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vt)))
+	for i, f := range fields {
+		if f != nil && f.scalar != nil {
+			copy(b.buf[pos+offs[i]:], f.scalar)
+		}
+	}
+	for i, f := range fields {
+		if f != nil && f.ref != nil {
+			b.patch(pos+offs[i], f.ref(b))
+		}
+	}
+	return pos
+}
+
+// arrowMessage frames one IPC message with the given header and body.
+func arrowMessage(w *bytes.Buffer, headerType byte, header *fbVal, body []byte) {
+	for len(body)%8 != 0 {
+		body = append(body, 0)
+	}
+	b := &fbBuilder{buf: make([]byte, 4)}
+	b.patch(0, b.table(fbI16(4), fbU8(headerType), header, fbI64(int64(len(body)))))
+	b.align(8, 0)
+	w.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	binary.Write(w, binary.LittleEndian, uint32(len(b.buf)))
+	w.Write(b.buf)
+	w.Write(body)
+}
+
+// arrowField builds a Field table: name, nullable, type_type, type,
+// dictionary, children.
+func arrowFieldTable(name string, typ byte, typeTable *fbVal, dict bool, children ...[]*fbVal) []*fbVal {
+	var dictRef, kids *fbVal
+	if dict {
+		dictRef = fbTableRef(fbI64(0), fbTableRef(fbI32(32), fbU8(1)))
+	}
+	if children != nil {
+		kids = fbTablesRef(children...)
+	}
+	return []*fbVal{fbStringRef(name), fbU8(1), fbU8(typ), typeTable, dictRef, kids}
+}
+
+// arrowBody lays out buffers 8-byte aligned and returns the body and the
+// (offset, length) pairs for the Buffer vector.
+func arrowBody(bufs ...[]byte) ([]byte, []int64) {
+	var body []byte
+	var spans []int64
+	for _, b := range bufs {
+		spans = append(spans, int64(len(body)), int64(len(b)))
+		body = append(body, b...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+	return body, spans
+}
+
+func le32(vals ...int32) []byte {
+	var b []byte
+	for _, v := range vals {
+		b = binary.LittleEndian.AppendUint32(b, uint32(v))
+	}
+	return b
+}
+
+func f32s(vals ...float32) []byte {
+	var b []byte
+	for _, v := range vals {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	}
+	return b
+}
+
+// arrowStream builds a stream with the columns label: int32, id: utf8,
+// embedding: fixed_size_list<float32>[3] and category: dictionary<utf8>,
+// a dictionary batch and two record batches. The second row of the first
+// batch has a null id.
+func arrowStream() []byte {
+	var w bytes.Buffer
+	fields := fbTablesRef(
+		arrowFieldTable("label", arrowInt, fbTableRef(fbI32(32), fbU8(1)), false),
+		arrowFieldTable("id", arrowUtf8, fbTableRef(), false),
+		arrowFieldTable("embedding", arrowFixedSizeList, fbTableRef(fbI32(3)), false,
+			arrowFieldTable("item", arrowFloatingPoint, fbTableRef(fbI16(1)), false)),
+		arrowFieldTable("category", arrowUtf8, fbTableRef(), true),
+	)
+	arrowMessage(&w, arrowSchema, fbTableRef(fbI16(0), fields), nil)
+
+	// A dictionary batch for category, which the reader skips.
+	dictBody, dictSpans := arrowBody(nil, le32(0, 1), []byte("x"))
+	arrowMessage(&w, arrowDictionaryBatch, fbTableRef(fbI64(0),
+		fbTableRef(fbI64(1), fbStructsRef(1, 0), fbStructsRef(dictSpans...))), dictBody)
+
+	batch := func(rows int64, idNulls int64, idValid []byte, offsets []int32, ids string, vecs []float32) {
+		body, spans := arrowBody(
+			nil, le32(make([]int32, rows)...), // label
+			idValid, le32(offsets...), []byte(ids), // id
+			nil, nil, f32s(vecs...), // embedding
+			nil, le32(make([]int32, rows)...), // category indices
+		)
+		nodes := fbStructsRef(rows, 0, rows, idNulls, rows, 0, rows*3, 0, rows, 0)
+		arrowMessage(&w, arrowRecordBatch, fbTableRef(fbI64(rows), nodes, fbStructsRef(spans...)), body)
+	}
+	batch(3, 1, []byte{0b101}, []int32{0, 1, 1, 3}, "abb", []float32{1, 2, 3, 9, 9, 9, 4, 5, 6})
+	batch(2, 0, nil, []int32{0, 3, 6}, "cccddd", []float32{7, 8, 9, 10, 11, 12})
+	w.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+	return w.Bytes()
+}
+
+func TestArrowReader(t *testing.T) {
+	stream := arrowStream()
+	ar, err := NewArrowReader(bytes.NewReader(stream), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ar.Dimension() != 3 {
+		t.Fatalf("dimension %d", ar.Dimension())
+	}
+	var got []store.Vector
+	for {
+		vecs, err := ar.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, vecs...)
+	}
+	want := []store.Vector{
+		{ID: "a", Data: []float32{1, 2, 3}},
+		{ID: "bb", Data: []float32{4, 5, 6}},
+		{ID: "ccc", Data: []float32{7, 8, 9}},
+		{ID: "ddd", Data: []float32{10, 11, 12}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || !bytes.Equal(f32s(got[i].Data...), f32s(want[i].Data...)) {
+			t.Errorf("row %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if ar.Skipped() != 1 {
+		t.Errorf("skipped %d rows, want 1", ar.Skipped())
+	}
+
+	s := store.NewVectorStore(3)
+	if n, err := ImportArrow(s, bytes.NewReader(stream), "id", "embedding"); err != nil || n != 4 {
+		t.Fatalf("import: %d, %v", n, err)
+	}
+	if v, err := s.Get("ddd"); err != nil || v.Data[2] != 12 {
+		t.Errorf("imported vector: %+v, %v", v, err)
+	}
+	if _, err := ImportArrow(store.NewVectorStore(4), bytes.NewReader(stream), "", ""); !errors.Is(err, store.ErrDimensionMismatch) {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestArrowReaderErrors(t *testing.T) {
+	stream := arrowStream()
+	if _, err := NewArrowReader(bytes.NewReader(stream), "label", ""); err == nil {
+		t.Error("expected error for a non-utf8 id column")
+	}
+	if _, err := NewArrowReader(bytes.NewReader(stream), "", "missing"); err == nil {
+		t.Error("expected error for a missing vector column")
+	}
+
+	ar, err := NewArrowReader(bytes.NewReader(stream[:len(stream)-20]), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar.Next()
+	if _, err := ar.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected ErrUnexpectedEOF for a truncated stream, got %v", err)
+	}
+
+	// Corrupt every byte of the schema metadata in turn; the reader must
+	// fail cleanly or succeed, never panic.
+	for i := 8; i < 200 && i < len(stream); i++ {
+		bad := bytes.Clone(stream)
+		bad[i] ^= 0xFF
+		if ar, err := NewArrowReader(bytes.NewReader(bad), "", ""); err == nil {
+			for {
+				if _, err := ar.Next(); err != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestArrowSchemaFanOut(t *testing.T) {
+	// A chain of struct fields, each with a second child that is a leaf.
+	var level func(d int) []*fbVal
+	level = func(d int) []*fbVal {
+		leaf := arrowFieldTable("leaf", arrowNull, fbTableRef(), false)
+		if d == 0 {
+			return leaf
+		}
+		return arrowFieldTable("s", arrowStruct, fbTableRef(), false, level(d-1), leaf)
+	}
+	var w bytes.Buffer
+	arrowMessage(&w, arrowSchema, fbTableRef(fbI16(0), fbTablesRef(level(60))), nil)
+	meta := w.Bytes()[8:]
+
+	// Point every second child at the first, so each level doubles the
+	// fields a naive walk visits while the metadata stays a few KB.
+	msg, _ := fbRoot(meta)
+	schema, _ := msg.table(2)
+	fields, _ := schema.vector(1)
+	f, _ := fields.table(0)
+	for range 60 {
+		kids, err := f.vector(5)
+		if err != nil || kids.len() != 2 {
+			t.Fatalf("unexpected children: %d, %v", kids.len(), err)
+		}
+		first, _ := kids.table(0)
+		at := kids.pos + 4
+		binary.LittleEndian.PutUint32(meta[at:], uint32(first.pos-at))
+		f = first
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewArrowReader(bytes.NewReader(w.Bytes()), "", "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrBadArrow) || !strings.Contains(err.Error(), "too many fields") {
+			t.Errorf("expected too many fields, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("schema with shared children was not rejected")
+	}
+}
@@ -0,0 +1,440 @@
+package vecio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NPY files, as written by numpy.save, start with the magic "\x93NUMPY", a
+// two-byte version and a little-endian header length (uint16 in version 1,
+// uint32 in 2 and 3), then an ASCII Python dict literal such as
+//
+//	{'descr': '<f4', 'fortran_order': False, 'shape': (1000, 128), }
+//
+// padded with spaces and a newline so the data starts 64-byte aligned. The
+// raw array follows in C or Fortran order.
+
+// ErrBadNPY is returned for a malformed NPY header or an unsupported dtype
+// or shape.
+var ErrBadNPY = errors.New("vecio: malformed or unsupported npy file")
+
+var npyMagic = []byte("\x93NUMPY")
+
+// maxNPYHeader bounds the header length so a corrupt file cannot trigger a
+// huge allocation.
+const maxNPYHeader = 1 << 20
+
+// NPYHeader describes the array stored in an NPY file.
+type NPYHeader struct {
+	// Descr is the numpy dtype string, e.g. "<f4", "<f2" or "<U12".
+	Descr        string
+	FortranOrder bool
+	Shape        []int
+}
+
+// ReadNPYHeader reads the magic, version and header dict from r, leaving r
+// positioned at the start of the array data.
+func ReadNPYHeader(r io.Reader) (NPYHeader, error) {
+	var pre [8]byte
+	if _, err := io.ReadFull(r, pre[:]); err != nil {
+		return NPYHeader{}, fmt.Errorf("vecio: npy preamble: %w", err)
+	}
+	if !bytes.Equal(pre[:6], npyMagic) {
+		return NPYHeader{}, fmt.Errorf("%w: bad magic", ErrBadNPY)
+	}
+	var n int
+	switch pre[6] {
+	case 1:
+		var l [2]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return NPYHeader{}, fmt.Errorf("vecio: npy header length: %w", err)
+		}
+		n = int(binary.LittleEndian.Uint16(l[:]))
+	case 2, 3:
+		var l [4]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return NPYHeader{}, fmt.Errorf("vecio: npy header length: %w", err)
+		}
+		n = int(binary.LittleEndian.Uint32(l[:]))
+	default:
+		return NPYHeader{}, fmt.Errorf("%w: version %d.%d", ErrBadNPY, pre[6], pre[7])
+	}
+	if n > maxNPYHeader {
+		return NPYHeader{}, fmt.Errorf("%w: header length %d", ErrBadNPY, n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return NPYHeader{}, fmt.Errorf("vecio: npy header: %w", err)
+	}
+	return parseNPYHeader(string(buf))
+}
+
+// parseNPYHeader parses the header dict. It accepts exactly the literal
+// forms numpy writes: quoted strings, True/False and tuples of integers.
+func parseNPYHeader(s string) (NPYHeader, error) {
+	var (
+		h    NPYHeader
+		seen = map[string]bool{}
+	)
+	p := &pyLexer{s: strings.TrimSpace(s)}
+	if !p.consume('{') {
+		return h, fmt.Errorf("%w: header is not a dict", ErrBadNPY)
+	}
+	for !p.consume('}') {
+		key, ok := p.str()
+		if !ok || !p.consume(':') {
+			return h, fmt.Errorf("%w: bad header key", ErrBadNPY)
+		}
+		switch key {
+		case "descr":
+			h.Descr, ok = p.str()
+		case "fortran_order":
+			h.FortranOrder, ok = p.boolean()
+		case "shape":
+			h.Shape, ok = p.tuple()
+		default:
+			return h, fmt.Errorf("%w: unknown header key %q", ErrBadNPY, key)
+		}
+		if !ok {
+			return h, fmt.Errorf("%w: bad value for %q", ErrBadNPY, key)
+		}
+		seen[key] = true
+		if !p.consume(',') && p.peek() != '}' {
+			return h, fmt.Errorf("%w: expected ',' in header", ErrBadNPY)
+		}
+	}
+	if !seen["descr"] || !seen["fortran_order"] || !seen["shape"] {
+		return h, fmt.Errorf("%w: header missing keys", ErrBadNPY)
+	}
+	return h, nil
+}
+
+type pyLexer struct {
+	s   string
+	pos int
+}
+
+func (p *pyLexer) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\n' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *pyLexer) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *pyLexer) consume(c byte) bool {
+	if p.peek() != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *pyLexer) str() (string, bool) {
+	q := p.peek()
+	if q != '\'' && q != '"' {
+		return "", false
+	}
+	end := strings.IndexByte(p.s[p.pos+1:], q)
+	if end < 0 {
+		return "", false
+	}
+	v := p.s[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return v, true
+}
+
+func (p *pyLexer) boolean() (bool, bool) {
+	p.skipSpace()
+	for _, lit := range []string{"True", "False"} {
+		if strings.HasPrefix(p.s[p.pos:], lit) {
+			p.pos += len(lit)
+			return lit == "True", true
+		}
+	}
+	return false, false
+}
+
+func (p *pyLexer) tuple() ([]int, bool) {
+	if !p.consume('(') {
+		return nil, false
+	}
+	dims := []int{}
+	for !p.consume(')') {
+		p.skipSpace()
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		d, err := strconv.Atoi(p.s[start:p.pos])
+		if err != nil || d < 0 {
+			return nil, false
+		}
+		dims = append(dims, d)
+		if !p.consume(',') && p.peek() != ')' {
+			return nil, false
+		}
+	}
+	return dims, true
+}
+
+// ReadNPY reads a 1-D or 2-D float array from r. Float32, float16 and
+// float64 data in either byte order are accepted and converted to float32;
+// Fortran-ordered arrays are transposed. A 1-D array is one vector. All
+// vectors share one backing array.
+func ReadNPY(r io.Reader) ([][]float32, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	h, err := ReadNPYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	return readNPYFloats(br, h)
+}
+
+func readNPYFloats(r io.Reader, h NPYHeader) ([][]float32, error) {
+	rows, cols, err := matrixShape(h.Shape)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.Descr) != 3 || h.Descr[1] != 'f' {
+		return nil, fmt.Errorf("%w: dtype %q is not a float", ErrBadNPY, h.Descr)
+	}
+	var order binary.ByteOrder
+	switch h.Descr[0] {
+	case '<', '=':
+		order = binary.LittleEndian
+	case '>':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: dtype %q", ErrBadNPY, h.Descr)
+	}
+	var (
+		size   int
+		decode func(b []byte) float32
+	)
+	switch h.Descr[2] {
+	case '2':
+		size, decode = 2, func(b []byte) float32 { return float16(order.Uint16(b)) }
+	case '4':
+		size, decode = 4, func(b []byte) float32 { return math.Float32frombits(order.Uint32(b)) }
+	case '8':
+		size, decode = 8, func(b []byte) float32 { return float32(math.Float64frombits(order.Uint64(b))) }
+	default:
+		return nil, fmt.Errorf("%w: dtype %q", ErrBadNPY, h.Descr)
+	}
+
+	// The shape comes from the header, so the data grows as it is read
+	// rather than being allocated up front: a short file cannot claim
+	// gigabytes.
+	total := rows * cols
+	buf := make([]byte, 1<<16-(1<<16)%size)
+	data := make([]float32, 0, min(total, len(buf)/size))
+	for len(data) < total {
+		n := min(len(buf), (total-len(data))*size)
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
+			return nil, fmt.Errorf("vecio: npy data: %w", io.ErrUnexpectedEOF)
+		}
+		for off := 0; off < n; off += size {
+			data = append(data, decode(buf[off:]))
+		}
+	}
+	if h.FortranOrder && rows > 1 && cols > 1 {
+		t := make([]float32, len(data))
+		for c := range cols {
+			for r := range rows {
+				t[r*cols+c] = data[c*rows+r]
+			}
+		}
+		data = t
+	}
+
+	out := make([][]float32, rows)
+	for i := range out {
+		out[i] = data[i*cols : (i+1)*cols : (i+1)*cols]
+	}
+	return out, nil
+}
+
+// matrixShape interprets a 1-D shape as a single row.
+func matrixShape(shape []int) (rows, cols int, err error) {
+	switch len(shape) {
+	case 1:
+		rows, cols = 1, shape[0]
+	case 2:
+		rows, cols = shape[0], shape[1]
+	default:
+		return 0, 0, fmt.Errorf("%w: shape %v is not 1-D or 2-D", ErrBadNPY, shape)
+	}
+	if cols == 0 && rows > 0 || cols > maxDimension || rows > math.MaxInt/max(cols, 1)/8 {
+		return 0, 0, fmt.Errorf("%w: shape %v", ErrBadNPY, shape)
+	}
+	return rows, cols, nil
+}
+
+// float16 converts IEEE 754 half-precision bits to float32.
+func float16(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f: // Inf or NaN
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	case exp != 0: // normal
+		return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+	case frac == 0: // signed zero
+		return math.Float32frombits(sign)
+	default: // subnormal: value is frac * 2^-24
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	}
+}
+
+// WriteNPY writes vecs to w as a 2-D little-endian float32 array in C
+// order, in NPY format version 1.0.
+func WriteNPY(w io.Writer, vecs [][]float32) error {
+	cols := 0
+	if len(vecs) > 0 {
+		cols = len(vecs[0])
+	}
+	return writeNPYMatrix(w, vecs, cols)
+}
+
+// writeNPYMatrix is WriteNPY with the column count given, so an empty
+// matrix keeps its dimension in the shape.
+func writeNPYMatrix(w io.Writer, vecs [][]float32, cols int) error {
+	for i, v := range vecs {
+		if len(v) == 0 || len(v) != cols {
+			return fmt.Errorf("vecio: vector %d has dimension %d: %w", i, len(v), ErrBadDimension)
+		}
+	}
+	bw := bufio.NewWriterSize(w, 1<<16)
+	if err := writeNPYHeader(bw, fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%d, %d), }", len(vecs), cols)); err != nil {
+		return err
+	}
+	var b [4]byte
+	for _, v := range vecs {
+		for _, x := range v {
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(x))
+			bw.Write(b[:])
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadNPYStrings reads a 1-D array of fixed-width strings, numpy dtype
+// "<U" (UTF-32) or "|S" (bytes). Trailing NULs are trimmed, as numpy does.
+func ReadNPYStrings(r io.Reader) ([]string, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	h, err := ReadNPYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	return readNPYStrings(br, h)
+}
+
+func readNPYStrings(r io.Reader, h NPYHeader) ([]string, error) {
+	if len(h.Shape) != 1 {
+		return nil, fmt.Errorf("%w: string array shape %v is not 1-D", ErrBadNPY, h.Shape)
+	}
+	if len(h.Descr) < 3 {
+		return nil, fmt.Errorf("%w: dtype %q", ErrBadNPY, h.Descr)
+	}
+	width, err := strconv.Atoi(h.Descr[2:])
+	if err != nil || width <= 0 || width > maxDimension {
+		return nil, fmt.Errorf("%w: dtype %q", ErrBadNPY, h.Descr)
+	}
+	var decode func(b []byte) string
+	switch h.Descr[:2] {
+	case "<U":
+		width *= 4
+		decode = func(b []byte) string {
+			var sb strings.Builder
+			for off := 0; off < len(b); off += 4 {
+				c := rune(binary.LittleEndian.Uint32(b[off:]))
+				if c == 0 {
+					break
+				}
+				sb.WriteRune(c)
+			}
+			return sb.String()
+		}
+	case "|S":
+		decode = func(b []byte) string { return string(bytes.TrimRight(b, "\x00")) }
+	default:
+		return nil, fmt.Errorf("%w: dtype %q is not a string", ErrBadNPY, h.Descr)
+	}
+	n := h.Shape[0]
+	if n > math.MaxInt/width {
+		return nil, fmt.Errorf("%w: shape %v", ErrBadNPY, h.Shape)
+	}
+	out := make([]string, 0, min(n, 1<<20))
+	buf := make([]byte, width)
+	for range n {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("vecio: npy data: %w", io.ErrUnexpectedEOF)
+		}
+		out = append(out, decode(buf))
+	}
+	return out, nil
+}
+
+// WriteNPYStrings writes strs as a 1-D "<U" array sized to the longest
+// string, which is how numpy stores a list of Python strings.
+func WriteNPYStrings(w io.Writer, strs []string) error {
+	width := 1
+	for _, s := range strs {
+		width = max(width, utf8.RuneCountInString(s))
+	}
+	bw := bufio.NewWriterSize(w, 1<<16)
+	if err := writeNPYHeader(bw, fmt.Sprintf("{'descr': '<U%d', 'fortran_order': False, 'shape': (%d,), }", width, len(strs))); err != nil {
+		return err
+	}
+	buf := make([]byte, width*4)
+	for _, s := range strs {
+		clear(buf)
+		off := 0
+		for _, c := range s {
+			binary.LittleEndian.PutUint32(buf[off:], uint32(c))
+			off += 4
+		}
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
+
+// writeNPYHeader writes a version 1.0 preamble and dict, padded so the
+// data starts on a 64-byte boundary.
+func writeNPYHeader(w io.Writer, dict string) error {
+	total := len(npyMagic) + 4 + len(dict) + 1
+	pad := (64 - total%64) % 64
+	header := dict + strings.Repeat(" ", pad) + "\n"
+	if len(header) > math.MaxUint16 {
+		return fmt.Errorf("%w: header too long", ErrBadNPY)
+	}
+	var pre [10]byte
+	copy(pre[:], npyMagic)
+	pre[6], pre[7] = 1, 0
+	binary.LittleEndian.PutUint16(pre[8:], uint16(len(header)))
+	if _, err := w.Write(pre[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, header)
+	return err
+}
@@ -0,0 +1,180 @@
+package vecio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"vexor/pkg/store"
+)
+
+// npyFile builds an NPY file the way numpy.save lays it out.
+func npyFile(dict string, data []byte) []byte {
+	var buf bytes.Buffer
+	writeNPYHeader(&buf, dict)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestNPYRoundTrip(t *testing.T) {
+	vecs := [][]float32{{1, 2, 3}, {-0.5, 0, 1e20}}
+	var buf bytes.Buffer
+	if err := WriteNPY(&buf, vecs); err != nil {
+		t.Fatal(err)
+	}
+	if off := bytes.IndexByte(buf.Bytes(), '\n') + 1; off%64 != 0 {
+		t.Fatalf("data starts at %d, want 64-byte alignment", off)
+	}
+	h, err := ReadNPYHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil || h.Descr != "<f4" || h.FortranOrder || !slices.Equal(h.Shape, []int{2, 3}) {
+		t.Fatalf("header %+v, %v", h, err)
+	}
+	got, err := ReadNPY(&buf)
+	if err != nil || !reflect.DeepEqual(got, vecs) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+
+	ids := []string{"a", "héllo", ""}
+	buf.Reset()
+	if err := WriteNPYStrings(&buf, ids); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := ReadNPYStrings(&buf); err != nil || !slices.Equal(s, ids) {
+		t.Fatalf("strings: got %q, %v", s, err)
+	}
+}
+
+func TestNPYDtypes(t *testing.T) {
+	// float16 in Fortran order: columns [1, 2] and [-0.5, 65504].
+	f16 := []uint16{0x3c00, 0x4000, 0xb800, 0x7bff}
+	data := make([]byte, 0, 8)
+	for _, h := range f16 {
+		data = binary.LittleEndian.AppendUint16(data, h)
+	}
+	got, err := ReadNPY(bytes.NewReader(npyFile("{'descr': '<f2', 'fortran_order': True, 'shape': (2, 2), }", data)))
+	want := [][]float32{{1, -0.5}, {2, 65504}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("float16: got %v, %v", got, err)
+	}
+
+	// Big-endian float64, 1-D.
+	data = binary.BigEndian.AppendUint64(nil, 0x4008000000000000) // 3.0
+	got, err = ReadNPY(bytes.NewReader(npyFile("{'descr': '>f8', 'fortran_order': False, 'shape': (1,), }", data)))
+	if err != nil || !reflect.DeepEqual(got, [][]float32{{3}}) {
+		t.Fatalf("float64: got %v, %v", got, err)
+	}
+
+	// Byte strings are NUL padded.
+	got2, err := ReadNPYStrings(bytes.NewReader(npyFile("{'descr': '|S3', 'fortran_order': False, 'shape': (2,), }", []byte("abcd\x00\x00"))))
+	if err != nil || !slices.Equal(got2, []string{"abc", "d"}) {
+		t.Fatalf("|S: got %q, %v", got2, err)
+	}
+
+	if f := float16(0x0001); f != 1.0/(1<<24) {
+		t.Errorf("smallest subnormal = %v", f)
+	}
+}
+
+func TestNPYErrors(t *testing.T) {
+	for _, dict := range []string{
+		"{'descr': '<i4', 'fortran_order': False, 'shape': (1, 1), }",
+		"{'descr': '<f4', 'fortran_order': False, 'shape': (1, 1, 1), }",
+		"{'descr': '<f4', 'shape': (1, 1), }",
+		"{'descr': '<f4', 'fortran_order': Maybe, 'shape': (1, 1), }",
+		"['<f4']",
+	} {
+		if _, err := ReadNPY(bytes.NewReader(npyFile(dict, make([]byte, 4)))); !errors.Is(err, ErrBadNPY) {
+			t.Errorf("%s: expected ErrBadNPY, got %v", dict, err)
+		}
+	}
+	if _, err := ReadNPY(strings.NewReader("PK\x03\x04 not an npy")); !errors.Is(err, ErrBadNPY) {
+		t.Errorf("expected ErrBadNPY for bad magic, got %v", err)
+	}
+	short := npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 2), }", make([]byte, 12))
+	if _, err := ReadNPY(bytes.NewReader(short)); err == nil {
+		t.Error("expected error for truncated data")
+	}
+	// A header claiming 64 GiB of data must not allocate it before finding
+	// the data missing.
+	huge := npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (1073741824, 16), }", make([]byte, 64))
+	if _, err := ReadNPY(bytes.NewReader(huge)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected ErrUnexpectedEOF for a huge shape, got %v", err)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	dir := t.TempDir()
+	npyPath := filepath.Join(dir, "emb.npy")
+	idsPath := filepath.Join(dir, "emb.ids")
+
+	vecs := make([][]float32, 5000) // more than one insert batch
+	for i := range vecs {
+		vecs[i] = []float32{float32(i), float32(-i)}
+	}
+	var buf bytes.Buffer
+	WriteNPY(&buf, vecs)
+	os.WriteFile(npyPath, buf.Bytes(), 0o644)
+
+	s := store.NewVectorStore(2)
+	if n, err := ImportNPY(s, npyPath, ""); err != nil || n != 5000 {
+		t.Fatalf("import: %d, %v", n, err)
+	}
+	if v, err := s.Get("4321"); err != nil || v.Data[1] != -4321 {
+		t.Fatalf("row-number id: %v, %v", v, err)
+	}
+
+	os.WriteFile(idsPath, []byte("x\r\ny\n"), 0o644)
+	if _, err := ImportNPY(s, npyPath, idsPath); err == nil {
+		t.Fatal("expected error for id count mismatch")
+	}
+
+	// Export and re-import into a fresh store, through both formats.
+	if n, err := ExportNPY(s, npyPath, idsPath); err != nil || n != 5000 {
+		t.Fatalf("export: %d, %v", n, err)
+	}
+	npzPath := filepath.Join(dir, "emb.npz")
+	if _, err := ExportNPZ(s, npzPath); err != nil {
+		t.Fatal(err)
+	}
+	for name, load := range map[string]func(*store.VectorStore) (int, error){
+		"npy": func(d *store.VectorStore) (int, error) { return ImportNPY(d, npyPath, idsPath) },
+		"npz": func(d *store.VectorStore) (int, error) { return ImportNPZ(d, npzPath, "vectors", "ids") },
+	} {
+		d := store.NewVectorStore(2)
+		if n, err := load(d); err != nil || n != 5000 || d.Count() != 5000 {
+			t.Fatalf("%s re-import: %d, %v", name, n, err)
+		}
+		if v, err := d.Get("17"); err != nil || v.Data[0] != 17 {
+			t.Fatalf("%s re-import lost data: %v, %v", name, v, err)
+		}
+	}
+
+	z, err := OpenNPZ(npzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+	if names := z.Names(); !slices.Equal(names, []string{"ids", "vectors"}) {
+		t.Errorf("npz names %v", names)
+	}
+	if _, err := z.Vectors("missing"); err == nil {
+		t.Error("expected error for missing array")
+	}
+
+	// An empty store still records its dimension.
+	if _, err := ExportNPY(store.NewVectorStore(7), npyPath, idsPath); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.Open(npyPath)
+	defer f.Close()
+	if h, err := ReadNPYHeader(f); err != nil || !slices.Equal(h.Shape, []int{0, 7}) {
+		t.Errorf("empty export header %+v, %v", h, err)
+	}
+}
@@ -0,0 +1,116 @@
+package vecio
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// An NPZ file, as written by numpy.savez, is a zip archive holding one
+// "<name>.npy" member per array. numpy.savez_compressed deflates the
+// members; archive/zip reads both.
+
+// NPZ is an open .npz archive.
+type NPZ struct {
+	zr *zip.ReadCloser
+}
+
+// OpenNPZ opens the .npz archive at path.
+func OpenNPZ(path string) (*NPZ, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return &NPZ{zr: zr}, nil
+}
+
+// Close closes the archive.
+func (z *NPZ) Close() error {
+	return z.zr.Close()
+}
+
+// Names returns the names of the arrays in the archive, sorted.
+func (z *NPZ) Names() []string {
+	var names []string
+	for _, f := range z.zr.File {
+		if name, ok := strings.CutSuffix(f.Name, ".npy"); ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Header returns the header of the named array.
+func (z *NPZ) Header(name string) (NPYHeader, error) {
+	rc, err := z.open(name)
+	if err != nil {
+		return NPYHeader{}, err
+	}
+	defer rc.Close()
+	return ReadNPYHeader(rc)
+}
+
+// Vectors reads the named float array, as ReadNPY does.
+func (z *NPZ) Vectors(name string) ([][]float32, error) {
+	rc, err := z.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ReadNPY(rc)
+}
+
+// Strings reads the named string array, as ReadNPYStrings does.
+func (z *NPZ) Strings(name string) ([]string, error) {
+	rc, err := z.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ReadNPYStrings(rc)
+}
+
+func (z *NPZ) open(name string) (io.ReadCloser, error) {
+	rc, err := z.zr.Open(name + ".npy")
+	if err != nil {
+		return nil, fmt.Errorf("vecio: npz array %q: %w", name, err)
+	}
+	return rc, nil
+}
+
+// WriteNPZ writes vecs and ids to w as an uncompressed archive with the
+// arrays "vectors" and "ids", matching numpy.savez(f, vectors=..., ids=...).
+// ids may be nil to omit the ids array.
+func WriteNPZ(w io.Writer, vecs [][]float32, ids []string) error {
+	cols := 0
+	if len(vecs) > 0 {
+		cols = len(vecs[0])
+	}
+	return writeNPZ(w, vecs, cols, ids)
+}
+
+func writeNPZ(w io.Writer, vecs [][]float32, cols int, ids []string) error {
+	zw := zip.NewWriter(w)
+	member := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: zip.Store})
+	}
+	f, err := member("vectors")
+	if err != nil {
+		return err
+	}
+	if err := writeNPYMatrix(f, vecs, cols); err != nil {
+		return err
+	}
+	if ids != nil {
+		if f, err = member("ids"); err != nil {
+			return err
+		}
+		if err := WriteNPYStrings(f, ids); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,499 @@
+package vecio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"vexor/pkg/store"
+)
+
+// Records are vectors with their IDs and payloads, one per row, in one of
+// two text formats:
+//
+//	JSON Lines: {"id": "a", "vector": [0.1, 0.2], "payload": {"k": "v"}}
+//	CSV:        id,vector,payload
+//	            a,"[0.1,0.2]","{""k"":""v""}"
+//
+// A CSV file starts with a header naming its columns. "id" and "vector"
+// are required and "payload" is optional; the vector and payload cells hold
+// JSON. Other columns are ignored.
+
+// Format is a record file format.
+type Format int
+
+const (
+	FormatJSONL Format = iota
+	FormatCSV
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSONL:
+		return "jsonl"
+	case FormatCSV:
+		return "csv"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// ParseFormat returns the format named name: "jsonl" or "csv".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "jsonl", "ndjson":
+		return FormatJSONL, nil
+	case "csv":
+		return FormatCSV, nil
+	default:
+		return 0, fmt.Errorf("vecio: unknown record format %q", name)
+	}
+}
+
+// FormatOf guesses the format of path from its extension.
+func FormatOf(path string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "" {
+		return 0, fmt.Errorf("vecio: cannot tell the record format of %q", path)
+	}
+	return ParseFormat(ext)
+}
+
+type record struct {
+	ID      string        `json:"id"`
+	Vector  []float32     `json:"vector"`
+	Sparse  *sparseRecord `json:"sparse,omitempty"`
+	Tokens  [][]float32   `json:"tokens,omitempty"`
+	Payload store.Payload `json:"payload,omitempty"`
+}
+
+// sparseRecord is a JSON Lines record's optional sparse vector. CSV
+// records have no sparse or tokens column.
+type sparseRecord struct {
+	Indices []uint32  `json:"indices"`
+	Values  []float32 `json:"values"`
+}
+
+// RowError reports an input row that could not be imported.
+type RowError struct {
+	Line int    // 1-based line the row starts on
+	Raw  string // the row as read, without its line terminator
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ReadOptions configures ReadRecords.
+type ReadOptions struct {
+	Format Format
+	// Dimension is the required vector length. Zero takes it from the
+	// first valid row.
+	Dimension int
+	// Workers is the number of rows parsed in parallel. Zero means
+	// GOMAXPROCS.
+	Workers int
+	// BatchSize is the number of vectors per emitted batch. Zero means
+	// 4096.
+	BatchSize int
+	// Reject is called, in input order, for each row that fails to parse
+	// or validate. Returning an error stops the read with that error. If
+	// Reject is nil the first bad row stops the read.
+	Reject func(*RowError) error
+	// Progress, if set, is called after each emitted batch.
+	Progress func(ReadStats)
+}
+
+// ReadStats counts the rows seen so far.
+type ReadStats struct {
+	Rows     int
+	Accepted int
+	Rejected int
+}
+
+// rawRow is one unparsed input row. err is set for a row the tokenizer
+// already found malformed.
+type rawRow struct {
+	line   int
+	raw    string
+	fields []string // CSV only
+	err    error
+}
+
+// parsedRow is a row after parsing: a vector or the reason it was rejected.
+type parsedRow struct {
+	v   store.Vector
+	err error
+}
+
+type recordChunk struct {
+	rows   []rawRow
+	parsed []parsedRow
+	err    error // read error ending the input
+	done   chan struct{}
+}
+
+const recordChunkSize = 512
+
+// ReadRecords reads records from r and passes the valid ones to emit in
+// batches, in input order. Rows are parsed by opts.Workers goroutines and
+// validated in order: an empty ID yields store.ErrEmptyID and a vector of
+// the wrong length store.ErrDimensionMismatch, both wrapped in a
+// *RowError. Malformed input rows are *RowErrors too. If ReadRecords
+// returns early, a background reader may still be blocked on r until it
+// yields its next row or is closed.
+func ReadRecords(r io.Reader, opts ReadOptions, emit func([]store.Vector) error) (ReadStats, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = importBatch
+	}
+
+	var (
+		next  func() (rawRow, error)
+		parse func(rawRow) (store.Vector, error)
+	)
+	switch opts.Format {
+	case FormatJSONL:
+		next, parse = jsonlRows(r), parseJSONLRow
+	case FormatCSV:
+		var err error
+		if next, parse, err = csvRows(r); err != nil {
+			return ReadStats{}, err
+		}
+	default:
+		return ReadStats{}, fmt.Errorf("vecio: unknown record format %v", opts.Format)
+	}
+
+	// The reader goroutine hands each chunk to the workers and, in input
+	// order, to the loop below, which waits for the chunk to be parsed.
+	jobs := make(chan *recordChunk, workers)
+	ordered := make(chan *recordChunk, 2*workers)
+	stop := make(chan struct{})
+	defer close(stop)
+	for range workers {
+		go func() {
+			for c := range jobs {
+				c.parsed = make([]parsedRow, len(c.rows))
+				for i, row := range c.rows {
+					if row.err != nil {
+						c.parsed[i].err = row.err
+						continue
+					}
+					c.parsed[i].v, c.parsed[i].err = parse(row)
+				}
+				close(c.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(ordered)
+		defer close(jobs)
+		for {
+			c := &recordChunk{done: make(chan struct{})}
+			for len(c.rows) < recordChunkSize {
+				row, err := next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					c.err = err
+					break
+				}
+				c.rows = append(c.rows, row)
+			}
+			select {
+			case jobs <- c:
+			case <-stop:
+				return
+			}
+			select {
+			case ordered <- c:
+			case <-stop:
+				return
+			}
+			if c.err != nil || len(c.rows) < recordChunkSize {
+				return
+			}
+		}
+	}()
+	var (
+		stats ReadStats
+		dim   = opts.Dimension
+		batch = make([]store.Vector, 0, batchSize)
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := emit(batch); err != nil {
+			return err
+		}
+		batch = make([]store.Vector, 0, batchSize)
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+		return nil
+	}
+	reject := func(e *RowError) error {
+		stats.Rejected++
+		if opts.Reject == nil {
+			return e
+		}
+		return opts.Reject(e)
+	}
+	for c := range ordered {
+		<-c.done
+		for i, row := range c.rows {
+			stats.Rows++
+			p := c.parsed[i]
+			err := p.err
+			if err == nil {
+				dim, err = checkRecord(p.v, dim)
+			}
+			if err != nil {
+				if err := reject(&RowError{Line: row.line, Raw: row.raw, Err: err}); err != nil {
+					return stats, err
+				}
+				continue
+			}
+			stats.Accepted++
+			batch = append(batch, p.v)
+			if len(batch) == batchSize {
+				if err := flush(); err != nil {
+					return stats, err
+				}
+			}
+		}
+		if c.err != nil {
+			return stats, c.err
+		}
+	}
+	return stats, flush()
+}
+
+// VectorDimension returns the dimension v implies: the length of its dense
+// vector, or else of its first token, or 0 if it has neither. It is how
+// ReadRecords takes the dimension from the first valid row.
+func VectorDimension(v store.Vector) int {
+	if len(v.Data) == 0 && len(v.Tokens) > 0 {
+		return len(v.Tokens[0])
+	}
+	return len(v.Data)
+}
+
+// checkRecord validates v the way the store will, so a bad row is rejected
+// on its own rather than failing the batch it would have joined. A zero dim
+// is taken from v by VectorDimension; the dimension in effect is returned.
+func checkRecord(v store.Vector, dim int) (int, error) {
+	if v.ID == "" {
+		return dim, store.ErrEmptyID
+	}
+	want := dim
+	if want == 0 {
+		want = VectorDimension(v)
+	}
+	if want == 0 || len(v.Data) == 0 && len(v.Tokens) == 0 {
+		return dim, fmt.Errorf("%w: empty vector", store.ErrDimensionMismatch)
+	}
+	if len(v.Data) != 0 && len(v.Data) != want {
+		return dim, fmt.Errorf("%w: got %d values, want %d", store.ErrDimensionMismatch, len(v.Data), want)
+	}
+	for i, tok := range v.Tokens {
+		if len(tok) != want {
+			return dim, fmt.Errorf("%w: token %d has %d values, want %d", store.ErrDimensionMismatch, i, len(tok), want)
+		}
+	}
+	if err := v.Sparse.Validate(); err != nil {
+		return dim, err
+	}
+	return want, nil
+}
+
+// jsonlRows splits r into lines. Blank lines are skipped.
+func jsonlRows(r io.Reader) func() (rawRow, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	line := 0
+	return func() (rawRow, error) {
+		for {
+			b, err := br.ReadBytes('\n')
+			if len(b) == 0 && err != nil {
+				return rawRow{}, err
+			}
+			line++
+			b = bytes.TrimRight(b, "\r\n")
+			if len(bytes.TrimSpace(b)) == 0 {
+				if err != nil {
+					return rawRow{}, err
+				}
+				continue
+			}
+			return rawRow{line: line, raw: string(b)}, nil
+		}
+	}
+}
+
+func parseJSONLRow(row rawRow) (store.Vector, error) {
+	var rec record
+	if err := json.Unmarshal([]byte(row.raw), &rec); err != nil {
+		return store.Vector{}, err
+	}
+	v := store.Vector{ID: rec.ID, Data: rec.Vector, Tokens: rec.Tokens, Payload: rec.Payload}
+	if rec.Sparse != nil {
+		v.Sparse = store.SparseVector{Indices: rec.Sparse.Indices, Values: rec.Sparse.Values}
+	}
+	return v, nil
+}
+
+// csvRows reads the header and returns a row source and a parser bound to
+// its column positions.
+func csvRows(r io.Reader) (func() (rawRow, error), func(rawRow) (store.Vector, error), error) {
+	cr := csv.NewReader(bufio.NewReaderSize(r, 1<<16))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("vecio: csv input has no header")
+		}
+		return nil, nil, fmt.Errorf("vecio: csv header: %w", err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	idCol, ok1 := col["id"]
+	vecCol, ok2 := col["vector"]
+	if !ok1 || !ok2 {
+		return nil, nil, fmt.Errorf("vecio: csv header %q needs id and vector columns", header)
+	}
+	payloadCol, hasPayload := col["payload"]
+
+	next := func() (rawRow, error) {
+		fields, err := cr.Read()
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return rawRow{line: perr.StartLine, err: perr.Err}, nil
+		}
+		if err != nil {
+			return rawRow{}, err
+		}
+		line, _ := cr.FieldPos(0)
+		return rawRow{line: line, raw: csvLine(fields), fields: fields}, nil
+	}
+	parse := func(row rawRow) (store.Vector, error) {
+		f := row.fields
+		if len(f) <= max(idCol, vecCol) || (hasPayload && len(f) <= payloadCol) {
+			return store.Vector{}, fmt.Errorf("row has %d fields, header has %d", len(f), len(header))
+		}
+		v := store.Vector{ID: f[idCol]}
+		if err := json.Unmarshal([]byte(f[vecCol]), &v.Data); err != nil {
+			return store.Vector{}, fmt.Errorf("vector: %w", err)
+		}
+		if hasPayload && strings.TrimSpace(f[payloadCol]) != "" {
+			if err := json.Unmarshal([]byte(f[payloadCol]), &v.Payload); err != nil {
+				return store.Vector{}, fmt.Errorf("payload: %w", err)
+			}
+		}
+		return v, nil
+	}
+	return next, parse, nil
+}
+
+// csvLine re-encodes a record as one CSV line for RowError.Raw.
+func csvLine(fields []string) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write(fields)
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// RecordWriter writes vectors as records.
+type RecordWriter struct {
+	format Format
+	bw     *bufio.Writer
+	cw     *csv.Writer
+	header bool
+}
+
+// NewRecordWriter returns a writer of records in format f to w. Call Flush
+// when done.
+func NewRecordWriter(w io.Writer, f Format) *RecordWriter {
+	rw := &RecordWriter{format: f, bw: bufio.NewWriterSize(w, 1<<16)}
+	if f == FormatCSV {
+		rw.cw = csv.NewWriter(rw.bw)
+	}
+	return rw
+}
+
+// Write writes one record.
+func (rw *RecordWriter) Write(v store.Vector) error {
+	switch rw.format {
+	case FormatJSONL:
+		rec := record{ID: v.ID, Vector: v.Data, Tokens: v.Tokens, Payload: v.Payload}
+		if v.Sparse.Len() > 0 {
+			rec.Sparse = &sparseRecord{Indices: v.Sparse.Indices, Values: v.Sparse.Values}
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("vecio: record %q: %w", v.ID, err)
+		}
+		rw.bw.Write(b)
+		return rw.bw.WriteByte('\n')
+	case FormatCSV:
+		if err := rw.writeHeader(); err != nil {
+			return err
+		}
+		vec, err := json.Marshal(v.Data)
+		if err != nil {
+			return fmt.Errorf("vecio: record %q: %w", v.ID, err)
+		}
+		var payload []byte
+		if len(v.Payload) > 0 {
+			if payload, err = json.Marshal(v.Payload); err != nil {
+				return fmt.Errorf("vecio: record %q: %w", v.ID, err)
+			}
+		}
+		return rw.cw.Write([]string{v.ID, string(vec), string(payload)})
+	default:
+		return fmt.Errorf("vecio: unknown record format %v", rw.format)
+	}
+}
+
+func (rw *RecordWriter) writeHeader() error {
+	if rw.header {
+		return nil
+	}
+	rw.header = true
+	return rw.cw.Write([]string{"id", "vector", "payload"})
+}
+
+// Flush writes any buffered records. A CSV with no records still gets its
+// header.
+func (rw *RecordWriter) Flush() error {
+	if rw.cw != nil {
+		if err := rw.writeHeader(); err != nil {
+			return err
+		}
+		rw.cw.Flush()
+		if err := rw.cw.Error(); err != nil {
+			return err
+		}
+	}
+	return rw.bw.Flush()
+}
@@ -0,0 +1,260 @@
+package vecio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"vexor/pkg/store"
+)
+
+func readAll(t *testing.T, input string, opts ReadOptions) ([]store.Vector, []*RowError, ReadStats, error) {
+	t.Helper()
+	var (
+		got      []store.Vector
+		rejected []*RowError
+	)
+	opts.Reject = func(e *RowError) error {
+		rejected = append(rejected, e)
+		return nil
+	}
+	stats, err := ReadRecords(strings.NewReader(input), opts, func(b []store.Vector) error {
+		got = append(got, b...)
+		return nil
+	})
+	return got, rejected, stats, err
+}
+
+func TestReadRecordsJSONL(t *testing.T) {
+	input := `{"id":"a","vector":[1,2],"payload":{"k":"v"}}
+{"id":"b","vector":[3,4,5]}
+
+{"id":"","vector":[1,1]}
+{"id":"c","vector":[}
+{"id":"d","vector":[6,7]}` // no trailing newline
+	got, rejected, stats, err := readAll(t, input, ReadOptions{Format: FormatJSONL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "a" || got[0].Payload["k"] != "v" || got[1].ID != "d" {
+		t.Fatalf("accepted %+v", got)
+	}
+	if stats != (ReadStats{Rows: 5, Accepted: 2, Rejected: 3}) {
+		t.Errorf("stats %+v", stats)
+	}
+	if len(rejected) != 3 {
+		t.Fatalf("rejected %v", rejected)
+	}
+	if !errors.Is(rejected[0], store.ErrDimensionMismatch) || rejected[0].Line != 2 {
+		t.Errorf("expected dimension mismatch on line 2, got %v", rejected[0])
+	}
+	if !errors.Is(rejected[1], store.ErrEmptyID) || rejected[1].Line != 4 {
+		t.Errorf("expected empty ID on line 4, got %v", rejected[1])
+	}
+	if rejected[2].Line != 5 || rejected[2].Raw != `{"id":"c","vector":[}` {
+		t.Errorf("malformed row reported as %+v", rejected[2])
+	}
+
+	// Without a Reject hook the first bad row stops the read.
+	_, err = ReadRecords(strings.NewReader(input), ReadOptions{Format: FormatJSONL, Dimension: 3}, func([]store.Vector) error { return nil })
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 1 || !errors.Is(err, store.ErrDimensionMismatch) {
+		t.Errorf("expected row error on line 1, got %v", err)
+	}
+}
+
+func TestReadRecordsInvalidSparse(t *testing.T) {
+	input := `{"id":"a","vector":[1,2],"sparse":{"indices":[3,1],"values":[1,1]}}
+{"id":"b","vector":[3,4],"sparse":{"indices":[1],"values":[1,2]}}
+{"id":"c","vector":[5,6],"sparse":{"indices":[1,3],"values":[1,2]}}`
+	got, rejected, stats, err := readAll(t, input, ReadOptions{Format: FormatJSONL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "c" || stats.Rejected != 2 {
+		t.Fatalf("accepted %+v, stats %+v", got, stats)
+	}
+	for i, e := range rejected {
+		if !errors.Is(e, store.ErrInvalidSparse) || e.Line != i+1 {
+			t.Errorf("rejected row %d: %v", i, e)
+		}
+	}
+	// The accepted rows insert as one batch.
+	if err := store.NewVectorStore(2).InsertBatch(got); err != nil {
+		t.Errorf("InsertBatch: %v", err)
+	}
+}
+
+func TestReadRecordsTokens(t *testing.T) {
+	input := `{"id":"a","tokens":[[1,0],[0,1]]}
+{"id":"b","vector":[],"tokens":[[1,0],[0]]}
+{"id":"c","vector":[1,1],"tokens":[[1,0,0]]}
+{"id":"d","vector":[1,1],"tokens":[[2,2]]}
+{"id":"e","vector":[]}`
+	got, rejected, stats, err := readAll(t, input, ReadOptions{Format: FormatJSONL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "d" || stats.Rejected != 3 {
+		t.Fatalf("accepted %+v, stats %+v", got, stats)
+	}
+	for i, line := range []int{2, 3, 5} {
+		if !errors.Is(rejected[i], store.ErrDimensionMismatch) || rejected[i].Line != line {
+			t.Errorf("expected dimension mismatch on line %d, got %v", line, rejected[i])
+		}
+	}
+	if err := store.NewVectorStore(2).InsertBatch(got); err != nil {
+		t.Errorf("InsertBatch: %v", err)
+	}
+}
+
+func TestReadRecordsCSV(t *testing.T) {
+	input := "ID,extra,vector,payload\n" +
+		"a,x,\"[1,2]\",\"{\"\"n\"\":1}\"\n" +
+		"b,x,\"[3,4]\",\n" +
+		"c,x,not a vector,\n" +
+		"d,x,\"[5, 6]\"\n" + // short row
+		"e,\"bad\"quote,\"[7,8]\",\n" +
+		"f,x,\"[9,10]\",\n"
+	got, rejected, _, err := readAll(t, input, ReadOptions{Format: FormatCSV})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(got))
+	for i, v := range got {
+		ids[i] = v.ID
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "b", "f"}) || got[0].Payload["n"] != 1.0 || got[1].Payload != nil {
+		t.Fatalf("accepted %+v", got)
+	}
+	var lines []int
+	for _, e := range rejected {
+		lines = append(lines, e.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{4, 5, 6}) {
+		t.Errorf("rejected lines %v: %v", lines, rejected)
+	}
+
+	if _, err := ReadRecords(strings.NewReader("name,vector\n"), ReadOptions{Format: FormatCSV}, nil); err == nil {
+		t.Error("expected error for a header without an id column")
+	}
+}
+
+func TestReadRecordsOrder(t *testing.T) {
+	var sb strings.Builder
+	for i := range 5000 {
+		fmt.Fprintf(&sb, `{"id":"v%d","vector":[%d]}`+"\n", i, i)
+	}
+	var batches int
+	var got []store.Vector
+	var progress []ReadStats
+	stats, err := ReadRecords(strings.NewReader(sb.String()), ReadOptions{
+		Format:    FormatJSONL,
+		Workers:   4,
+		BatchSize: 700,
+		Progress:  func(s ReadStats) { progress = append(progress, s) },
+	}, func(b []store.Vector) error {
+		batches++
+		got = append(got, b...)
+		return nil
+	})
+	if err != nil || stats.Accepted != 5000 {
+		t.Fatalf("stats %+v, %v", stats, err)
+	}
+	for i, v := range got {
+		if v.ID != fmt.Sprintf("v%d", i) || v.Data[0] != float32(i) {
+			t.Fatalf("row %d out of order: %+v", i, v)
+		}
+	}
+	if batches != 8 || len(progress) != 8 || progress[7].Accepted != 5000 {
+		t.Errorf("%d batches, progress %+v", batches, progress)
+	}
+
+	stop := errors.New("stop")
+	_, err = ReadRecords(strings.NewReader(sb.String()), ReadOptions{Format: FormatJSONL, BatchSize: 10},
+		func([]store.Vector) error { return stop })
+	if err != stop {
+		t.Errorf("expected emit error to stop the read, got %v", err)
+	}
+}
+
+func TestRecordWriterRoundTrip(t *testing.T) {
+	vecs := []store.Vector{
+		{ID: "a", Data: []float32{0.5, -1}, Payload: store.Payload{"tags": []any{"x", "y"}}},
+		{ID: "b,with \"quotes\"", Data: []float32{2, 3}},
+	}
+	for _, f := range []Format{FormatJSONL, FormatCSV} {
+		var buf bytes.Buffer
+		rw := NewRecordWriter(&buf, f)
+		for _, v := range vecs {
+			if err := rw.Write(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := rw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		got, rejected, _, err := readAll(t, buf.String(), ReadOptions{Format: f})
+		if err != nil || len(rejected) > 0 || !reflect.DeepEqual(got, vecs) {
+			t.Errorf("%v: got %+v, rejected %v, %v", f, got, rejected, err)
+		}
+	}
+
+	// JSON Lines also carries sparse vectors and token vectors.
+	var buf bytes.Buffer
+	rw := NewRecordWriter(&buf, FormatJSONL)
+	rich := store.Vector{
+		ID:     "s",
+		Data:   []float32{1, 1},
+		Sparse: store.SparseVector{Indices: []uint32{3, 90}, Values: []float32{0.5, 2}},
+		Tokens: [][]float32{{1, 0}, {1, 2}},
+	}
+	rw.Write(rich)
+	rw.Flush()
+	if got, _, _, err := readAll(t, buf.String(), ReadOptions{Format: FormatJSONL}); err != nil || len(got) != 1 || !reflect.DeepEqual(got[0], rich) {
+		t.Errorf("sparse and token round trip: got %+v, %v", got, err)
+	}
+
+	if f, err := FormatOf("dump.CSV"); err != nil || f != FormatCSV {
+		t.Errorf("FormatOf: %v, %v", f, err)
+	}
+	if _, err := FormatOf("dump"); err == nil {
+		t.Error("expected error for a path without an extension")
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	s := store.NewVectorStore(2)
+	for i := range 2500 {
+		s.Insert(store.Vector{ID: fmt.Sprintf("v%d", i), Data: []float32{float32(i), 1}})
+	}
+	s.Insert(store.Vector{ID: "p", Data: []float32{0, 0}, Payload: store.Payload{"m": map[string]any{"k": []any{1.0}}}})
+
+	path := filepath.Join(t.TempDir(), "s.snap")
+	if n, err := SaveSnapshot(path, s); err != nil || n != 2501 {
+		t.Fatalf("save: %d, %v", n, err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Count() != 2501 || loaded.Dimension() != 2 {
+		t.Fatalf("loaded %d vectors of dimension %d", loaded.Count(), loaded.Dimension())
+	}
+	if v, err := loaded.Get("p"); err != nil || !reflect.DeepEqual(v.Payload, store.Payload{"m": map[string]any{"k": []any{1.0}}}) {
+		t.Errorf("payload lost: %+v, %v", v, err)
+	}
+
+	var buf bytes.Buffer
+	WriteSnapshot(&buf, s)
+	if _, err := ReadSnapshot(bytes.NewReader(buf.Bytes()[:buf.Len()-10])); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("expected ErrBadSnapshot for a truncated snapshot, got %v", err)
+	}
+	if _, err := ReadSnapshot(strings.NewReader("not a snapshot")); !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("expected ErrBadSnapshot, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package vecio
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"vexor/pkg/store"
+)
+
+// A snapshot file holds a store's visible vectors, payloads and expiry
+// times included, as a gob stream: a snapshotHeader, then chunks of
+// vectors, then an empty chunk marking the end, so truncation is detected.
+// It is a point-in-time copy for moving data around, not a write-ahead
+// log; the store itself stays in memory.
+
+const (
+	snapshotMagic   = "vexor-snapshot"
+	snapshotVersion = 1
+	snapshotChunk   = 1024
+)
+
+// ErrBadSnapshot is returned when a file is not a snapshot or is cut short.
+var ErrBadSnapshot = errors.New("vecio: malformed or truncated snapshot")
+
+type snapshotHeader struct {
+	Magic     string
+	Version   int
+	Dimension int
+}
+
+func init() {
+	// Payloads decoded from JSON nest these types inside interface values.
+	gob.Register([]any(nil))
+	gob.Register(map[string]any(nil))
+}
+
+// WriteSnapshot writes every visible vector in s to w and returns how many
+// it wrote. The snapshot is a consistent point-in-time view.
+func WriteSnapshot(w io.Writer, s *store.VectorStore) (int, error) {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Magic: snapshotMagic, Version: snapshotVersion, Dimension: s.Dimension()}); err != nil {
+		return 0, err
+	}
+	n := 0
+	chunk := make([]store.Vector, 0, snapshotChunk)
+	for v := range s.Scan(store.ScanOptions{Payloads: true}) {
+		chunk = append(chunk, v)
+		if len(chunk) == snapshotChunk {
+			if err := enc.Encode(chunk); err != nil {
+				return n, err
+			}
+			n += len(chunk)
+			chunk = chunk[:0]
+		}
+	}
+	if len(chunk) > 0 {
+		if err := enc.Encode(chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, enc.Encode([]store.Vector{})
+}
+
+// ReadSnapshot reads a snapshot from r into a new store built with opts.
+func ReadSnapshot(r io.Reader, opts ...store.Option) (*store.VectorStore, error) {
+	dec := gob.NewDecoder(r)
+	var h snapshotHeader
+	if err := dec.Decode(&h); err != nil || h.Magic != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad header", ErrBadSnapshot)
+	}
+	if h.Version != snapshotVersion || h.Dimension <= 0 {
+		return nil, fmt.Errorf("%w: version %d, dimension %d", ErrBadSnapshot, h.Version, h.Dimension)
+	}
+	s := store.NewVectorStore(h.Dimension, opts...)
+	for {
+		var chunk []store.Vector
+		if err := dec.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("%w: %w", ErrBadSnapshot, err)
+		}
+		if len(chunk) == 0 {
+			return s, nil
+		}
+		if err := s.InsertBatch(chunk); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBadSnapshot, err)
+		}
+	}
+}
+
+// SaveSnapshot writes a snapshot of s to path. A regular file is written
+// under a temporary name and renamed into place, so path never holds a
+// partial snapshot; anything else, such as a pipe or device, is written
+// directly.
+func SaveSnapshot(path string, s *store.VectorStore) (int, error) {
+	if fi, err := os.Stat(path); err == nil && !fi.Mode().IsRegular() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return 0, err
+		}
+		n, err := WriteSnapshot(f, s)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return n, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := WriteSnapshot(f, s)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// LoadSnapshot reads the snapshot at path into a new store.
+func LoadSnapshot(path string, opts ...store.Option) (*store.VectorStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f, opts...)
+}
@@ -0,0 +1,162 @@
+package vecio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"vexor/pkg/store"
+)
+
+// importBatch is how many vectors each InsertBatch call carries.
+const importBatch = 4096
+
+// ImportNPY loads the matrix in npyPath into s. IDs come from idsPath, one
+// per line, matching the matrix rows; with idsPath empty, each vector's ID
+// is its row number. It returns the number of vectors inserted.
+func ImportNPY(s *store.VectorStore, npyPath, idsPath string) (int, error) {
+	f, err := os.Open(npyPath)
+	if err != nil {
+		return 0, err
+	}
+	vecs, err := ReadNPY(f)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	if idsPath != "" {
+		if ids, err = LoadIDs(idsPath); err != nil {
+			return 0, err
+		}
+	}
+	return insertAll(s, vecs, ids)
+}
+
+// ImportNPZ loads the float array vectorsName from the archive at path
+// into s, taking IDs from the string array idsName, or row numbers if
+// idsName is empty.
+func ImportNPZ(s *store.VectorStore, path, vectorsName, idsName string) (int, error) {
+	z, err := OpenNPZ(path)
+	if err != nil {
+		return 0, err
+	}
+	defer z.Close()
+	vecs, err := z.Vectors(vectorsName)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	if idsName != "" {
+		if ids, err = z.Strings(idsName); err != nil {
+			return 0, err
+		}
+	}
+	return insertAll(s, vecs, ids)
+}
+
+func insertAll(s *store.VectorStore, vecs [][]float32, ids []string) (int, error) {
+	if ids != nil && len(ids) != len(vecs) {
+		return 0, fmt.Errorf("vecio: %d ids for %d vectors", len(ids), len(vecs))
+	}
+	batch := make([]store.Vector, 0, min(importBatch, len(vecs)))
+	for start := 0; start < len(vecs); start += importBatch {
+		batch = batch[:0]
+		for i := start; i < min(start+importBatch, len(vecs)); i++ {
+			id := strconv.Itoa(i)
+			if ids != nil {
+				id = ids[i]
+			}
+			batch = append(batch, store.Vector{ID: id, Data: vecs[i]})
+		}
+		if err := s.InsertBatch(batch); err != nil {
+			return start, fmt.Errorf("vecio: inserting rows %d-%d: %w", start, start+len(batch)-1, err)
+		}
+	}
+	return len(vecs), nil
+}
+
+// ExportNPY writes every vector in s to npyPath as a float32 matrix and its
+// IDs, in the same row order, to idsPath, one per line. The export is a
+// consistent point-in-time view. It returns the number of vectors written.
+func ExportNPY(s *store.VectorStore, npyPath, idsPath string) (int, error) {
+	vecs, ids := collect(s)
+	if err := writeFile(npyPath, func(w io.Writer) error { return writeNPYMatrix(w, vecs, s.Dimension()) }); err != nil {
+		return 0, err
+	}
+	if err := writeFile(idsPath, func(w io.Writer) error { return WriteIDs(w, ids) }); err != nil {
+		return 0, err
+	}
+	return len(vecs), nil
+}
+
+// ExportNPZ writes every vector in s to path as an archive holding the
+// arrays "vectors" and "ids".
+func ExportNPZ(s *store.VectorStore, path string) (int, error) {
+	vecs, ids := collect(s)
+	if err := writeFile(path, func(w io.Writer) error { return writeNPZ(w, vecs, s.Dimension(), ids) }); err != nil {
+		return 0, err
+	}
+	return len(vecs), nil
+}
+
+func collect(s *store.VectorStore) ([][]float32, []string) {
+	vecs := [][]float32{}
+	ids := []string{}
+	for v := range s.Scan(store.ScanOptions{}) {
+		vecs = append(vecs, v.Data)
+		ids = append(ids, v.ID)
+	}
+	return vecs, ids
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadIDs reads the ID file at path: one ID per line.
+func LoadIDs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIDs(f)
+}
+
+// ReadIDs reads one ID per line. A trailing "\r" is stripped, so files
+// written on Windows load too.
+func ReadIDs(r io.Reader) ([]string, error) {
+	var ids []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		ids = append(ids, strings.TrimSuffix(sc.Text(), "\r"))
+	}
+	return ids, sc.Err()
+}
+
+// WriteIDs writes one ID per line. IDs containing a line break cannot be
+// represented and are rejected.
+func WriteIDs(w io.Writer, ids []string) error {
+	bw := bufio.NewWriter(w)
+	for _, id := range ids {
+		if strings.ContainsAny(id, "\r\n") {
+			return fmt.Errorf("vecio: id %q contains a line break", id)
+		}
+		bw.WriteString(id)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,188 @@
+// Package vecio reads and writes vectors in common dataset file formats.
+package vecio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// The TEXMEX formats used by the SIFT, GIST and BIGANN benchmark sets store
+// each vector as a little-endian int32 dimension followed by that many
+// components: float32 for .fvecs, int32 for .ivecs and uint8 for .bvecs.
+// Ground truth files are .ivecs of neighbor positions in the base file.
+
+// ErrBadDimension is returned for a record whose dimension is not positive
+// or differs from the first record's.
+var ErrBadDimension = errors.New("vecio: inconsistent or invalid vector dimension")
+
+// maxDimension bounds the dimension read from a record header, so a corrupt
+// or mistyped file cannot trigger a huge allocation.
+const maxDimension = 1 << 20
+
+// ReadFvecs reads up to limit vectors from r in .fvecs format. limit <= 0
+// reads the whole file.
+func ReadFvecs(r io.Reader, limit int) ([][]float32, error) {
+	return readVecs(r, limit, 4, func(b []byte, v []float32) {
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+	})
+}
+
+// ReadIvecs reads up to limit vectors from r in .ivecs format.
+func ReadIvecs(r io.Reader, limit int) ([][]int32, error) {
+	return readVecs(r, limit, 4, func(b []byte, v []int32) {
+		for i := range v {
+			v[i] = int32(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+	})
+}
+
+// ReadBvecs reads up to limit vectors from r in .bvecs format.
+func ReadBvecs(r io.Reader, limit int) ([][]uint8, error) {
+	return readVecs(r, limit, 1, func(b []byte, v []uint8) {
+		copy(v, b)
+	})
+}
+
+// WriteFvecs writes vecs to w in .fvecs format.
+func WriteFvecs(w io.Writer, vecs [][]float32) error {
+	return writeVecs(w, vecs, 4, func(b []byte, v []float32) {
+		for i, x := range v {
+			binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(x))
+		}
+	})
+}
+
+// WriteIvecs writes vecs to w in .ivecs format.
+func WriteIvecs(w io.Writer, vecs [][]int32) error {
+	return writeVecs(w, vecs, 4, func(b []byte, v []int32) {
+		for i, x := range v {
+			binary.LittleEndian.PutUint32(b[i*4:], uint32(x))
+		}
+	})
+}
+
+// WriteBvecs writes vecs to w in .bvecs format.
+func WriteBvecs(w io.Writer, vecs [][]uint8) error {
+	return writeVecs(w, vecs, 1, func(b []byte, v []uint8) {
+		copy(b, v)
+	})
+}
+
+// readVecs decodes TEXMEX records of size-byte components. All vectors of
+// a file share one backing array.
+func readVecs[T any](r io.Reader, limit, size int, decode func([]byte, []T)) ([][]T, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	var (
+		out  [][]T
+		dim  int
+		buf  []byte
+		head [4]byte
+		data []T
+	)
+	for limit <= 0 || len(out) < limit {
+		if _, err := io.ReadFull(br, head[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("vecio: vector %d: %w", len(out), err)
+		}
+		d := int(int32(binary.LittleEndian.Uint32(head[:])))
+		if d <= 0 || d > maxDimension || (dim != 0 && d != dim) {
+			return nil, fmt.Errorf("vecio: vector %d has dimension %d: %w", len(out), d, ErrBadDimension)
+		}
+		if dim == 0 {
+			dim = d
+			buf = make([]byte, dim*size)
+		}
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("vecio: vector %d: %w", len(out), io.ErrUnexpectedEOF)
+		}
+		if len(data)+dim > cap(data) {
+			data = make([]T, 0, max(dim*1024, 2*cap(data)))
+		}
+		start := len(data)
+		data = data[:start+dim]
+		v := data[start : start+dim : start+dim]
+		decode(buf, v)
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func writeVecs[T any](w io.Writer, vecs [][]T, size int, encode func([]byte, []T)) error {
+	bw := bufio.NewWriterSize(w, 1<<16)
+	var buf []byte
+	for i, v := range vecs {
+		if len(v) == 0 || (i > 0 && len(v) != len(vecs[0])) {
+			return fmt.Errorf("vecio: vector %d has dimension %d: %w", i, len(v), ErrBadDimension)
+		}
+		if len(buf) != 4+len(v)*size {
+			buf = make([]byte, 4+len(v)*size)
+		}
+		binary.LittleEndian.PutUint32(buf, uint32(len(v)))
+		encode(buf[4:], v)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// LoadVectors reads up to limit float vectors from a .fvecs or .bvecs file,
+// converting bytes to float32.
+func LoadVectors(path string, limit int) ([][]float32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch ext := filepath.Ext(path); ext {
+	case ".fvecs":
+		return ReadFvecs(f, limit)
+	case ".bvecs":
+		bvecs, err := ReadBvecs(f, limit)
+		if err != nil {
+			return nil, err
+		}
+		return BytesToFloat32(bvecs), nil
+	default:
+		return nil, fmt.Errorf("vecio: unsupported vector file extension %q", ext)
+	}
+}
+
+// LoadGroundTruth reads up to limit neighbor lists from an .ivecs file.
+func LoadGroundTruth(path string, limit int) ([][]int32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIvecs(f, limit)
+}
+
+// BytesToFloat32 converts byte vectors, as stored in .bvecs, to float32.
+func BytesToFloat32(vecs [][]uint8) [][]float32 {
+	out := make([][]float32, len(vecs))
+	if len(vecs) == 0 {
+		return out
+	}
+	dim := len(vecs[0])
+	data := make([]float32, len(vecs)*dim)
+	for i, v := range vecs {
+		f := data[i*dim : (i+1)*dim : (i+1)*dim]
+		for j, b := range v {
+			f[j] = float32(b)
+		}
+		out[i] = f
+	}
+	return out
+}
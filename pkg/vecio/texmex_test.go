@@ -0,0 +1,117 @@
+package vecio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFvecsRoundTrip(t *testing.T) {
+	vecs := [][]float32{{1, 2, 3}, {-1.5, 0, 4e10}, {0, 0, 0}}
+	var buf bytes.Buffer
+	if err := WriteFvecs(&buf, vecs); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 3*(4+3*4) {
+		t.Fatalf("unexpected encoded size %d", buf.Len())
+	}
+	got, err := ReadFvecs(bytes.NewReader(buf.Bytes()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, vecs) {
+		t.Fatalf("got %v, want %v", got, vecs)
+	}
+
+	got, err = ReadFvecs(bytes.NewReader(buf.Bytes()), 2)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("limited read returned %d vectors, %v", len(got), err)
+	}
+}
+
+func TestIvecsBvecsRoundTrip(t *testing.T) {
+	ivecs := [][]int32{{7, -1}, {0, 1 << 30}}
+	var buf bytes.Buffer
+	if err := WriteIvecs(&buf, ivecs); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadIvecs(&buf, 0); err != nil || !reflect.DeepEqual(got, ivecs) {
+		t.Fatalf("ivecs: got %v, %v", got, err)
+	}
+
+	bvecs := [][]uint8{{0, 128, 255}, {1, 2, 3}}
+	buf.Reset()
+	if err := WriteBvecs(&buf, bvecs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBvecs(&buf, 0)
+	if err != nil || !reflect.DeepEqual(got, bvecs) {
+		t.Fatalf("bvecs: got %v, %v", got, err)
+	}
+	if f := BytesToFloat32(got); f[0][2] != 255 || f[1][0] != 1 {
+		t.Fatalf("unexpected conversion %v", f)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	var buf bytes.Buffer
+	WriteFvecs(&buf, [][]float32{{1, 2}})
+	truncated := buf.Bytes()[:buf.Len()-1]
+	if _, err := ReadFvecs(bytes.NewReader(truncated), 0); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected ErrUnexpectedEOF, got %v", err)
+	}
+
+	// A second record with a different dimension.
+	WriteFvecs(&buf, [][]float32{{1, 2, 3}})
+	if _, err := ReadFvecs(&buf, 0); !errors.Is(err, ErrBadDimension) {
+		t.Errorf("expected ErrBadDimension, got %v", err)
+	}
+
+	var neg [4]byte
+	binary.LittleEndian.PutUint32(neg[:], uint32(0xFFFFFFFF))
+	if _, err := ReadFvecs(bytes.NewReader(neg[:]), 0); !errors.Is(err, ErrBadDimension) {
+		t.Errorf("expected ErrBadDimension for negative dimension, got %v", err)
+	}
+
+	if err := WriteFvecs(io.Discard, [][]float32{{1}, {1, 2}}); !errors.Is(err, ErrBadDimension) {
+		t.Errorf("expected ErrBadDimension on write, got %v", err)
+	}
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, fn func(f *os.File) error) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fn(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		return path
+	}
+
+	fpath := write("base.fvecs", func(f *os.File) error { return WriteFvecs(f, [][]float32{{1, 2}}) })
+	bpath := write("base.bvecs", func(f *os.File) error { return WriteBvecs(f, [][]uint8{{3, 4}}) })
+	gpath := write("gt.ivecs", func(f *os.File) error { return WriteIvecs(f, [][]int32{{0}}) })
+
+	if v, err := LoadVectors(fpath, 0); err != nil || v[0][1] != 2 {
+		t.Errorf("fvecs load: %v, %v", v, err)
+	}
+	if v, err := LoadVectors(bpath, 0); err != nil || v[0][1] != 4 {
+		t.Errorf("bvecs load: %v, %v", v, err)
+	}
+	if _, err := LoadVectors(gpath, 0); err == nil {
+		t.Error("expected error loading .ivecs as vectors")
+	}
+	if g, err := LoadGroundTruth(gpath, 0); err != nil || g[0][0] != 0 {
+		t.Errorf("ground truth load: %v, %v", g, err)
+	}
+}